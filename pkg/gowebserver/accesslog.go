@@ -0,0 +1,235 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	accessLogFormatCommon   = "common"
+	accessLogFormatCombined = "combined"
+	// accessLogFormatExtended is the combined format followed by the
+	// duration, mount, trace ID and request ID.
+	accessLogFormatExtended = "extended"
+	accessLogFormatJSON     = "json"
+
+	// commonLogTimeFormat is the timestamp format of the Common Log Format.
+	commonLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+)
+
+var (
+	accessLogFormats = []string{accessLogFormatCommon, accessLogFormatCombined, accessLogFormatExtended, accessLogFormatJSON}
+)
+
+// accessLogEntryKey is the context key of the *accessLogEntry of a request.
+type accessLogEntryKey struct{}
+
+// accessLogEntry holds the details of a request that are only known to the
+// handlers further down the handler tree.
+type accessLogEntry struct {
+	mount   string
+	traceID string
+	user    string
+}
+
+// accessLogRecord is a single access log line, it is also the JSON format.
+type accessLogRecord struct {
+	Time            time.Time `json:"time"`
+	RemoteAddr      string    `json:"remoteAddr"`
+	User            string    `json:"user,omitempty"`
+	Method          string    `json:"method"`
+	URI             string    `json:"uri"`
+	Proto           string    `json:"proto"`
+	Host            string    `json:"host"`
+	Status          int       `json:"status"`
+	Bytes           int64     `json:"bytes"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	Referer         string    `json:"referer,omitempty"`
+	UserAgent       string    `json:"userAgent,omitempty"`
+	Mount           string    `json:"mount,omitempty"`
+	TraceID         string    `json:"traceId,omitempty"`
+	RequestID       string    `json:"requestId,omitempty"`
+	// ClientCertSHA256 is the fingerprint of the verified client certificate.
+	ClientCertSHA256 string `json:"clientCertSha256,omitempty"`
+}
+
+// accessLogger writes a line per request in the configured format.
+type accessLogger struct {
+	format string
+	mu     sync.Mutex
+	out    io.Writer
+}
+
+// newAccessLogger opens the access log, the logger is nil if the access log is
+// disabled. The returned function releases the log file, which the handler
+// generations of a reload share while they log to the same path.
+func newAccessLogger(conf AccessLog) (*accessLogger, func() error, error) {
+	if conf.Format == "" {
+		return nil, nilFuncWithError, nil
+	}
+	if isStdoutPath(conf.Path) {
+		return &accessLogger{format: conf.Format, out: os.Stdout}, nilFuncWithError, nil
+	}
+	f, release, err := acquireRotatingFile(conf.Path, conf.MaxSizeBytes, conf.MaxBackups, conf.Compress)
+	if err != nil {
+		return nil, nilFuncWithError, fmt.Errorf("cannot open access log '%s', %w", conf.Path, err)
+	}
+	return &accessLogger{format: conf.Format, out: f}, release, nil
+}
+
+func isStdoutPath(p string) bool {
+	return p == "" || p == "-"
+}
+
+// handler wraps h so that its requests are written to the access log, h is
+// returned as is if the access log is disabled.
+func (l *accessLogger) handler(h http.Handler) http.Handler {
+	if l == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessLogEntry{}
+		sw := &statusResponseWriter{ResponseWriter: w}
+		// Deferred so that requests aborted with a panic are logged as well.
+		defer func() {
+			l.log(newAccessLogRecord(r, sw, entry, start))
+		}()
+		h.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), accessLogEntryKey{}, entry)))
+	})
+}
+
+// withAccessLogMount records the mount and the trace ID of the requests served
+// by h in their access log entry.
+func withAccessLogMount(h http.Handler, mount string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if entry, ok := r.Context().Value(accessLogEntryKey{}).(*accessLogEntry); ok {
+			entry.mount = mount
+			if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+				entry.traceID = sc.TraceID().String()
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func newAccessLogRecord(r *http.Request, sw *statusResponseWriter, entry *accessLogEntry, start time.Time) *accessLogRecord {
+	remoteAddr := r.RemoteAddr
+	if addr := requestClientIP(r); addr.IsValid() {
+		remoteAddr = addr.String()
+	} else if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
+	}
+	fingerprint := ""
+	if cert, ok := clientCertificate(r); ok {
+		fingerprint = certificateFingerprint(cert)
+	}
+	return &accessLogRecord{
+		Time:             start,
+		RemoteAddr:       remoteAddr,
+		User:             entry.user,
+		Method:           r.Method,
+		URI:              r.RequestURI,
+		Proto:            r.Proto,
+		Host:             r.Host,
+		Status:           sw.statusCode(),
+		Bytes:            sw.bytes,
+		DurationSeconds:  time.Since(start).Seconds(),
+		Referer:          r.Referer(),
+		UserAgent:        r.UserAgent(),
+		Mount:            entry.mount,
+		TraceID:          entry.traceID,
+		RequestID:        requestID(r.Context()),
+		ClientCertSHA256: fingerprint,
+	}
+}
+
+func (l *accessLogger) log(rec *accessLogRecord) {
+	line, err := formatAccessLogRecord(l.format, rec)
+	if err != nil {
+		zap.S().With("error", err).Warn("cannot format access log line")
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := io.WriteString(l.out, line); err != nil {
+		zap.S().With("error", err).Warn("cannot write access log")
+	}
+}
+
+// formatAccessLogRecord returns the newline terminated log line of the record.
+func formatAccessLogRecord(format string, rec *accessLogRecord) (string, error) {
+	if format == accessLogFormatJSON {
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	}
+
+	bytes := "-"
+	if rec.Bytes > 0 {
+		bytes = fmt.Sprint(rec.Bytes)
+	}
+	line := fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s`,
+		orDash(rec.RemoteAddr), orDash(escapeLogValue(rec.User)), rec.Time.Format(commonLogTimeFormat),
+		escapeLogValue(rec.Method), escapeLogValue(rec.URI), escapeLogValue(rec.Proto), rec.Status, bytes)
+	if format == accessLogFormatCombined || format == accessLogFormatExtended {
+		line += fmt.Sprintf(` "%s" "%s"`, orDash(escapeLogValue(rec.Referer)), orDash(escapeLogValue(rec.UserAgent)))
+	}
+	if format == accessLogFormatExtended {
+		line += fmt.Sprintf(` %.6f "%s" %s %s`, rec.DurationSeconds, orDash(escapeLogValue(rec.Mount)), orDash(rec.TraceID), orDash(escapeLogValue(rec.RequestID)))
+	}
+	return line + "\n", nil
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// escapeLogValue escapes quotes, backslashes and non-printable bytes like
+// Apache httpd so that request values cannot forge log lines.
+func escapeLogValue(value string) string {
+	sb := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&sb, `\x%02x`, c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,158 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestFormatAccessLogRecord(t *testing.T) {
+	rec := &accessLogRecord{
+		Time:            time.Date(2026, 10, 17, 13, 55, 36, 0, time.FixedZone("", -7*60*60)),
+		RemoteAddr:      "127.0.0.1",
+		User:            "frank",
+		Method:          http.MethodGet,
+		URI:             "/apache_pb.gif",
+		Proto:           "HTTP/1.1",
+		Host:            "www.example.com",
+		Status:          http.StatusOK,
+		Bytes:           2326,
+		DurationSeconds: 0.25,
+		Referer:         "http://www.example.com/start.html",
+		UserAgent:       "Mozilla/4.08",
+		Mount:           "/",
+		TraceID:         "4bf92f3577b34da6a3ce929d0e0e4736",
+		RequestID:       "f4c2b1a0",
+	}
+	noBody := *rec
+	noBody.User = ""
+	noBody.Bytes = 0
+	noBody.Referer = ""
+	noBody.UserAgent = `evil" "agent` + "\n"
+
+	testCases := []struct {
+		format string
+		rec    *accessLogRecord
+		want   string
+	}{
+		{
+			format: accessLogFormatCommon,
+			rec:    rec,
+			want:   `127.0.0.1 - frank [17/Oct/2026:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 200 2326` + "\n",
+		},
+		{
+			format: accessLogFormatCombined,
+			rec:    rec,
+			want:   `127.0.0.1 - frank [17/Oct/2026:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"` + "\n",
+		},
+		{
+			format: accessLogFormatCombined,
+			rec:    &noBody,
+			want:   `127.0.0.1 - - [17/Oct/2026:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 200 - "-" "evil\" \"agent\x0a"` + "\n",
+		},
+		{
+			format: accessLogFormatExtended,
+			rec:    rec,
+			want:   `127.0.0.1 - frank [17/Oct/2026:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.1" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08" 0.250000 "/" 4bf92f3577b34da6a3ce929d0e0e4736 f4c2b1a0` + "\n",
+		},
+		{
+			format: accessLogFormatExtended,
+			rec:    &accessLogRecord{Time: rec.Time, RemoteAddr: "127.0.0.1", Method: http.MethodGet, URI: "/", Proto: "HTTP/1.1", Status: http.StatusNotFound},
+			want:   `127.0.0.1 - - [17/Oct/2026:13:55:36 -0700] "GET / HTTP/1.1" 404 - "-" "-" 0.000000 "-" - -` + "\n",
+		},
+		{
+			format: accessLogFormatJSON,
+			rec:    rec,
+			want:   `{"time":"2026-10-17T13:55:36-07:00","remoteAddr":"127.0.0.1","user":"frank","method":"GET","uri":"/apache_pb.gif","proto":"HTTP/1.1","host":"www.example.com","status":200,"bytes":2326,"durationSeconds":0.25,"referer":"http://www.example.com/start.html","userAgent":"Mozilla/4.08","mount":"/","traceId":"4bf92f3577b34da6a3ce929d0e0e4736","requestId":"f4c2b1a0"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		got, err := formatAccessLogRecord(tc.format, tc.rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("formatAccessLogRecord(%s) mismatch (-want +got):\n%s", tc.format, diff)
+		}
+	}
+}
+
+func TestAccessLoggerHandler(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := &accessLogger{format: accessLogFormatJSON, out: out}
+	mux := http.NewServeMux()
+	mux.Handle("/files/", withAccessLogMount(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("hello"))
+	}), "/files/"))
+	auth, err := newAuthenticator(Auth{Users: []User{{Name: "frank", PasswordHash: mustHashPassword(t, "secret")}}}, ClientAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := l.handler(auth.handler(mux))
+
+	req := httptest.NewRequest(http.MethodGet, "/files/a.txt?b=1", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	req.SetBasicAuth("frank", "secret")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	got := []accessLogRecord{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		rec := accessLogRecord{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("cannot parse access log line %q, %s", line, err)
+		}
+		got = append(got, rec)
+	}
+	want := []accessLogRecord{
+		{RemoteAddr: "192.0.2.1", User: "frank", Method: http.MethodGet, URI: "/files/a.txt?b=1", Proto: "HTTP/1.1", Host: "example.com", Status: http.StatusAccepted, Bytes: 5, UserAgent: "test-agent", Mount: "/files/"},
+		{RemoteAddr: "192.0.2.1", Method: http.MethodGet, URI: "/missing", Proto: "HTTP/1.1", Host: "example.com", Status: http.StatusNotFound, Bytes: 19},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(accessLogRecord{}, "Time", "DurationSeconds")); diff != "" {
+		t.Errorf("access log mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWebServer_AccessLog(t *testing.T) {
+	dir := mustTempDir(t)
+	logFile := filepath.Join(dir, "logs", "access.log")
+	baseURL, close := serveAsync(t, &Config{
+		Serve:     []Serve{{Source: dir, Endpoint: "/"}},
+		AccessLog: AccessLog{Format: accessLogFormatCombined, Path: logFile},
+	})
+	mustGetBody(t, baseURL+"/?hello")
+	close()
+
+	contents, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(contents), `"GET /?hello HTTP/1.1" 200 `) {
+		t.Errorf("access log does not contain the request, got:\n%s", contents)
+	}
+}
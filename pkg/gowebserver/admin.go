@@ -0,0 +1,368 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// adminAPIVersion is the path segment of the current admin API version.
+const adminAPIVersion = "/v1"
+
+// adminPrefix returns the path prefix of the admin API's current version.
+func adminPrefix(endpoint string) string {
+	return strings.TrimRight(endpoint, "/") + adminAPIVersion
+}
+
+// readAdminToken reads the bearer token of the admin API.
+func readAdminToken(tokenFile string) (string, error) {
+	data, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("cannot read admin token file '%s', %w", tokenFile, err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("admin token file '%s' is empty", tokenFile)
+	}
+	return token, nil
+}
+
+// adminHandler serves the JSON admin API of the listening server.
+type adminHandler struct {
+	server *webServerImpl
+	token  string
+	roles  []string
+	mux    *http.ServeMux
+}
+
+func newAdminHandler(server *webServerImpl, conf Admin) (*adminHandler, error) {
+	h := &adminHandler{server: server, roles: conf.Roles, mux: http.NewServeMux()}
+	if conf.TokenFile != "" {
+		token, err := readAdminToken(conf.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		h.token = token
+	}
+
+	prefix := adminPrefix(conf.Endpoint)
+	h.mux.HandleFunc("POST "+prefix+"/shutdown", h.serveShutdown)
+	h.mux.HandleFunc("POST "+prefix+"/reload", h.serveReload)
+	h.mux.HandleFunc("GET "+prefix+"/mounts", h.serveMounts)
+	h.mux.HandleFunc("GET "+prefix+"/connections", h.serveConnections)
+	h.mux.HandleFunc("GET "+prefix+"/loglevel", h.serveLogLevel)
+	h.mux.HandleFunc("PUT "+prefix+"/loglevel", h.serveSetLogLevel)
+	h.mux.HandleFunc("GET "+prefix+"/certificate", h.serveCertificate)
+	h.mux.HandleFunc("POST "+prefix+"/certificate/reload", h.serveCertificateReload)
+	h.mux.HandleFunc(prefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminError(w, http.StatusNotFound, fmt.Errorf("unknown admin API endpoint %s %s", r.Method, r.URL.Path))
+	})
+	return h, nil
+}
+
+// authorized reports the status code of the request's credentials, 200 if
+// the caller has the bearer token or one of the admin roles.
+func (h *adminHandler) authorized(r *http.Request) int {
+	if h.token != "" {
+		if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+			if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(h.token)) == 1 {
+				return http.StatusOK
+			}
+		}
+	}
+	id, ok := r.Context().Value(userContextKey{}).(*identity)
+	if !ok {
+		return http.StatusUnauthorized
+	}
+	roles := append([]string{roleAuthenticated}, id.roles...)
+	for _, role := range h.roles {
+		if slices.Contains(roles, role) {
+			return http.StatusOK
+		}
+	}
+	return http.StatusForbidden
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if status := h.authorized(r); status != http.StatusOK {
+		zap.S().With("client", requestClientIP(r), "method", r.Method, "path", r.URL.Path, "status", status).Warn("Rejecting admin API request")
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gowebserver admin"`)
+		}
+		writeAdminError(w, status, fmt.Errorf("%s", strings.ToLower(http.StatusText(status))))
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+type adminStatus struct {
+	Status string `json:"status"`
+}
+
+type adminErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeJSON responds with the JSON encoding of v.
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Malformed server response.", http.StatusInternalServerError)
+		zap.S().With("error", err).Warn("Cannot marshal admin JSON response")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		zap.S().With("error", err).Warn("cannot write admin response")
+	}
+}
+
+func writeAdminError(w http.ResponseWriter, statusCode int, err error) {
+	writeJSON(w, statusCode, adminErrorResponse{Error: err.Error()})
+}
+
+func (h *adminHandler) serveShutdown(w http.ResponseWriter, r *http.Request) {
+	zap.S().With("client", requestClientIP(r)).Info("Shutdown requested through the admin API")
+	writeJSON(w, http.StatusAccepted, adminStatus{Status: "shutting down"})
+	// The response is drained along with the other in-flight requests.
+	go h.server.killFunc()
+}
+
+func (h *adminHandler) serveReload(w http.ResponseWriter, r *http.Request) {
+	if h.server.configurationFile == "" {
+		writeAdminError(w, http.StatusConflict, fmt.Errorf("cannot reload, the server was not started with a configuration file"))
+		return
+	}
+	if err := h.server.reloadAndReport("admin"); err != nil {
+		writeAdminError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, adminStatus{Status: "reloaded"})
+}
+
+// adminMount describes a mount in the admin API.
+type adminMount struct {
+	Endpoint string   `json:"endpoint"`
+	Source   string   `json:"source,omitempty"`
+	Embedded bool     `json:"embedded,omitempty"`
+	Hosts    []string `json:"hosts,omitempty"`
+	Status   string   `json:"status"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// newAdminMount describes the mount, local directories that have gone away
+// since the mount was loaded are unavailable. Passwords in source URIs are
+// redacted.
+func newAdminMount(p servePath) adminMount {
+	m := adminMount{Endpoint: p.httpPath, Hosts: p.hosts, Status: "ok"}
+	if p.fsys != nil {
+		m.Embedded = true
+		return m
+	}
+	m.Source = p.localPath
+	if u, err := url.Parse(p.localPath); err == nil && u.Scheme != "" && u.Host != "" {
+		m.Source = u.Redacted()
+		return m
+	}
+	if _, err := os.Stat(p.localPath); err != nil {
+		m.Status = "unavailable"
+		m.Error = err.Error()
+	}
+	return m
+}
+
+func (h *adminHandler) serveMounts(w http.ResponseWriter, r *http.Request) {
+	h.server.RLock()
+	paths := h.server.fileSystemServePath
+	h.server.RUnlock()
+
+	mounts := []adminMount{}
+	for _, p := range paths {
+		mounts = append(mounts, newAdminMount(p))
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Mounts []adminMount `json:"mounts"`
+	}{Mounts: mounts})
+}
+
+// adminConnection is an open connection of the HTTP and HTTPS listeners.
+type adminConnection struct {
+	Listener   string    `json:"listener"`
+	RemoteAddr string    `json:"remoteAddr"`
+	State      string    `json:"state"`
+	Since      time.Time `json:"since"`
+}
+
+// adminTransfer is a request that is being served.
+type adminTransfer struct {
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	RemoteAddr string    `json:"remoteAddr"`
+	Start      time.Time `json:"start"`
+}
+
+func (h *adminHandler) serveConnections(w http.ResponseWriter, r *http.Request) {
+	transfers := []adminTransfer{}
+	for _, req := range h.server.requests.inflight() {
+		transfers = append(transfers, adminTransfer{Method: req.method, Path: req.path, RemoteAddr: req.remote, Start: req.start})
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Connections []adminConnection `json:"connections"`
+		Transfers   []adminTransfer   `json:"transfers"`
+	}{Connections: h.server.conns.list(), Transfers: transfers})
+}
+
+type adminLogLevel struct {
+	Level string `json:"level"`
+}
+
+func (h *adminHandler) serveLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, adminLogLevel{Level: logLevel.Level().String()})
+}
+
+func (h *adminHandler) serveSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	req := adminLogLevel{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("cannot parse log level request, %w", err))
+		return
+	}
+	level, err := zapcore.ParseLevel(req.Level)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+	logLevel.SetLevel(level)
+	zap.S().With("level", level, "client", requestClientIP(r)).Info("Log level changed through the admin API")
+	writeJSON(w, http.StatusOK, adminLogLevel{Level: level.String()})
+}
+
+// certificates returns the certificate store of the listening server, it
+// responds with an error if HTTPS is not serving a certificate.
+func (h *adminHandler) certificates(w http.ResponseWriter) *certificateStore {
+	h.server.RLock()
+	certs := h.server.certs
+	h.server.RUnlock()
+	if certs == nil {
+		writeAdminError(w, http.StatusConflict, fmt.Errorf("HTTPS is not serving a certificate"))
+	}
+	return certs
+}
+
+func (h *adminHandler) writeCertificate(w http.ResponseWriter, certs *certificateStore) {
+	cert, err := certs.leaf()
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, fmt.Errorf("cannot parse certificate, %w", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, newCertificateInfo(cert))
+}
+
+func (h *adminHandler) serveCertificate(w http.ResponseWriter, r *http.Request) {
+	if certs := h.certificates(w); certs != nil {
+		h.writeCertificate(w, certs)
+	}
+}
+
+func (h *adminHandler) serveCertificateReload(w http.ResponseWriter, r *http.Request) {
+	certs := h.certificates(w)
+	if certs == nil {
+		return
+	}
+	if err := certs.reload(); err != nil {
+		zap.S().With("error", err).Error("Certificate reload failed, keeping the previous certificate")
+		writeAdminError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	zap.S().With("certificate", certs.certificateFilePath).Info("Certificate reloaded")
+	h.writeCertificate(w, certs)
+}
+
+// adminListenerHandler restricts the listeners when the admin API has its own
+// addresses, the admin listeners only serve the API and the others do not
+// serve it.
+func (ws *webServerImpl) adminListenerHandler(h http.Handler, adminListener bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws.RLock()
+		endpoint := ws.admin.Endpoint
+		ws.RUnlock()
+		prefix := adminPrefix(endpoint)
+		isAdmin := endpoint != "" && (r.URL.Path == prefix || strings.HasPrefix(r.URL.Path, prefix+"/"))
+		if isAdmin != adminListener {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// connTracker keeps account of the open connections of the HTTP and HTTPS
+// listeners.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]adminConnection
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: map[net.Conn]adminConnection{}}
+}
+
+// connState returns the http.Server.ConnState hook of a listener.
+func (t *connTracker) connState(listener string) func(net.Conn, http.ConnState) {
+	return func(conn net.Conn, state http.ConnState) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		switch state {
+		case http.StateHijacked, http.StateClosed:
+			delete(t.conns, conn)
+		case http.StateNew:
+			t.conns[conn] = adminConnection{Listener: listener, RemoteAddr: conn.RemoteAddr().String(), State: state.String(), Since: time.Now()}
+		default:
+			c := t.conns[conn]
+			c.State = state.String()
+			t.conns[conn] = c
+		}
+	}
+}
+
+// list returns the open connections, oldest first.
+func (t *connTracker) list() []adminConnection {
+	t.mu.Lock()
+	result := make([]adminConnection, 0, len(t.conns))
+	for _, c := range t.conns {
+		result = append(result, c)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Since.Before(result[j].Since)
+	})
+	return result
+}
@@ -0,0 +1,690 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	permissionRead   = "read"
+	permissionUpload = "upload"
+	permissionDelete = "delete"
+	// permissionAdmin grants all the other permissions.
+	permissionAdmin = "admin"
+
+	// roleAnonymous is the role of every client, signed in or not.
+	roleAnonymous = "anonymous"
+	// roleAuthenticated is the role of every signed in user.
+	roleAuthenticated = "authenticated"
+
+	sessionCookieName = "gowebserver_session"
+	authRealm         = "gowebserver"
+
+	// verifiedPasswordTTL is how long a verified Basic auth password is
+	// remembered so that scripts do not pay for the slow hash on every request.
+	verifiedPasswordTTL  = 5 * time.Minute
+	maxVerifiedPasswords = 1024
+)
+
+var (
+	//go:embed login.html
+	loginHTML []byte
+
+	permissions = []string{permissionRead, permissionUpload, permissionDelete, permissionAdmin}
+
+	// sessionKey signs the session cookies. It lives as long as the process so
+	// that sessions survive configuration reloads.
+	sessionKey = sync.OnceValue(func() []byte {
+		key := make([]byte, 32)
+		rand.Read(key)
+		return key
+	})
+
+	// dummyPasswordHash is verified for unknown users so that they take as long
+	// to reject as wrong passwords.
+	dummyPasswordHash = sync.OnceValue(func() string {
+		hash, _ := bcrypt.GenerateFromPassword([]byte(rand.Text()), bcrypt.DefaultCost)
+		return string(hash)
+	})
+)
+
+// checkPasswordHash returns an error if the hash is not a bcrypt or argon2id
+// hash.
+func checkPasswordHash(hash string) error {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		_, err := parseArgon2id(hash)
+		return err
+	}
+	if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+		return fmt.Errorf("unsupported password hash, use bcrypt (htpasswd -B) or argon2id")
+	}
+	return nil
+}
+
+// verifyPassword reports whether the password matches the bcrypt or argon2id
+// hash.
+func verifyPassword(hash string, password string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, err := parseArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+		return subtle.ConstantTimeCompare(key, p.key) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// argon2idHash is a decoded argon2id hash in the PHC string format, e.g.
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>.
+type argon2idHash struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func parseArgon2id(hash string) (*argon2idHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, fmt.Errorf("malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2id version '%s'", parts[2])
+	}
+	h := &argon2idHash{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.time, &h.threads); err != nil {
+		return nil, fmt.Errorf("malformed argon2id parameters '%s', %w", parts[3], err)
+	}
+	if h.time < 1 || h.threads < 1 {
+		return nil, fmt.Errorf("argon2id time and parallelism must be at least 1")
+	}
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("malformed argon2id salt, %w", err)
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(h.key) == 0 {
+		return nil, fmt.Errorf("malformed argon2id key")
+	}
+	return h, nil
+}
+
+// readHtpasswd reads the users of an htpasswd file and gives them the roles.
+func readHtpasswd(name string, roles []string) ([]User, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read htpasswd file '%s', %w", name, err)
+	}
+	defer f.Close()
+
+	users := []User{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		userName, hash, ok := strings.Cut(text, ":")
+		if !ok || userName == "" {
+			return nil, fmt.Errorf("malformed line %d of htpasswd file '%s'", line, name)
+		}
+		if err := checkPasswordHash(hash); err != nil {
+			return nil, fmt.Errorf("user '%s' on line %d of htpasswd file '%s' has an invalid password hash, %w", userName, line, name, err)
+		}
+		users = append(users, User{Name: userName, PasswordHash: hash, Roles: roles})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read htpasswd file '%s', %w", name, err)
+	}
+	return users, nil
+}
+
+// authUsers returns the users of the configuration and its htpasswd file.
+func (a Auth) authUsers() ([]User, error) {
+	users := slices.Clone(a.Users)
+	if a.HtpasswdFile != "" {
+		fileUsers, err := readHtpasswd(a.HtpasswdFile, a.HtpasswdRoles)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, fileUsers...)
+	}
+	return users, nil
+}
+
+// identity is the authenticated user of a request.
+type identity struct {
+	name  string
+	roles []string
+	// source is how the user signed in, one of the identitySource constants.
+	source string
+}
+
+const (
+	identitySourcePassword = "password"
+	identitySourceJWT      = "jwt"
+	identitySourceOIDC     = "oidc"
+)
+
+// authenticator signs users in with HTTP Basic auth, bearer tokens or a
+// session cookie and checks their permissions on the mounts. A nil
+// authenticator has no users so every request is anonymous.
+type authenticator struct {
+	users          map[string]User
+	loginPath      string
+	sessionTimeout time.Duration
+	tmpl           *template.Template
+	now            func() time.Time
+
+	jwt    JWT
+	claims Claims
+	// jwks verifies bearer tokens, nil if they are not accepted.
+	jwks *keySet
+	// oidc signs browsers in with single sign-on, nil if it is not configured.
+	oidc *oidcProvider
+	// clientAuth names the users of verified client certificates.
+	clientAuth ClientAuth
+
+	mu       sync.Mutex
+	verified map[[sha256.Size]byte]time.Time
+}
+
+// newAuthenticator returns nil if there are no users and neither bearer
+// tokens, OpenID Connect nor client certificates are configured.
+func newAuthenticator(conf Auth, clientAuth ClientAuth) (*authenticator, error) {
+	users, err := conf.authUsers()
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 && conf.JWT.JWKS == "" && conf.OIDC.Issuer == "" && !clientAuth.enabled() {
+		return nil, nil
+	}
+	tmpl, err := createTemplate(loginHTML)
+	if err != nil {
+		return nil, err
+	}
+	a := &authenticator{
+		users:          map[string]User{},
+		loginPath:      conf.LoginEndpoint,
+		sessionTimeout: conf.SessionTimeout,
+		tmpl:           tmpl,
+		now:            time.Now,
+		jwt:            conf.JWT,
+		claims:         conf.Claims,
+		clientAuth:     clientAuth,
+		verified:       map[[sha256.Size]byte]time.Time{},
+	}
+	if a.loginPath == "" {
+		a.loginPath = "/login"
+	}
+	if a.sessionTimeout <= 0 {
+		a.sessionTimeout = 24 * time.Hour
+	}
+	for _, u := range users {
+		if _, ok := a.users[u.Name]; ok {
+			return nil, fmt.Errorf("user '%s' is defined more than once", u.Name)
+		}
+		a.users[u.Name] = u
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	if conf.JWT.JWKS != "" {
+		if a.jwks, err = newKeySet(conf.JWT.JWKS, client); err != nil {
+			return nil, err
+		}
+	}
+	if conf.OIDC.Issuer != "" {
+		a.oidc = newOIDCProvider(conf.OIDC, a.loginPath, client)
+	}
+	return a, nil
+}
+
+// checkPassword returns the user if the password is correct.
+func (a *authenticator) checkPassword(name string, password string) (User, bool) {
+	u, ok := a.users[name]
+	hash := u.PasswordHash
+	if !ok {
+		hash = dummyPasswordHash()
+	}
+	cacheKey := sha256.Sum256([]byte(name + "\x00" + hash + "\x00" + password))
+	now := a.now()
+
+	a.mu.Lock()
+	expires, verified := a.verified[cacheKey]
+	a.mu.Unlock()
+	if ok && verified && now.Before(expires) {
+		return u, true
+	}
+	if !verifyPassword(hash, password) || !ok {
+		return User{}, false
+	}
+
+	a.mu.Lock()
+	if len(a.verified) >= maxVerifiedPasswords {
+		clear(a.verified)
+	}
+	a.verified[cacheKey] = now.Add(verifiedPasswordTTL)
+	a.mu.Unlock()
+	return u, true
+}
+
+// sessionPayload is the signed content of a session cookie.
+type sessionPayload struct {
+	Name   string `json:"n"`
+	Source string `json:"s"`
+	// Roles are only stored for single sign-on users, the roles of local users
+	// come from the configuration.
+	Roles   []string `json:"r,omitempty"`
+	Expires int64    `json:"e"`
+}
+
+// newSession returns the session cookie value of the user. It is signed and
+// the sessions of local users are tied to their password hash so that changing
+// the password ends them.
+func (a *authenticator) newSession(id *identity, expires time.Time) string {
+	p := sessionPayload{Name: id.name, Source: id.source, Expires: expires.Unix()}
+	if id.source != identitySourcePassword {
+		p.Roles = id.roles
+	}
+	payload, _ := json.Marshal(p)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(a.sessionMAC(payload, p))
+}
+
+func (a *authenticator) sessionMAC(payload []byte, p sessionPayload) []byte {
+	mac := hmac.New(sha256.New, sessionKey())
+	mac.Write(payload)
+	if p.Source == identitySourcePassword {
+		io.WriteString(mac, "\x00"+a.users[p.Name].PasswordHash)
+	}
+	return mac.Sum(nil)
+}
+
+// verifySession returns the user of a valid, unexpired session cookie value.
+func (a *authenticator) verifySession(value string) (*identity, bool) {
+	encodedPayload, encodedMAC, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return nil, false
+	}
+	p := sessionPayload{}
+	if err := json.Unmarshal(payload, &p); err != nil || p.Name == "" {
+		return nil, false
+	}
+	if !a.now().Before(time.Unix(p.Expires, 0)) {
+		return nil, false
+	}
+	switch p.Source {
+	case identitySourcePassword:
+		u, ok := a.users[p.Name]
+		if !ok || !hmac.Equal(mac, a.sessionMAC(payload, p)) {
+			return nil, false
+		}
+		return &identity{name: u.Name, roles: u.Roles, source: p.Source}, true
+	case identitySourceOIDC:
+		if a.oidc == nil || !hmac.Equal(mac, a.sessionMAC(payload, p)) {
+			return nil, false
+		}
+		return &identity{name: p.Name, roles: p.Roles, source: p.Source}, true
+	}
+	return nil, false
+}
+
+// bearerToken returns the token of an Authorization: Bearer header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// verifyBearer returns the user of a valid bearer token.
+func (a *authenticator) verifyBearer(r *http.Request, token string) (*identity, error) {
+	claims, err := verifyJWT(r.Context(), token, a.jwks)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkClaims(claims, a.jwt.Issuer, a.jwt.Audience, a.now()); err != nil {
+		return nil, err
+	}
+	return a.claims.identity(claims, identitySourceJWT)
+}
+
+// authenticate returns the user signed in with Basic auth, a bearer token, a
+// session cookie or a client certificate. Requests with wrong credentials are
+// anonymous.
+func (a *authenticator) authenticate(r *http.Request) (*identity, bool) {
+	if name, password, ok := r.BasicAuth(); ok {
+		u, ok := a.checkPassword(name, password)
+		if !ok {
+			return nil, false
+		}
+		return &identity{name: u.Name, roles: u.Roles, source: identitySourcePassword}, true
+	}
+	if token, ok := bearerToken(r); ok && a.jwks != nil {
+		id, err := a.verifyBearer(r, token)
+		if err != nil {
+			zap.S().With("error", err, "remoteAddr", r.RemoteAddr).Debug("Rejected bearer token")
+			return nil, false
+		}
+		return id, true
+	}
+	if c, err := r.Cookie(sessionCookieName); err == nil {
+		if id, ok := a.verifySession(c.Value); ok {
+			return id, true
+		}
+	}
+	if cert, ok := clientCertificate(r); ok && a.clientAuth.enabled() {
+		return a.certificateIdentity(cert)
+	}
+	return nil, false
+}
+
+// certificateIdentity returns the user named by a verified client
+// certificate with the roles that ClientAuth grants it.
+func (a *authenticator) certificateIdentity(cert *x509.Certificate) (*identity, bool) {
+	name := a.clientAuth.certificateName(cert)
+	if name == "" {
+		return nil, false
+	}
+	roles := slices.Concat(a.clientAuth.Roles, a.clientAuth.Users[name])
+	slices.Sort(roles)
+	return &identity{name: name, roles: slices.Compact(roles), source: identitySourceCertificate}, true
+}
+
+// handler records the signed in user of the requests in their context and
+// access log entry, h is returned as is if the authenticator is nil.
+func (a *authenticator) handler(h http.Handler) http.Handler {
+	if a == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := a.authenticate(r); ok {
+			if entry, ok := r.Context().Value(accessLogEntryKey{}).(*accessLogEntry); ok {
+				entry.user = id.name
+			}
+			r = r.WithContext(context.WithValue(r.Context(), userContextKey{}, id))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// roles returns the roles of the request's user, including the built-in ones.
+func (a *authenticator) roles(r *http.Request) []string {
+	roles := []string{roleAnonymous}
+	if id, ok := r.Context().Value(userContextKey{}).(*identity); ok {
+		roles = append(roles, roleAuthenticated)
+		roles = append(roles, id.roles...)
+	}
+	return roles
+}
+
+// allowed reports whether the access rules grant the permission to the
+// request's user.
+func (a *authenticator) allowed(r *http.Request, access map[string][]string, permission string) bool {
+	for _, role := range a.roles(r) {
+		for _, p := range access[role] {
+			if p == permission || p == permissionAdmin {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// methodPermission returns the permission that the request's method needs on
+// a mount.
+func methodPermission(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return permissionRead
+	case http.MethodDelete:
+		return permissionDelete
+	}
+	return permissionUpload
+}
+
+// uploadPermission is the permission that all requests to the upload
+// endpoint need.
+func uploadPermission(*http.Request) string {
+	return permissionUpload
+}
+
+// accessHandler only lets the requests whose user has the permission on the
+// mount through to next, next is returned as is if the mount has no access
+// rules.
+func (a *authenticator) accessHandler(next http.Handler, access map[string][]string, permission func(*http.Request) string) http.Handler {
+	if len(access) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Preflight requests never carry credentials, the CORS policy answers
+		// them.
+		isPreflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if isPreflight || a.allowed(r, access, permission(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if requestUser(r) != "" {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		a.challenge(w, r)
+	})
+}
+
+// challenge asks an anonymous client to sign in, browsers are redirected to
+// the login page or, without local users, straight to single sign-on.
+func (a *authenticator) challenge(w http.ResponseWriter, r *http.Request) {
+	if a != nil && r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		target := a.loginPath
+		if a.oidc != nil && len(a.users) == 0 {
+			target = a.oidc.startPath
+		}
+		http.Redirect(w, r, target+"?next="+url.QueryEscape(r.RequestURI), http.StatusSeeOther)
+		return
+	}
+	if a == nil || len(a.users) > 0 || a.jwks == nil {
+		w.Header().Add("WWW-Authenticate", `Basic realm="`+authRealm+`", charset="UTF-8"`)
+	}
+	if a != nil && a.jwks != nil {
+		bearer := `Bearer realm="` + authRealm + `"`
+		if _, ok := bearerToken(r); ok {
+			bearer += `, error="invalid_token"`
+		}
+		w.Header().Add("WWW-Authenticate", bearer)
+	}
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// listingFilterKey is the context key of the function that reports whether a
+// directory listing shows the entry at a URL path.
+type listingFilterKey struct{}
+
+// listingHandler hides the mounts, keyed by their HTTP path, that the user
+// cannot read from the directory listings of h.
+func (a *authenticator) listingHandler(h http.Handler, restricted map[string]map[string][]string) http.Handler {
+	if len(restricted) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listed := func(urlPath string) bool {
+			access, ok := restricted[normalizeHTTPPath(urlPath)]
+			return !ok || a.allowed(r, access, permissionRead)
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), listingFilterKey{}, listed)))
+	})
+}
+
+// isListed reports whether the directory listing of the request shows the
+// entry at the URL path.
+func isListed(r *http.Request, urlPath string) bool {
+	listed, ok := r.Context().Value(listingFilterKey{}).(func(string) bool)
+	return !ok || listed(urlPath)
+}
+
+type loginPage struct {
+	User  string
+	Next  string
+	Error string
+	// Password shows the username and password form.
+	Password bool
+	// SSO is the URL that starts single sign-on, empty if it is not
+	// configured.
+	SSO string
+}
+
+func (a *authenticator) newLoginPage(r *http.Request, next string) loginPage {
+	page := loginPage{User: requestUser(r), Next: next, Password: len(a.users) > 0}
+	if a.oidc != nil {
+		page.SSO = a.oidc.startPath + "?next=" + url.QueryEscape(next)
+	}
+	return page
+}
+
+// serveLogin serves the login page. A successful sign in sets the session
+// cookie and redirects to the page that asked for it.
+func (a *authenticator) serveLogin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		a.renderLogin(w, http.StatusOK, a.newLoginPage(r, safeRedirect(r.URL.Query().Get("next"))))
+	case http.MethodPost:
+		// The login form is only accepted from the server's own pages.
+		if !isSameOrigin(r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("logout") != "" {
+			cookie := sessionCookie(r)
+			cookie.MaxAge = -1
+			http.SetCookie(w, cookie)
+			http.Redirect(w, r, a.loginPath, http.StatusSeeOther)
+			return
+		}
+
+		next := safeRedirect(r.PostForm.Get("next"))
+		name := r.PostForm.Get("username")
+		u, ok := a.checkPassword(name, r.PostForm.Get("password"))
+		if !ok {
+			zap.S().With("user", name, "remoteAddr", r.RemoteAddr).Info("Failed sign in")
+			page := a.newLoginPage(r, next)
+			page.Error = "Invalid username or password."
+			a.renderLogin(w, http.StatusUnauthorized, page)
+			return
+		}
+		a.signIn(w, r, &identity{name: u.Name, roles: u.Roles, source: identitySourcePassword}, next)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// signIn sets the session cookie of the user and redirects to next.
+func (a *authenticator) signIn(w http.ResponseWriter, r *http.Request, id *identity, next string) {
+	expires := a.now().Add(a.sessionTimeout)
+	cookie := sessionCookie(r)
+	cookie.Value = a.newSession(id, expires)
+	cookie.Expires = expires
+	http.SetCookie(w, cookie)
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func sessionCookie(r *http.Request) *http.Cookie {
+	return &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (a *authenticator) renderLogin(w http.ResponseWriter, statusCode int, page loginPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	setSecureHeaders(w.Header())
+	w.WriteHeader(statusCode)
+	if err := a.tmpl.Execute(w, page); err != nil {
+		zap.S().With("error", err).Warn("cannot execute login.html template")
+	}
+}
+
+// safeRedirect returns the local path to redirect to after signing in, other
+// sites are not allowed.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.Contains(next, "\\") {
+		return "/"
+	}
+	return next
+}
+
+// runHashPassword reads a password from in, prints its bcrypt hash for
+// auth.users and returns the process exit code.
+func runHashPassword(in io.Reader, w io.Writer) int {
+	password, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		fmt.Fprintf(w, "cannot read password, %s\n", err)
+		return 1
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		fmt.Fprintln(w, "cannot hash an empty password")
+		return 1
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		fmt.Fprintf(w, "cannot hash password, %s\n", err)
+		return 1
+	}
+	fmt.Fprintln(w, string(hash))
+	return 0
+}
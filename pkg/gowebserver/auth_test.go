@@ -0,0 +1,459 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func mustHashPassword(tb testing.TB, password string) string {
+	tb.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return string(hash)
+}
+
+func argon2idTestHash(password string) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(password), salt, 1, 64, 1, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=64,t=1,p=1$%s$%s", argon2.Version, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestVerifyPassword(t *testing.T) {
+	bcryptHash := mustHashPassword(t, "secret")
+	argon2idHash := argon2idTestHash("secret")
+
+	testCases := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+	}{
+		{name: "bcrypt", hash: bcryptHash, password: "secret", want: true},
+		{name: "bcrypt wrong password", hash: bcryptHash, password: "wrong"},
+		{name: "argon2id", hash: argon2idHash, password: "secret", want: true},
+		{name: "argon2id wrong password", hash: argon2idHash, password: "wrong"},
+		{name: "malformed argon2id", hash: "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5", password: "secret"},
+		{name: "plain text", hash: "secret", password: "secret"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := verifyPassword(tc.hash, tc.password); got != tc.want {
+				t.Errorf("verifyPassword(%q, %q) got %t, want %t", tc.hash, tc.password, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordHash(t *testing.T) {
+	testCases := []struct {
+		hash    string
+		wantErr bool
+	}{
+		{hash: mustHashPassword(t, "secret")},
+		{hash: argon2idTestHash("secret")},
+		{hash: "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5", wantErr: true},
+		{hash: "$argon2id$v=19$m=64$c2FsdA$a2V5", wantErr: true},
+		{hash: "$apr1$salt$hash", wantErr: true},
+		{hash: "{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=", wantErr: true},
+		{hash: "", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		if err := checkPasswordHash(tc.hash); (err != nil) != tc.wantErr {
+			t.Errorf("checkPasswordHash(%q) got error %v, want error %t", tc.hash, err, tc.wantErr)
+		}
+	}
+}
+
+func TestReadHtpasswd(t *testing.T) {
+	dir := mustTempDir(t)
+	hash := mustHashPassword(t, "secret")
+	valid := filepath.Join(dir, "valid")
+	if err := os.WriteFile(valid, []byte("# users\nfrank:"+hash+"\n\nalice:"+hash+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	md5 := filepath.Join(dir, "md5")
+	if err := os.WriteFile(md5, []byte("frank:$apr1$salt$hash\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readHtpasswd(valid, []string{"staff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []User{
+		{Name: "frank", PasswordHash: hash, Roles: []string{"staff"}},
+		{Name: "alice", PasswordHash: hash, Roles: []string{"staff"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("users mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := readHtpasswd(md5, nil); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("want an unsupported hash error on line 1, got %v", err)
+	}
+	if _, err := readHtpasswd(filepath.Join(dir, "missing"), nil); err == nil {
+		t.Error("want an error for a missing file")
+	}
+}
+
+func mustNewAuthenticator(tb testing.TB, users ...User) *authenticator {
+	tb.Helper()
+	a, err := newAuthenticator(Auth{Users: users}, ClientAuth{})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return a
+}
+
+func TestAuthenticator_Session(t *testing.T) {
+	frank := User{Name: "frank", PasswordHash: mustHashPassword(t, "secret"), Roles: []string{"staff"}}
+	a := mustNewAuthenticator(t, frank)
+	clock := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return clock }
+	// The roles of local users come from the configuration, not the cookie.
+	session := a.newSession(&identity{name: "frank", roles: []string{"admins"}, source: identitySourcePassword}, clock.Add(time.Hour))
+
+	if id, ok := a.verifySession(session); !ok || id.name != "frank" || !slices.Equal(id.roles, []string{"staff"}) {
+		t.Errorf("verifySession() got %v %t, want frank with role staff", id, ok)
+	}
+	if _, ok := a.verifySession(strings.Replace(session, ".", ".1", 1)); ok {
+		t.Error("want a tampered session rejected")
+	}
+	// Single sign-on sessions are only accepted when single sign-on is
+	// configured.
+	if _, ok := a.verifySession(a.newSession(&identity{name: "frank", source: identitySourceOIDC}, clock.Add(time.Hour))); ok {
+		t.Error("want a single sign-on session rejected without OpenID Connect")
+	}
+
+	// Changing the password ends the sessions.
+	changed := mustNewAuthenticator(t, User{Name: "frank", PasswordHash: mustHashPassword(t, "changed")})
+	changed.now = a.now
+	if _, ok := changed.verifySession(session); ok {
+		t.Error("want the session rejected after a password change")
+	}
+
+	clock = clock.Add(2 * time.Hour)
+	if _, ok := a.verifySession(session); ok {
+		t.Error("want an expired session rejected")
+	}
+}
+
+func TestAuthenticator_CheckPassword(t *testing.T) {
+	a := mustNewAuthenticator(t, User{Name: "frank", PasswordHash: mustHashPassword(t, "secret")})
+
+	if _, ok := a.checkPassword("frank", "secret"); !ok {
+		t.Error("want the correct password accepted")
+	}
+	// The second check is answered from the cache.
+	if _, ok := a.checkPassword("frank", "secret"); !ok {
+		t.Error("want the cached password accepted")
+	}
+	if len(a.verified) != 1 {
+		t.Errorf("want 1 cached password, got %d", len(a.verified))
+	}
+	if _, ok := a.checkPassword("frank", "wrong"); ok {
+		t.Error("want a wrong password rejected")
+	}
+	if _, ok := a.checkPassword("nobody", "secret"); ok {
+		t.Error("want an unknown user rejected")
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	a, err := newAuthenticator(Auth{}, ClientAuth{})
+	if err != nil || a != nil {
+		t.Errorf("newAuthenticator() without users got %v %v, want nil", a, err)
+	}
+
+	hash := mustHashPassword(t, "secret")
+	if _, err := newAuthenticator(Auth{Users: []User{{Name: "frank", PasswordHash: hash}, {Name: "frank", PasswordHash: hash}}}, ClientAuth{}); err == nil {
+		t.Error("want an error for duplicate users")
+	}
+}
+
+func TestAuthenticator_AccessHandler(t *testing.T) {
+	hash := mustHashPassword(t, "secret")
+	a := mustNewAuthenticator(t,
+		User{Name: "frank", PasswordHash: hash, Roles: []string{"editors"}},
+		User{Name: "alice", PasswordHash: hash, Roles: []string{"admins"}},
+		User{Name: "bob", PasswordHash: hash},
+	)
+	access := map[string][]string{
+		roleAuthenticated: {permissionRead},
+		"editors":         {permissionRead, permissionUpload},
+		"admins":          {permissionAdmin},
+	}
+	h := a.handler(a.accessHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), access, methodPermission))
+
+	testCases := []struct {
+		name       string
+		method     string
+		user       string
+		password   string
+		accept     string
+		header     http.Header
+		wantStatus int
+		wantHeader string
+	}{
+		{name: "anonymous", method: http.MethodGet, wantStatus: http.StatusUnauthorized, wantHeader: `Basic realm="gowebserver", charset="UTF-8"`},
+		{name: "anonymous browser", method: http.MethodGet, accept: "text/html,*/*", wantStatus: http.StatusSeeOther, wantHeader: "/login?next=%2Fprivate%2Fa.txt"},
+		{name: "wrong password", method: http.MethodGet, user: "frank", password: "wrong", wantStatus: http.StatusUnauthorized},
+		{name: "read", method: http.MethodGet, user: "bob", password: "secret", wantStatus: http.StatusOK},
+		{name: "upload denied", method: http.MethodPost, user: "bob", password: "secret", wantStatus: http.StatusForbidden},
+		{name: "upload", method: http.MethodPut, user: "frank", password: "secret", wantStatus: http.StatusOK},
+		{name: "delete denied", method: http.MethodDelete, user: "frank", password: "secret", wantStatus: http.StatusForbidden},
+		{name: "admin", method: http.MethodDelete, user: "alice", password: "secret", wantStatus: http.StatusOK},
+		{name: "preflight", method: http.MethodOptions, header: http.Header{"Access-Control-Request-Method": {"PUT"}}, wantStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/private/a.txt", nil)
+			if tc.user != "" {
+				r.SetBasicAuth(tc.user, tc.password)
+			}
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			for k, v := range tc.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tc.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tc.wantStatus)
+			}
+			got := w.Header().Get("WWW-Authenticate") + w.Header().Get("Location")
+			if tc.wantHeader != "" && got != tc.wantHeader {
+				t.Errorf("got header %q, want %q", got, tc.wantHeader)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_AccessHandlerWithoutUsers(t *testing.T) {
+	var a *authenticator
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := a.accessHandler(next, map[string][]string{roleAnonymous: {permissionRead}}, methodPermission)
+
+	for method, want := range map[string]int{http.MethodGet: http.StatusOK, http.MethodPost: http.StatusUnauthorized} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/", nil))
+		if w.Code != want {
+			t.Errorf("%s got status %d, want %d", method, w.Code, want)
+		}
+	}
+}
+
+func TestIsListed(t *testing.T) {
+	a := mustNewAuthenticator(t, User{Name: "frank", PasswordHash: mustHashPassword(t, "secret"), Roles: []string{"staff"}})
+	restricted := map[string]map[string][]string{"/private/": {"staff": {permissionRead}}}
+
+	got := map[string][]string{}
+	h := a.listingHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listed := []string{}
+		for _, p := range []string{"/public", "/private", "//private"} {
+			if isListed(r, p) {
+				listed = append(listed, p)
+			}
+		}
+		got[requestUser(r)] = listed
+	}), restricted)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	h.ServeHTTP(httptest.NewRecorder(), r.WithContext(context.WithValue(r.Context(), userContextKey{}, &identity{name: "frank", roles: []string{"staff"}})))
+
+	want := map[string][]string{
+		"":      {"/public"},
+		"frank": {"/public", "/private", "//private"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("listed mismatch (-want +got):\n%s", diff)
+	}
+	if !isListed(r, "/private") {
+		t.Error("want everything listed without a listing filter")
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	testCases := map[string]string{
+		"":                    "/",
+		"/private/?sort=name": "/private/?sort=name",
+		"//evil.example.com":  "/",
+		"/\\evil.example.com": "/",
+		"https://example.com": "/",
+	}
+	for next, want := range testCases {
+		if got := safeRedirect(next); got != want {
+			t.Errorf("safeRedirect(%q) got %q, want %q", next, got, want)
+		}
+	}
+}
+
+func TestRunHashPassword(t *testing.T) {
+	out := &bytes.Buffer{}
+	if code := runHashPassword(strings.NewReader("secret\n"), out); code != 0 {
+		t.Fatalf("runHashPassword() got exit code %d, output %q", code, out.String())
+	}
+	if !verifyPassword(strings.TrimSpace(out.String()), "secret") {
+		t.Errorf("got hash %q, want a hash of the password", out.String())
+	}
+	if code := runHashPassword(strings.NewReader(""), &bytes.Buffer{}); code == 0 {
+		t.Error("want a non-zero exit code for an empty password")
+	}
+}
+
+func TestWebServer_Auth(t *testing.T) {
+	dir := mustTempDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	baseURL, close := serveAsync(t, &Config{
+		Serve: []Serve{
+			{Source: dir, Endpoint: "/public"},
+			{Source: dir, Endpoint: "/private", Access: map[string][]string{"staff": {permissionRead}}},
+		},
+		EnhancedList: true,
+		Auth: Auth{
+			Users: []User{
+				{Name: "frank", PasswordHash: mustHashPassword(t, "secret"), Roles: []string{"staff"}},
+				{Name: "bob", PasswordHash: mustHashPassword(t, "secret")},
+			},
+			LoginEndpoint:  "/login",
+			SessionTimeout: time.Hour,
+		},
+	})
+	defer close()
+
+	status := func(client *http.Client, user string, p string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, baseURL+p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user != "" {
+			req.SetBasicAuth(user, "secret")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	got := []int{
+		status(http.DefaultClient, "", "/public/a.txt"),
+		status(http.DefaultClient, "", "/private/a.txt"),
+		status(http.DefaultClient, "bob", "/private/a.txt"),
+		status(http.DefaultClient, "frank", "/private/a.txt"),
+	}
+	want := []int{http.StatusOK, http.StatusUnauthorized, http.StatusForbidden, http.StatusOK}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Basic auth status codes mismatch (-want +got):\n%s", diff)
+	}
+
+	if body := mustGetBody(t, baseURL+"/"); strings.Contains(body, "private") || !strings.Contains(body, "public") {
+		t.Errorf("want only the public mount listed for anonymous users, got\n%s", body)
+	}
+
+	// Browsers sign in with the login page.
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	browser := &http.Client{Jar: jar}
+	resp, err := browser.PostForm(baseURL+"/login", url.Values{"username": {"frank"}, "password": {"secret"}, "next": {"/private/a.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Request.URL.Path != "/private/a.txt" {
+		t.Errorf("sign in got %d at %s, want %d at /private/a.txt", resp.StatusCode, resp.Request.URL.Path, http.StatusOK)
+	}
+	if got := status(browser, "", "/private/a.txt"); got != http.StatusOK {
+		t.Errorf("signed in request got %d, want %d", got, http.StatusOK)
+	}
+
+	resp, err = browser.PostForm(baseURL+"/login", url.Values{"username": {"frank"}, "password": {"wrong"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong password got %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	resp, err = browser.PostForm(baseURL+"/login", url.Values{"logout": {"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := status(browser, "", "/private/a.txt"); got != http.StatusUnauthorized {
+		t.Errorf("signed out request got %d, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+func TestCustomIndex_HidesRestrictedMounts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt":              {Data: []byte("a")},
+		"secret-mount/b.txt": {Data: []byte("b")},
+	}
+	var mc *monitoringContext
+	index, err := newCustomIndex(http.FileServer(http.FS(fsys)), fsys, mc.getTraceProvider(), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := mustNewAuthenticator(t, User{Name: "frank", PasswordHash: mustHashPassword(t, "secret")})
+	h := a.handler(a.listingHandler(index, map[string]map[string][]string{"/secret-mount/": {roleAuthenticated: {permissionRead}}}))
+
+	anonymous := httptest.NewRecorder()
+	h.ServeHTTP(anonymous, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := anonymous.Body.String(); strings.Contains(body, "secret-mount") || !strings.Contains(body, "a.txt") {
+		t.Errorf("want the restricted mount hidden from anonymous users, got\n%s", body)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth("frank", "secret")
+	frank := httptest.NewRecorder()
+	h.ServeHTTP(frank, r)
+	if !strings.Contains(frank.Body.String(), "secret-mount") {
+		t.Errorf("want the restricted mount listed for frank, got\n%s", frank.Body)
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	bandwidthDownload = "download"
+	bandwidthUpload   = "upload"
+
+	// maxThrottledChunkBytes is the largest write or read that is throttled at
+	// once, smaller chunks keep the transfer rate smooth.
+	maxThrottledChunkBytes = 32 << 10
+)
+
+// byteBucket is a token bucket of bytes. Reservations may take more tokens
+// than available, the caller then waits for the debt to be repaid.
+type byteBucket struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newByteBucket(bytesPerSecond int64) *byteBucket {
+	burst := float64(chunkBytes(bytesPerSecond))
+	return &byteBucket{
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		now:    time.Now,
+		tokens: burst,
+	}
+}
+
+// reserve takes n bytes from the bucket and returns how long to wait before
+// transferring them.
+func (b *byteBucket) reserve(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// chunkBytes returns how many bytes are transferred at once at the rate.
+func chunkBytes(bytesPerSecond int64) int {
+	return int(max(1, min(bytesPerSecond, maxThrottledChunkBytes)))
+}
+
+// connectionBucket is the bucket of a client connection, it is shared by the
+// concurrent requests of the connection.
+type connectionBucket struct {
+	bucket *byteBucket
+	refs   int
+}
+
+// bandwidthLimiter limits the total transfer rate and the rate of each client
+// connection in one direction, and measures the throughput.
+type bandwidthLimiter struct {
+	direction     string
+	total         *byteBucket
+	perConnection int64
+	transferred   metric.Int64Counter
+
+	mu          sync.Mutex
+	connections map[string]*connectionBucket
+	bytes       int64
+	lastBytes   int64
+	lastObserve time.Time
+}
+
+// newBandwidthLimiter returns nil if the limit is not set. The returned function
+// stops reporting the throughput metric.
+func newBandwidthLimiter(direction string, limit BandwidthLimit, mc *monitoringContext) (*bandwidthLimiter, func() error, error) {
+	if limit.BytesPerSecond <= 0 && limit.PerConnectionBytesPerSecond <= 0 {
+		return nil, nilFuncWithError, nil
+	}
+	l := &bandwidthLimiter{
+		direction:     direction,
+		perConnection: limit.PerConnectionBytesPerSecond,
+		connections:   map[string]*connectionBucket{},
+		lastObserve:   time.Now(),
+	}
+	if limit.BytesPerSecond > 0 {
+		l.total = newByteBucket(limit.BytesPerSecond)
+	}
+
+	m := mc.getMeterProvider().Meter("gowebserver")
+	transferred, err := m.Int64Counter("transferred_bytes_total", metric.WithDescription("Number of body bytes transferred through the bandwidth limits."), metric.WithUnit("bytes"))
+	if err != nil {
+		return nil, nilFuncWithError, err
+	}
+	l.transferred = transferred
+	throughput, err := m.Float64ObservableGauge("throughput_bytes_per_second", metric.WithDescription("Body bytes transferred per second through the bandwidth limits since the previous collection."), metric.WithUnit("bytes"))
+	if err != nil {
+		return nil, nilFuncWithError, err
+	}
+	attrs := metric.WithAttributes(attribute.String("direction", direction))
+	registration, err := m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveFloat64(throughput, l.throughput(), attrs)
+		return nil
+	}, throughput)
+	if err != nil {
+		return nil, nilFuncWithError, err
+	}
+	return l, registration.Unregister, nil
+}
+
+// throughput returns the bytes per second since the previous call.
+func (l *bandwidthLimiter) throughput() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	elapsed := now.Sub(l.lastObserve).Seconds()
+	bytes := l.bytes - l.lastBytes
+	l.lastBytes = l.bytes
+	l.lastObserve = now
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(bytes) / elapsed
+}
+
+// acquire returns the buckets that limit the transfers of the connection, it
+// must be released once the transfer is done.
+func (l *bandwidthLimiter) acquire(conn string) []*byteBucket {
+	buckets := []*byteBucket{}
+	if l.total != nil {
+		buckets = append(buckets, l.total)
+	}
+	if l.perConnection <= 0 {
+		return buckets
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.connections[conn]
+	if !ok {
+		c = &connectionBucket{bucket: newByteBucket(l.perConnection)}
+		l.connections[conn] = c
+	}
+	c.refs++
+	return append(buckets, c.bucket)
+}
+
+func (l *bandwidthLimiter) release(conn string) {
+	if l.perConnection <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.connections[conn]; ok {
+		c.refs--
+		if c.refs <= 0 {
+			delete(l.connections, conn)
+		}
+	}
+}
+
+// throttle calls transfer with chunks of p, waiting between them so that the
+// buckets' rates are not exceeded.
+func (l *bandwidthLimiter) throttle(ctx context.Context, buckets []*byteBucket, p []byte, transfer func([]byte) (int, error)) (int, error) {
+	chunk := len(p)
+	for _, b := range buckets {
+		chunk = min(chunk, int(b.burst))
+	}
+	total := 0
+	for len(p) > 0 {
+		n := min(len(p), chunk)
+		wait := time.Duration(0)
+		for _, b := range buckets {
+			wait = max(wait, b.reserve(n))
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return total, err
+		}
+		written, err := transfer(p[:n])
+		total += written
+		l.record(ctx, written)
+		if err != nil {
+			return total, err
+		}
+		if written < n {
+			return total, nil
+		}
+		p = p[n:]
+	}
+	return total, nil
+}
+
+func (l *bandwidthLimiter) record(ctx context.Context, n int) {
+	if n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	l.bytes += int64(n)
+	l.mu.Unlock()
+	l.transferred.Add(ctx, int64(n), metric.WithAttributes(attribute.String("direction", l.direction)))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// responseHandler throttles the response bodies of h, h is returned as is if
+// the limiter is nil.
+func (l *bandwidthLimiter) responseHandler(h http.Handler) http.Handler {
+	if l == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buckets := l.acquire(r.RemoteAddr)
+		defer l.release(r.RemoteAddr)
+		h.ServeHTTP(&throttledResponseWriter{ResponseWriter: w, ctx: r.Context(), limiter: l, buckets: buckets}, r)
+	})
+}
+
+// requestHandler throttles the request bodies read by h, h is returned as is
+// if the limiter is nil.
+func (l *bandwidthLimiter) requestHandler(h http.Handler) http.Handler {
+	if l == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buckets := l.acquire(r.RemoteAddr)
+		defer l.release(r.RemoteAddr)
+		r.Body = &throttledReadCloser{ReadCloser: r.Body, ctx: r.Context(), limiter: l, buckets: buckets}
+		h.ServeHTTP(w, r)
+	})
+}
+
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	limiter *bandwidthLimiter
+	buckets []*byteBucket
+}
+
+func (w *throttledResponseWriter) Write(p []byte) (int, error) {
+	return w.limiter.throttle(w.ctx, w.buckets, p, w.ResponseWriter.Write)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *throttledResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type throttledReadCloser struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *bandwidthLimiter
+	buckets []*byteBucket
+}
+
+func (r *throttledReadCloser) Read(p []byte) (int, error) {
+	chunk := len(p)
+	for _, b := range r.buckets {
+		chunk = min(chunk, int(b.burst))
+	}
+	n, err := r.ReadCloser.Read(p[:chunk])
+	// Reads are throttled after the fact as their size is not known before.
+	if _, waitErr := r.limiter.throttle(r.ctx, r.buckets, p[:n], func(b []byte) (int, error) { return len(b), nil }); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
+}
@@ -0,0 +1,167 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestByteBucket(t *testing.T) {
+	b := newByteBucket(1000)
+	clock := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return clock }
+
+	steps := []struct {
+		advance time.Duration
+		bytes   int
+		want    time.Duration
+	}{
+		{bytes: 1000, want: 0},
+		{bytes: 500, want: 500 * time.Millisecond},
+		{advance: 500 * time.Millisecond, bytes: 250, want: 250 * time.Millisecond},
+		{advance: time.Hour, bytes: 1000, want: 0},
+	}
+
+	for i, step := range steps {
+		clock = clock.Add(step.advance)
+		if got := b.reserve(step.bytes); got != step.want {
+			t.Errorf("step %d: reserve(%d) got %s, want %s", i, step.bytes, got, step.want)
+		}
+	}
+}
+
+func TestChunkBytes(t *testing.T) {
+	testCases := []struct {
+		rate int64
+		want int
+	}{
+		{rate: 1, want: 1},
+		{rate: 1000, want: 1000},
+		{rate: 10 << 20, want: maxThrottledChunkBytes},
+	}
+
+	for _, tc := range testCases {
+		if got := chunkBytes(tc.rate); got != tc.want {
+			t.Errorf("chunkBytes(%d) got %d, want %d", tc.rate, got, tc.want)
+		}
+	}
+}
+
+func TestBandwidthLimiter_Response(t *testing.T) {
+	l, cleanup, err := newBandwidthLimiter(bandwidthDownload, BandwidthLimit{PerConnectionBytesPerSecond: 256 << 10}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	body := bytes.Repeat([]byte("a"), 128<<10)
+	h := l.responseHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	elapsed := time.Since(start)
+
+	if w.Body.Len() != len(body) {
+		t.Errorf("got %d bytes, want %d", w.Body.Len(), len(body))
+	}
+	// The first 32KiB are the burst, the remaining 96KiB take 375ms.
+	if elapsed < 300*time.Millisecond {
+		t.Errorf("response took %s, want it throttled to at least 300ms", elapsed)
+	}
+	if len(l.connections) != 0 {
+		t.Errorf("want the connection bucket released, got %d connections", len(l.connections))
+	}
+	if got := l.bytes; got != int64(len(body)) {
+		t.Errorf("transferred bytes got %d, want %d", got, len(body))
+	}
+}
+
+func TestBandwidthLimiter_Request(t *testing.T) {
+	l, cleanup, err := newBandwidthLimiter(bandwidthUpload, BandwidthLimit{BytesPerSecond: 256 << 10}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	body := bytes.Repeat([]byte("a"), 128<<10)
+	var read int
+	h := l.requestHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		read = len(b)
+	}))
+
+	start := time.Now()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	elapsed := time.Since(start)
+
+	if read != len(body) {
+		t.Errorf("read %d bytes, want %d", read, len(body))
+	}
+	if elapsed < 300*time.Millisecond {
+		t.Errorf("upload took %s, want it throttled to at least 300ms", elapsed)
+	}
+}
+
+func TestBandwidthLimiter_Disabled(t *testing.T) {
+	l, _, err := newBandwidthLimiter(bandwidthDownload, BandwidthLimit{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l != nil {
+		t.Errorf("want no limiter without limits, got %+v", l)
+	}
+	h := http.NewServeMux()
+	if got := l.responseHandler(h); got != h {
+		t.Errorf("got %v, want the handler returned as is", got)
+	}
+	if got := l.requestHandler(h); got != h {
+		t.Errorf("got %v, want the handler returned as is", got)
+	}
+}
+
+func TestWebServer_Bandwidth(t *testing.T) {
+	dir := mustTempDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "big.bin"), bytes.Repeat([]byte("a"), 128<<10), 0600); err != nil {
+		t.Fatal(err)
+	}
+	baseURL, close := serveAsync(t, &Config{
+		Serve:     []Serve{{Source: dir, Endpoint: "/"}},
+		Bandwidth: Bandwidth{Download: BandwidthLimit{BytesPerSecond: 256 << 10}},
+	})
+	defer close()
+
+	start := time.Now()
+	body := mustGetBody(t, baseURL+"/big.bin")
+	elapsed := time.Since(start)
+	if len(body) != 128<<10 {
+		t.Errorf("got %d bytes, want %d", len(body), 128<<10)
+	}
+	if elapsed < 300*time.Millisecond {
+		t.Errorf("download took %s, want it throttled to at least 300ms", elapsed)
+	}
+}
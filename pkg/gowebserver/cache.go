@@ -0,0 +1,276 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// etagAuto hashes the file contents unless the mount is a local
+	// directory, whose modification times are reliable.
+	etagAuto    = "auto"
+	etagContent = "content"
+	etagOff     = "off"
+
+	// etagCacheMaxEntries bounds the number of file hashes kept per mount.
+	etagCacheMaxEntries = 100000
+	// minHashedNameLength is the shortest name segment that is taken for a
+	// content hash, such as the 8 characters of index-BZt3Qk9a.js.
+	minHashedNameLength = 8
+)
+
+var (
+	etagModes = []string{etagAuto, etagContent, etagOff}
+)
+
+// contentETag returns the strong ETag of the contents hashed in sum.
+func contentETag(sum []byte) string {
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// matchingETag returns the entity tag of the If-None-Match header that matches
+// etag, including the variants of etag that compression added the content
+// coding to, and empty if none does.
+func matchingETag(r *http.Request, etag string) string {
+	for _, field := range r.Header.Values("If-None-Match") {
+		for _, tag := range strings.Split(field, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" {
+				return etag
+			}
+			// If-None-Match uses the weak comparison.
+			opaque := strings.TrimPrefix(tag, "W/")
+			if opaque == etag {
+				return tag
+			}
+			for _, encoding := range compressionEncodings {
+				if opaque == encodedETag(etag, encoding) {
+					return tag
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// notModified answers 304 Not Modified to GET and HEAD requests whose
+// If-None-Match header matches etag.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	tag := matchingETag(r, etag)
+	if tag == "" {
+		return false
+	}
+	h := w.Header()
+	delete(h, "Content-Type")
+	delete(h, "Content-Length")
+	h.Set("ETag", tag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// serveGenerated serves a generated page, such as a directory listing, with
+// the ETag of its contents so that clients can revalidate it. modTime is
+// zero if the page has no meaningful modification time.
+func serveGenerated(w http.ResponseWriter, r *http.Request, body []byte, modTime time.Time) {
+	sum := sha256.Sum256(body)
+	etag := contentETag(sum[:])
+	if notModified(w, r, etag) {
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(body))
+}
+
+// fileETag is a cached content hash, valid as long as the file keeps its
+// size and modification time.
+type fileETag struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// etagHandler sets strong ETags computed from the file contents so that
+// clients can revalidate files whose modification times are unreliable, such
+// as the ones in archives. The hashes are computed on the first request of
+// each file.
+type etagHandler struct {
+	next http.Handler
+	fsys fs.FS
+
+	mu    sync.Mutex
+	etags map[string]fileETag
+}
+
+func newETagHandler(next http.Handler, fsys fs.FS) *etagHandler {
+	return &etagHandler{next: next, fsys: fsys, etags: map[string]fileETag{}}
+}
+
+func (h *etagHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// http.FileServer redirects index.html requests to the directory.
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || strings.HasSuffix(r.URL.Path, "/index.html") {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	name := cleanPath(strings.TrimPrefix(r.URL.Path, "/"))
+	stat, err := fs.Stat(h.fsys, name)
+	if err != nil || !stat.Mode().IsRegular() {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	etag, err := h.etag(name, stat)
+	if err != nil {
+		requestLogger(r.Context()).With("error", err, "path", name).Warn("cannot compute ETag")
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	if notModified(w, r, etag) {
+		return
+	}
+	w.Header().Set("ETag", etag)
+	h.next.ServeHTTP(w, r)
+}
+
+// etag returns the cached ETag of the file or hashes its contents.
+func (h *etagHandler) etag(name string, stat fs.FileInfo) (string, error) {
+	h.mu.Lock()
+	cached, ok := h.etags[name]
+	h.mu.Unlock()
+	if ok && cached.size == stat.Size() && cached.modTime.Equal(stat.ModTime()) {
+		return cached.etag, nil
+	}
+
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("cannot hash '%s', %w", name, err)
+	}
+	etag := contentETag(hash.Sum(nil))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.etags) >= etagCacheMaxEntries {
+		clear(h.etags)
+	}
+	h.etags[name] = fileETag{size: stat.Size(), modTime: stat.ModTime(), etag: etag}
+	return etag, nil
+}
+
+// matches reports whether the rule applies to the request path.
+func (r CacheRule) matches(urlPath string) bool {
+	if r.Hashed && !isHashedName(path.Base(urlPath)) {
+		return false
+	}
+	return matchPathPattern(r.Path, urlPath)
+}
+
+// validate checks the pattern and value of the rule.
+func (r CacheRule) validate() error {
+	if _, err := path.Match(r.Path, ""); err != nil {
+		return fmt.Errorf("invalid path pattern '%s', %w", r.Path, err)
+	}
+	if strings.TrimSpace(r.CacheControl) == "" {
+		return fmt.Errorf("rule does not set cacheControl")
+	}
+	return nil
+}
+
+// isHashedName reports whether the file name contains a content hash, such as
+// app.3f9a2c1e.js or index-BZt3Qk9a.css. Those files never change since a
+// new version gets a new name.
+func isHashedName(name string) bool {
+	segments := strings.FieldsFunc(name, func(c rune) bool { return c == '.' || c == '-' })
+	if len(segments) < 3 {
+		return false
+	}
+	// The first segment is the name and the last the extension.
+	for _, segment := range segments[1 : len(segments)-1] {
+		if len(segment) < minHashedNameLength || !strings.ContainsAny(segment, "0123456789") {
+			continue
+		}
+		if strings.IndexFunc(segment, func(c rune) bool {
+			return !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_')
+		}) < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheRulesHandler sets the Cache-Control header of the first matching rule
+// on the successful responses.
+type cacheRulesHandler struct {
+	next  http.Handler
+	rules []CacheRule
+}
+
+func (h *cacheRulesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, rule := range h.rules {
+		if rule.matches(r.URL.Path) {
+			h.next.ServeHTTP(&cacheRuleWriter{ResponseWriter: w, cacheControl: rule.CacheControl}, r)
+			return
+		}
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+type cacheRuleWriter struct {
+	http.ResponseWriter
+	cacheControl string
+	wroteHeader  bool
+}
+
+func (w *cacheRuleWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader && statusCode >= http.StatusOK {
+		w.wroteHeader = true
+		// Errors are not cached for as long as the content.
+		if statusCode < http.StatusBadRequest {
+			w.Header().Set("Cache-Control", w.cacheControl)
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *cacheRuleWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *cacheRuleWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
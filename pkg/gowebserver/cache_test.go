@@ -0,0 +1,162 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIsHashedName(t *testing.T) {
+	testCases := []struct {
+		name string
+		want bool
+	}{
+		{name: "app.3f9a2c1e.js", want: true},
+		{name: "index-BZt3Qk9a.css", want: true},
+		{name: "chunk.3f9a2c1e.min.js", want: true},
+		{name: "app.js", want: false},
+		{name: "3f9a2c1e.js", want: false},
+		{name: "jquery-3.6.0.min.js", want: false},
+		{name: "my-longname.js", want: false},
+		{name: "app.3f9a.js", want: false},
+		{name: "app.3f9a2c1e", want: false},
+	}
+	for _, tc := range testCases {
+		if got := isHashedName(tc.name); got != tc.want {
+			t.Errorf("isHashedName(%q) got %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMatchingETag(t *testing.T) {
+	testCases := []struct {
+		ifNoneMatch string
+		want        string
+	}{
+		{ifNoneMatch: "", want: ""},
+		{ifNoneMatch: `"abc"`, want: `"abc"`},
+		{ifNoneMatch: `W/"abc"`, want: `W/"abc"`},
+		{ifNoneMatch: `"other", "abc-gzip"`, want: `"abc-gzip"`},
+		{ifNoneMatch: `"abc-deflate"`, want: ""},
+		{ifNoneMatch: `"ab"`, want: ""},
+		{ifNoneMatch: "*", want: `"abc"`},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", tc.ifNoneMatch)
+		}
+		if got := matchingETag(r, `"abc"`); got != tc.want {
+			t.Errorf("matchingETag(%q) got %q, want %q", tc.ifNoneMatch, got, tc.want)
+		}
+	}
+}
+
+func TestWebServer_ETag(t *testing.T) {
+	assets := fstest.MapFS{
+		"app.3f9a2c1e.js": {Data: []byte(strings.Repeat("console.log('hello');\n", 100))},
+		"index.txt":       {Data: []byte("hello")},
+		"main.go":         {Data: []byte("package main\n")},
+		"vendor.js":       {Data: []byte("console.log('vendor');\n")},
+	}
+	ws, err := New(&Config{EnhancedList: true, Compression: Compression{Enabled: true, MinSizeBytes: 128}},
+		WithFS(Serve{
+			Endpoint:     "/",
+			CacheControl: "no-cache",
+			Cache:        []CacheRule{{Path: "*.js", Hashed: true, CacheControl: "public, max-age=31536000, immutable"}},
+		}, assets),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Shutdown(context.Background())
+	h, err := ws.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/index.txt", "/app.3f9a2c1e.js", "/", "/main.go?view=rich"} {
+		w := mustServeRequest(t, h, http.MethodGet, path, "", map[string]string{"Accept-Encoding": "gzip"})
+		etag := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || etag == "" {
+			t.Fatalf("GET %s got %d with ETag %q, want 200 with an ETag", path, w.Code, etag)
+		}
+		w = mustServeRequest(t, h, http.MethodGet, path, "", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("GET %s with If-None-Match %s got %d, want %d", path, etag, w.Code, http.StatusNotModified)
+		}
+		w = mustServeRequest(t, h, http.MethodGet, path, "", map[string]string{"If-None-Match": `"stale"`})
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s with a stale ETag got %d, want %d", path, w.Code, http.StatusOK)
+		}
+	}
+
+	first := mustServeRequest(t, h, http.MethodGet, "/index.txt", "", nil).Header().Get("ETag")
+	if second := mustServeRequest(t, h, http.MethodGet, "/index.txt", "", nil).Header().Get("ETag"); first != second {
+		t.Errorf("ETag changed from %s to %s", first, second)
+	}
+
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{path: "/app.3f9a2c1e.js", want: "public, max-age=31536000, immutable"},
+		{path: "/index.txt", want: "no-cache"},
+		{path: "/vendor.js", want: "no-cache"},
+	}
+	for _, tc := range testCases {
+		if got := mustServeRequest(t, h, http.MethodGet, tc.path, "", nil).Header().Get("Cache-Control"); got != tc.want {
+			t.Errorf("GET %s got Cache-Control %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestWebServer_ETagLocalDirectory(t *testing.T) {
+	dir := mustTempDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		etag string
+		want bool
+	}{
+		{etag: "", want: false},
+		{etag: etagContent, want: true},
+	} {
+		ws, err := New(&Config{Serve: []Serve{{Source: dir, Endpoint: "/", ETag: tc.etag}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := ws.Handler()
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := mustServeRequest(t, h, http.MethodGet, "/a.txt", "", nil)
+		if got := w.Header().Get("ETag") != ""; got != tc.want {
+			t.Errorf("etag %q got ETag %q, want one %t", tc.etag, w.Header().Get("ETag"), tc.want)
+		}
+		if w.Header().Get("Last-Modified") == "" {
+			t.Errorf("etag %q got no Last-Modified", tc.etag)
+		}
+		ws.Shutdown(context.Background())
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// certificateStore holds the server certificate of the HTTPS and HTTP/3
+// listeners so that it can be reloaded without a restart.
+type certificateStore struct {
+	certificateFilePath string
+	privateKeyFilePath  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newCertificateStore(certificateFilePath string, privateKeyFilePath string) (*certificateStore, error) {
+	s := &certificateStore{certificateFilePath: certificateFilePath, privateKeyFilePath: privateKeyFilePath}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload reads the certificate files again, the previous certificate is kept
+// if they cannot be loaded.
+func (s *certificateStore) reload() error {
+	cert, err := tls.LoadX509KeyPair(s.certificateFilePath, s.privateKeyFilePath)
+	if err != nil {
+		return fmt.Errorf("cannot load certificate '%s', %w", s.certificateFilePath, err)
+	}
+	s.mu.Lock()
+	s.cert = &cert
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *certificateStore) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cert, nil
+}
+
+// leaf returns the parsed server certificate.
+func (s *certificateStore) leaf() (*x509.Certificate, error) {
+	s.mu.RLock()
+	cert := s.cert
+	s.mu.RUnlock()
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+	return x509.ParseCertificate(cert.Certificate[0])
+}
+
+// tlsConfig returns the TLS settings of a listener that serves the stored
+// certificate, clientTLS holds the client certificate settings, nil if there
+// are none.
+func (s *certificateStore) tlsConfig(clientTLS *tls.Config) *tls.Config {
+	conf := &tls.Config{}
+	if clientTLS != nil {
+		conf = clientTLS.Clone()
+	}
+	conf.GetCertificate = s.getCertificate
+	return conf
+}
+
+// certificateInfo describes a certificate in the admin API.
+type certificateInfo struct {
+	Subject   string    `json:"subject"`
+	DNSNames  []string  `json:"dnsNames,omitempty"`
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+	SHA256    string    `json:"sha256"`
+}
+
+func newCertificateInfo(cert *x509.Certificate) *certificateInfo {
+	return &certificateInfo{
+		Subject:   cert.Subject.String(),
+		DNSNames:  cert.DNSNames,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		SHA256:    certificateFingerprint(cert),
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+const (
+	clientAuthNone    = "none"
+	clientAuthRequest = "request"
+	clientAuthRequire = "require"
+
+	certIdentityCommonName = "commonName"
+	certIdentityEmail      = "email"
+	certIdentityDNS        = "dns"
+	certIdentityURI        = "uri"
+
+	identitySourceCertificate = "certificate"
+)
+
+var (
+	clientAuthModes    = []string{clientAuthNone, clientAuthRequest, clientAuthRequire}
+	certIdentityFields = []string{certIdentityCommonName, certIdentityEmail, certIdentityDNS, certIdentityURI}
+)
+
+// enabled reports whether the HTTPS listeners ask for client certificates.
+func (c ClientAuth) enabled() bool {
+	return c.Mode == clientAuthRequest || c.Mode == clientAuthRequire
+}
+
+// tlsConfig returns the TLS settings that verify client certificates against
+// the CA bundle, nil if client certificates are not asked for.
+func (c ClientAuth) tlsConfig() (*tls.Config, error) {
+	if !c.enabled() {
+		return nil, nil
+	}
+	pool, err := readCertPool(c.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	conf := &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	if c.Mode == clientAuthRequire {
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return conf, nil
+}
+
+// readCertPool reads a PEM bundle of CA certificates.
+func readCertPool(name string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read client CA bundle '%s', %w", name, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("client CA bundle '%s' has no PEM certificates", name)
+	}
+	return pool, nil
+}
+
+// certificateName returns the user name in the certificate's field, empty if
+// it has none.
+func (c ClientAuth) certificateName(cert *x509.Certificate) string {
+	switch c.Identity {
+	case certIdentityEmail:
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	case certIdentityDNS:
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	case certIdentityURI:
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	default:
+		return cert.Subject.CommonName
+	}
+	return ""
+}
+
+// clientCertificate returns the verified client certificate of the request.
+func clientCertificate(r *http.Request) (*x509.Certificate, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return r.TLS.VerifiedChains[0][0], true
+}
+
+// certificateFingerprint is the hex SHA-256 of the DER certificate, as shown
+// by openssl x509 -fingerprint -sha256 without the colons.
+func certificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
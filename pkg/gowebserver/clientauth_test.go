@@ -0,0 +1,230 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jeremyje/gowebserver/v2/pkg/certtool"
+)
+
+func mustGenerateKeyPair(tb testing.TB, args *certtool.Args) *certtool.KeyPair {
+	tb.Helper()
+	args.KeyType = &certtool.KeyType{Algorithm: "ECDSA", KeyLength: 256}
+	args.Validity = time.Hour
+	kp, err := certtool.GenerateKeyPair(args)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return kp
+}
+
+func mustParseCertificate(tb testing.TB, kp *certtool.KeyPair) *x509.Certificate {
+	tb.Helper()
+	block, _ := pem.Decode(kp.PublicCertificate)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return cert
+}
+
+func TestClientAuth_TLSConfig(t *testing.T) {
+	dir := mustTempDir(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, mustGenerateKeyPair(t, &certtool.Args{CA: true, CommonName: "Test CA"}).PublicCertificate, 0600); err != nil {
+		t.Fatal(err)
+	}
+	notPEM := filepath.Join(dir, "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		conf    ClientAuth
+		want    tls.ClientAuthType
+		wantErr bool
+	}{
+		{conf: ClientAuth{}, want: tls.NoClientCert},
+		{conf: ClientAuth{Mode: clientAuthNone, CAFile: caFile}, want: tls.NoClientCert},
+		{conf: ClientAuth{Mode: clientAuthRequest, CAFile: caFile}, want: tls.VerifyClientCertIfGiven},
+		{conf: ClientAuth{Mode: clientAuthRequire, CAFile: caFile}, want: tls.RequireAndVerifyClientCert},
+		{conf: ClientAuth{Mode: clientAuthRequire, CAFile: notPEM}, wantErr: true},
+		{conf: ClientAuth{Mode: clientAuthRequire, CAFile: filepath.Join(dir, "missing.pem")}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s %s", tc.conf.Mode, filepath.Base(tc.conf.CAFile)), func(t *testing.T) {
+			conf, err := tc.conf.tlsConfig()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("tlsConfig() error = %v, wantErr %t", err, tc.wantErr)
+			}
+			got := tls.NoClientCert
+			if conf != nil {
+				got = conf.ClientAuth
+			}
+			if got != tc.want {
+				t.Errorf("got client auth %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClientAuth_CertificateName(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.com/ci")
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "frank"},
+		EmailAddresses: []string{"frank@example.com"},
+		DNSNames:       []string{"ci.example.com"},
+		URIs:           []*url.URL{spiffe},
+	}
+	testCases := []struct {
+		identity string
+		cert     *x509.Certificate
+		want     string
+	}{
+		{identity: "", cert: cert, want: "frank"},
+		{identity: certIdentityCommonName, cert: cert, want: "frank"},
+		{identity: certIdentityEmail, cert: cert, want: "frank@example.com"},
+		{identity: certIdentityDNS, cert: cert, want: "ci.example.com"},
+		{identity: certIdentityURI, cert: cert, want: "spiffe://example.com/ci"},
+		{identity: certIdentityEmail, cert: &x509.Certificate{Subject: pkix.Name{CommonName: "frank"}}, want: ""},
+	}
+	for _, tc := range testCases {
+		if got := (ClientAuth{Identity: tc.identity}).certificateName(tc.cert); got != tc.want {
+			t.Errorf("certificateName() with identity %q got %q, want %q", tc.identity, got, tc.want)
+		}
+	}
+}
+
+func TestAuthenticator_CertificateIdentity(t *testing.T) {
+	a, err := newAuthenticator(
+		Auth{Users: []User{{Name: "frank", PasswordHash: mustHashPassword(t, "secret"), Roles: []string{"staff"}}}},
+		ClientAuth{Mode: clientAuthRequest, Roles: []string{"clients"}, Users: map[string][]string{"grace": {"operator"}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name  string
+		roles []string
+	}{
+		// Basic auth users with the same name do not lend their roles.
+		{name: "frank", roles: []string{"clients"}},
+		{name: "grace", roles: []string{"clients", "operator"}},
+	}
+	for _, tc := range testCases {
+		got, ok := a.certificateIdentity(&x509.Certificate{Subject: pkix.Name{CommonName: tc.name}})
+		if !ok {
+			t.Fatalf("want an identity for the certificate of %s", tc.name)
+		}
+		want := &identity{name: tc.name, roles: tc.roles, source: identitySourceCertificate}
+		if diff := cmp.Diff(want, got, cmp.AllowUnexported(identity{})); diff != "" {
+			t.Errorf("identity mismatch (-want +got):\n%s", diff)
+		}
+	}
+	if _, ok := a.certificateIdentity(&x509.Certificate{}); ok {
+		t.Error("want no identity for a certificate without a common name")
+	}
+}
+
+func TestWebServer_ClientAuth(t *testing.T) {
+	dir := mustTempDir(t)
+	ca := mustGenerateKeyPair(t, &certtool.Args{CA: true, CommonName: "Test Client CA"})
+	caFile := filepath.Join(dir, "client-ca.pem")
+	if err := os.WriteFile(caFile, ca.PublicCertificate, 0600); err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "web.cert")
+	keyFile := filepath.Join(dir, "web.key")
+	if err := certtool.WriteKeyPair(mustGenerateKeyPair(t, &certtool.Args{Hostnames: []string{"127.0.0.1"}}), certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	frank := mustGenerateKeyPair(t, &certtool.Args{CommonName: "frank", ParentKeyPair: ca})
+	mallory := mustGenerateKeyPair(t, &certtool.Args{CommonName: "frank"})
+	logFile := filepath.Join(dir, "access.log")
+
+	ws, _, close := serveAsyncServer(t, &Config{
+		Serve: []Serve{
+			{Source: dir, Endpoint: "/private", Access: map[string][]string{"clients": {permissionRead}}},
+		},
+		HTTP: HTTP{Addresses: []string{"127.0.0.1:0"}},
+		HTTPS: HTTPS{
+			Addresses:   []string{"127.0.0.1:0"},
+			Certificate: Certificate{CertificateFilePath: certFile, PrivateKeyFilePath: keyFile},
+			ClientAuth:  ClientAuth{Mode: clientAuthRequest, CAFile: caFile, Identity: certIdentityCommonName, Roles: []string{"clients"}},
+		},
+		AccessLog: AccessLog{Format: accessLogFormatJSON, Path: logFile},
+	})
+	_, httpsPort := ws.getPorts()
+	privateURL := fmt.Sprintf("https://127.0.0.1:%d/private/a.txt", httpsPort)
+
+	get := func(kp *certtool.KeyPair) (int, error) {
+		t.Helper()
+		tlsConfig := &tls.Config{InsecureSkipVerify: true}
+		if kp != nil {
+			cert, err := tls.X509KeyPair(kp.PublicCertificate, kp.PrivateKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Send the certificate even if the server does not list its CA.
+			tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return &cert, nil }
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get(privateURL)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+
+	if status, err := get(nil); err != nil || status != http.StatusUnauthorized {
+		t.Errorf("without a certificate got %d %v, want %d", status, err, http.StatusUnauthorized)
+	}
+	if status, err := get(frank); err != nil || status != http.StatusOK {
+		t.Errorf("with a certificate got %d %v, want %d", status, err, http.StatusOK)
+	}
+	if _, err := get(mallory); err == nil {
+		t.Error("want the handshake rejected for a certificate of another CA")
+	}
+	close()
+
+	contents, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `"user":"frank","method":"GET","uri":"/private/a.txt"`
+	fingerprint := fmt.Sprintf(`"clientCertSha256":"%s"`, certificateFingerprint(mustParseCertificate(t, frank)))
+	if !strings.Contains(string(contents), want) || !strings.Contains(string(contents), fingerprint) {
+		t.Errorf("want the access log to have the certificate user and fingerprint, got\n%s", contents)
+	}
+}
@@ -0,0 +1,441 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+)
+
+const (
+	encodingBrotli = "br"
+	encodingZstd   = "zstd"
+	encodingGzip   = "gzip"
+
+	compressionLevelFastest = "fastest"
+	compressionLevelDefault = "default"
+	compressionLevelBest    = "best"
+
+	// zstdWindowSize is the largest window that browsers decode.
+	zstdWindowSize = 8 << 20
+)
+
+var (
+	// compressionEncodings are the supported content codings, in the default
+	// order of preference.
+	compressionEncodings = []string{encodingBrotli, encodingZstd, encodingGzip}
+	compressionLevels    = []string{compressionLevelFastest, compressionLevelDefault, compressionLevelBest}
+
+	// precompressedExtensions are the file name extensions of the
+	// precompressed siblings of a file.
+	precompressedExtensions = map[string]string{
+		encodingBrotli: ".br",
+		encodingZstd:   ".zst",
+		encodingGzip:   ".gz",
+	}
+
+	// compressibleContentTypes are the media types other than text/* that
+	// are worth compressing.
+	compressibleContentTypes = []string{
+		"application/javascript",
+		"application/json",
+		"application/manifest+json",
+		"application/wasm",
+		"application/x-javascript",
+		"application/xhtml+xml",
+		"application/xml",
+		"font/otf",
+		"font/ttf",
+		"image/svg+xml",
+		"image/x-icon",
+	}
+)
+
+// compressibleContentType reports whether responses of the Content-Type are
+// worth compressing, already compressed formats such as images are not.
+func compressibleContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") || slices.Contains(compressibleContentTypes, mediaType)
+}
+
+// negotiateEncoding returns the content coding of the Accept-Encoding header
+// with the highest quality, ties are broken by the order of supported. It is
+// empty if the client accepts none of them.
+func negotiateEncoding(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name != "" {
+			qualities[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range supported {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// encodedETag returns the ETag of the representation of the content in the
+// encoding, a strong ETag must differ between content codings.
+func encodedETag(etag string, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// addVary adds the request header to the Vary header unless it is listed.
+func addVary(h http.Header, name string) {
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
+
+// encoder is a pooled compressing writer.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressor compresses the responses of the mounts for the clients that
+// accept it.
+type compressor struct {
+	encodings []string
+	minSize   int64
+	pools     map[string]*sync.Pool
+}
+
+// newCompressor returns nil if compression is disabled.
+func newCompressor(conf Compression) (*compressor, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+	encodings := conf.Encodings
+	if len(encodings) == 0 {
+		encodings = compressionEncodings
+	}
+	level := conf.Level
+	if level == "" {
+		level = compressionLevelDefault
+	}
+	if !slices.Contains(compressionLevels, level) {
+		return nil, fmt.Errorf("unknown compression level '%s', want one of %s", conf.Level, strings.Join(compressionLevels, ", "))
+	}
+
+	c := &compressor{minSize: conf.MinSizeBytes, pools: map[string]*sync.Pool{}}
+	for _, encoding := range encodings {
+		encoding = strings.ToLower(encoding)
+		newEncoder, err := newEncoderFunc(encoding, level)
+		if err != nil {
+			return nil, err
+		}
+		c.encodings = append(c.encodings, encoding)
+		c.pools[encoding] = &sync.Pool{New: func() any { return newEncoder() }}
+	}
+	return c, nil
+}
+
+// newEncoderFunc returns the constructor of the encoders of a content coding.
+func newEncoderFunc(encoding string, level string) (func() encoder, error) {
+	levelIndex := slices.Index(compressionLevels, level)
+	switch encoding {
+	case encodingGzip:
+		gzipLevel := []int{gzip.BestSpeed, gzip.DefaultCompression, gzip.BestCompression}[levelIndex]
+		return func() encoder {
+			w, _ := gzip.NewWriterLevel(io.Discard, gzipLevel)
+			return w
+		}, nil
+	case encodingBrotli:
+		brotliLevel := []int{brotli.BestSpeed, 5, brotli.BestCompression}[levelIndex]
+		return func() encoder {
+			return brotli.NewWriterLevel(io.Discard, brotliLevel)
+		}, nil
+	case encodingZstd:
+		zstdLevel := []zstd.EncoderLevel{zstd.SpeedFastest, zstd.SpeedDefault, zstd.SpeedBestCompression}[levelIndex]
+		return func() encoder {
+			w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstdLevel), zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(zstdWindowSize))
+			return w
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown compression encoding '%s', want one of %s", encoding, strings.Join(compressionEncodings, ", "))
+}
+
+// handler compresses the responses of h, h is returned as is if the
+// compressor is nil.
+func (c *compressor) handler(h http.Handler) http.Handler {
+	if c == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := ""
+		// Ranges apply to the uncompressed content.
+		if r.Header.Get("Range") == "" {
+			encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"), c.encodings)
+		}
+		cw := &compressResponseWriter{ResponseWriter: w, c: c, encoding: encoding, head: r.Method == http.MethodHead}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// compressResponseWriter buffers the start of the response until it knows
+// whether the response is large enough and of a compressible type.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	c        *compressor
+	encoding string
+	head     bool
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+// candidate reports whether the response can be compressed based on its
+// status and headers.
+func (w *compressResponseWriter) candidate() bool {
+	h := w.Header()
+	if w.status != http.StatusOK || h.Get("Content-Encoding") != "" || strings.Contains(h.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+	return compressibleContentType(h.Get("Content-Type"))
+}
+
+// start writes the status and headers, compressing the body if compress is
+// true and the response is a candidate.
+func (w *compressResponseWriter) start(compress bool) {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	if _, ok := h["Content-Type"]; !ok && len(w.buf) > 0 {
+		// Same as net/http, the Content-Type decides on compression.
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if w.candidate() {
+		addVary(h, "Accept-Encoding")
+		if compress && w.encoding != "" {
+			h.Del("Content-Length")
+			h.Del("Accept-Ranges")
+			h.Set("Content-Encoding", w.encoding)
+			if etag := h.Get("ETag"); etag != "" {
+				h.Set("ETag", encodedETag(etag, w.encoding))
+			}
+			if !w.head {
+				w.enc = w.c.pools[w.encoding].Get().(encoder)
+				w.enc.Reset(w.ResponseWriter)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) > 0 {
+		buf := w.buf
+		w.buf = nil
+		if _, err := w.write(buf); err != nil {
+			zap.S().With("error", err).Debug("cannot write compressed response")
+		}
+	}
+}
+
+func (w *compressResponseWriter) write(p []byte) (int, error) {
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *compressResponseWriter) WriteHeader(statusCode int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	if statusCode < http.StatusOK {
+		// Informational responses such as 103 Early Hints are sent as is.
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.status = statusCode
+	if !w.candidate() && w.Header().Get("Content-Type") != "" {
+		w.start(false)
+		return
+	}
+	if length, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil {
+		w.start(length >= w.c.minSize)
+	}
+}
+
+func (w *compressResponseWriter) Write(p []byte) (int, error) {
+	if !w.decided && w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if int64(len(w.buf)) >= w.c.minSize {
+			w.start(true)
+		}
+		return len(p), nil
+	}
+	return w.write(p)
+}
+
+// FlushError sends the buffered response, a flushed response is compressed
+// regardless of its size since more is likely to follow.
+func (w *compressResponseWriter) FlushError() error {
+	if !w.decided {
+		w.start(true)
+	}
+	if w.enc != nil {
+		if err := w.enc.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressResponseWriter) Flush() {
+	w.FlushError() //nolint:errcheck
+}
+
+// close sends the rest of the response and returns the encoder to its pool.
+func (w *compressResponseWriter) close() {
+	if !w.decided {
+		length, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64)
+		w.start(err == nil && len(w.buf) == 0 && length >= w.c.minSize)
+	}
+	if w.enc != nil {
+		if err := w.enc.Close(); err != nil {
+			zap.S().With("error", err).Debug("cannot finish compressed response")
+		}
+		w.enc.Reset(io.Discard)
+		w.c.pools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// precompressedHandler serves the precompressed siblings of files, such as
+// app.js.br or app.js.gz next to app.js, to the clients that accept them.
+type precompressedHandler struct {
+	next http.Handler
+	fsys fs.FS
+}
+
+func (h *precompressedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	name := cleanPath(strings.TrimPrefix(r.URL.Path, "/"))
+	stat, err := fs.Stat(h.fsys, name)
+	if err != nil || stat.IsDir() {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	available := []string{}
+	for _, encoding := range compressionEncodings {
+		if sibling, err := fs.Stat(h.fsys, name+precompressedExtensions[encoding]); err == nil && !sibling.IsDir() {
+			available = append(available, encoding)
+		}
+	}
+	if len(available) == 0 {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	addVary(w.Header(), "Accept-Encoding")
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), available)
+	if encoding == "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	f, err := http.FS(h.fsys).Open(name + precompressedExtensions[encoding])
+	if err != nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	defer f.Close()
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = sniffContentType(h.fsys, name)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Encoding", encoding)
+	if etag := w.Header().Get("ETag"); etag != "" {
+		w.Header().Set("ETag", encodedETag(etag, encoding))
+	}
+	// The modification time is the one of the original file so that
+	// conditional requests do not depend on the encoding.
+	http.ServeContent(w, r, name, stat.ModTime(), f)
+}
+
+// sniffContentType detects the Content-Type of a file from its contents.
+func sniffContentType(fsys fs.FS, name string) string {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(f, buf)
+	return http.DetectContentType(buf[:n])
+}
@@ -0,0 +1,256 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andybalholm/brotli"
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	testCases := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{acceptEncoding: "", want: ""},
+		{acceptEncoding: "gzip", want: "gzip"},
+		{acceptEncoding: "gzip, deflate, br, zstd", want: "br"},
+		{acceptEncoding: "gzip;q=1.0, br;q=0.5", want: "gzip"},
+		{acceptEncoding: "br;q=0, gzip", want: "gzip"},
+		{acceptEncoding: "*", want: "br"},
+		{acceptEncoding: "*;q=0.1, zstd", want: "zstd"},
+		{acceptEncoding: "identity", want: ""},
+		{acceptEncoding: "GZIP;Q=0.8", want: "gzip"},
+		{acceptEncoding: "gzip;q=bad", want: ""},
+	}
+	for _, tc := range testCases {
+		if got := negotiateEncoding(tc.acceptEncoding, compressionEncodings); got != tc.want {
+			t.Errorf("negotiateEncoding(%q) got %q, want %q", tc.acceptEncoding, got, tc.want)
+		}
+	}
+}
+
+func mustDecode(tb testing.TB, encoding string, body []byte) string {
+	tb.Helper()
+	var r io.Reader = strings.NewReader(string(body))
+	switch encoding {
+	case encodingGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		r = gr
+	case encodingBrotli:
+		r = brotli.NewReader(r)
+	case encodingZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCompressorHandler(t *testing.T) {
+	large := strings.Repeat("<p>Hello, World!</p>\n", 100)
+	testCases := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		rangeHeader    string
+		headers        map[string]string
+		status         int
+		body           string
+		wantEncoding   string
+		wantVary       bool
+	}{
+		{name: "gzip", acceptEncoding: "gzip", body: large, wantEncoding: "gzip", wantVary: true},
+		{name: "brotli", acceptEncoding: "gzip, br", body: large, wantEncoding: "br", wantVary: true},
+		{name: "zstd", acceptEncoding: "zstd", body: large, wantEncoding: "zstd", wantVary: true},
+		{name: "sniffed", acceptEncoding: "gzip", headers: map[string]string{}, body: large, wantEncoding: "gzip", wantVary: true},
+		{name: "content length", acceptEncoding: "gzip", headers: map[string]string{"Content-Type": "text/css", "Content-Length": "2100"}, body: large, wantEncoding: "gzip", wantVary: true},
+		{name: "head", method: http.MethodHead, acceptEncoding: "gzip", headers: map[string]string{"Content-Type": "text/css", "Content-Length": "2100"}, wantEncoding: "gzip", wantVary: true},
+		{name: "small", acceptEncoding: "gzip", body: "<p>Hi</p>", wantVary: true},
+		{name: "not accepted", body: large, wantVary: true},
+		{name: "image", acceptEncoding: "gzip", headers: map[string]string{"Content-Type": "image/png"}, body: large},
+		{name: "already encoded", acceptEncoding: "gzip", headers: map[string]string{"Content-Type": "text/html", "Content-Encoding": "br"}, body: large},
+		{name: "no transform", acceptEncoding: "gzip", headers: map[string]string{"Content-Type": "text/html", "Cache-Control": "no-transform"}, body: large},
+		{name: "range", acceptEncoding: "gzip", rangeHeader: "bytes=0-10", body: large, wantVary: true},
+		{name: "not found", acceptEncoding: "gzip", status: http.StatusNotFound, body: large},
+	}
+
+	c, err := newCompressor(Compression{Enabled: true, MinSizeBytes: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			headers := tc.headers
+			if headers == nil {
+				headers = map[string]string{"Content-Type": "text/html; charset=utf-8"}
+			}
+			h := c.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range headers {
+					w.Header().Set(k, v)
+				}
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				if r.Method == http.MethodHead {
+					return
+				}
+				// Several writes so that the buffering is exercised.
+				for i := 0; i < len(tc.body); i += 100 {
+					io.WriteString(w, tc.body[i:min(i+100, len(tc.body))])
+				}
+			}))
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/", nil)
+			if tc.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			if tc.rangeHeader != "" {
+				r.Header.Set("Range", tc.rangeHeader)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			gotEncoding := w.Header().Get("Content-Encoding")
+			if _, ok := headers["Content-Encoding"]; ok {
+				gotEncoding = ""
+			}
+			if gotEncoding != tc.wantEncoding {
+				t.Errorf("Content-Encoding got %q, want %q", gotEncoding, tc.wantEncoding)
+			}
+			if got := w.Header().Get("Vary") == "Accept-Encoding"; got != tc.wantVary {
+				t.Errorf("Vary got %q, want Accept-Encoding %t", w.Header().Get("Vary"), tc.wantVary)
+			}
+			if tc.wantEncoding != "" && w.Header().Get("Content-Length") != "" {
+				t.Errorf("Content-Length got %q, want none", w.Header().Get("Content-Length"))
+			}
+			if method == http.MethodHead {
+				if w.Body.Len() != 0 {
+					t.Errorf("HEAD got a %d byte body, want none", w.Body.Len())
+				}
+			} else if got := mustDecode(t, gotEncoding, w.Body.Bytes()); got != tc.body {
+				t.Errorf("body mismatch (-want +got):\n%s", cmp.Diff(tc.body, got))
+			}
+		})
+	}
+
+	if _, err := newCompressor(Compression{Enabled: true, Encodings: []string{"deflate"}}); err == nil {
+		t.Error("want an error for an unsupported encoding")
+	}
+	if _, err := newCompressor(Compression{Enabled: true, Level: "max"}); err == nil {
+		t.Error("want an error for an unknown level")
+	}
+}
+
+func TestWebServer_Compression(t *testing.T) {
+	script := strings.Repeat("console.log('hello');\n", 100)
+	assets := fstest.MapFS{
+		"app.js":        {Data: []byte(script)},
+		"app.js.br":     {Data: []byte("precompressed brotli")},
+		"app.js.gz":     {Data: []byte("precompressed gzip")},
+		"data":          {Data: []byte(script)},
+		"data.gz":       {Data: []byte("precompressed data")},
+		"orphan.css.gz": {Data: []byte("no original")},
+		"style.css":     {Data: []byte(strings.Repeat("p { color: red; }\n", 100))},
+	}
+	ws, err := New(&Config{Compression: Compression{Enabled: true, MinSizeBytes: 128}},
+		WithFS(Serve{Endpoint: "/", Precompressed: true}, assets),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Shutdown(context.Background())
+	h, err := ws.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path            string
+		acceptEncoding  string
+		wantEncoding    string
+		wantContentType string
+		wantBody        string
+	}{
+		{path: "/app.js", acceptEncoding: "gzip, br", wantEncoding: "br", wantContentType: "text/javascript; charset=utf-8", wantBody: "precompressed brotli"},
+		{path: "/app.js", acceptEncoding: "gzip", wantEncoding: "gzip", wantContentType: "text/javascript; charset=utf-8", wantBody: "precompressed gzip"},
+		{path: "/app.js", acceptEncoding: "zstd", wantEncoding: "zstd", wantContentType: "text/javascript; charset=utf-8", wantBody: script},
+		{path: "/app.js", wantContentType: "text/javascript; charset=utf-8", wantBody: script},
+		{path: "/data", acceptEncoding: "gzip", wantEncoding: "gzip", wantContentType: "text/plain; charset=utf-8", wantBody: "precompressed data"},
+		{path: "/orphan.css", acceptEncoding: "gzip"},
+		{path: "/style.css", acceptEncoding: "br", wantEncoding: "br", wantContentType: "text/css; charset=utf-8", wantBody: strings.Repeat("p { color: red; }\n", 100)},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if tc.wantBody == "" {
+			if w.Code != http.StatusNotFound {
+				t.Errorf("GET %s got %d, want %d", tc.path, w.Code, http.StatusNotFound)
+			}
+			continue
+		}
+		if got := w.Header().Get("Content-Encoding"); got != tc.wantEncoding {
+			t.Errorf("GET %s with %q got Content-Encoding %q, want %q", tc.path, tc.acceptEncoding, got, tc.wantEncoding)
+		}
+		if got := w.Header().Get("Content-Type"); got != tc.wantContentType {
+			t.Errorf("GET %s got Content-Type %q, want %q", tc.path, got, tc.wantContentType)
+		}
+		body := string(w.Body.Bytes())
+		if !strings.HasPrefix(tc.wantBody, "precompressed") {
+			body = mustDecode(t, tc.wantEncoding, w.Body.Bytes())
+		}
+		if body != tc.wantBody {
+			t.Errorf("GET %s with %q body mismatch (-want +got):\n%s", tc.path, tc.acceptEncoding, cmp.Diff(tc.wantBody, body))
+		}
+		if got := w.Header().Values("Vary"); strings.Count(strings.Join(got, ","), "Accept-Encoding") != 1 {
+			t.Errorf("GET %s got Vary %v, want Accept-Encoding once", tc.path, got)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" || !strings.Contains(mustDecode(t, "gzip", w.Body.Bytes()), "style.css") {
+		t.Errorf("GET / got Content-Encoding %q, want a gzip directory listing", got)
+	}
+}
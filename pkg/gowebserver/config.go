@@ -16,15 +16,17 @@ package gowebserver
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/user"
 	"strconv"
 	"strings"
 	"time"
 
-	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
 )
 
@@ -32,7 +34,7 @@ var (
 	// Serving Flags
 	pathFlag       = flag.String("path", "", "Path to serve (local filesystem, git, zip, tarball files).")
 	servePathFlag  = flag.String("servepath", "/", "The HTTP/HTTPS serving root path for the hosted path.")
-	configFileFlag = flag.String("configfile", "", "YAML formatted configuration file. (overrides flag values)")
+	configFileFlag = flag.String("configfile", "", "YAML formatted configuration file. (GOWEBSERVER_* environment variables and flags take precedence)")
 	verboseFlag    = flag.Bool("verbose", false, "Print out extra information.")
 
 	// Upload Flags
@@ -42,9 +44,33 @@ var (
 	// HTTP Flags
 	httpPortFlag *int
 
+	// HTTP Limits Flags
+	readHeaderTimeoutFlag    = flag.Duration("http.limits.readheadertimeout", 10*time.Second, "Time allowed to read the request headers, 0 for no limit.")
+	readTimeoutFlag          = flag.Duration("http.limits.readtimeout", 0, "Time allowed to read the entire request including the body, 0 for no limit.")
+	writeTimeoutFlag         = flag.Duration("http.limits.writetimeout", 0, "Time allowed to write the response, 0 for no limit.")
+	idleTimeoutFlag          = flag.Duration("http.limits.idletimeout", 2*time.Minute, "Time a keep-alive connection may be idle before it is closed, 0 for no limit.")
+	maxHeaderBytesFlag       = flag.Int("http.limits.maxheaderbytes", http.DefaultMaxHeaderBytes, "Maximum size of the request headers in bytes.")
+	maxBodyBytesFlag         = flag.Int64("http.limits.maxbodybytes", 0, "Maximum size of a request body in bytes, 0 for no limit.")
+	multipartMemoryBytesFlag = flag.Int64("http.limits.multipartmemorybytes", defaultMultipartMemoryBytes, "Bytes of an upload held in memory, the rest is stored in temporary files.")
+	maxConnectionsFlag       = flag.Int("http.limits.maxconnections", 0, "Maximum number of concurrent connections per listener, 0 for no limit.")
+
+	httpAddressesFlag   = flag.String("http.addresses", "", "Comma-separated addresses to listen for HTTP on, e.g. 127.0.0.1, [::1]:8080, unix:///run/gowebserver.sock or systemd:http. Defaults to all interfaces.")
+	httpDisabledFlag    = flag.Bool("http.disabled", false, "Do not listen for HTTP connections.")
+	redirectToHTTPSFlag = flag.Bool("http.redirecttohttps", false, "Respond to all HTTP requests with a permanent redirect to HTTPS.")
+
 	// HTTPS Flags
 	httpsPortFlag *int
 
+	httpsAddressesFlag        = flag.String("https.addresses", "", "Comma-separated addresses to listen for HTTPS on, see -http.addresses. Defaults to all interfaces.")
+	httpsDisabledFlag         = flag.Bool("https.disabled", false, "Do not listen for HTTPS connections.")
+	http3Flag                 = flag.Bool("https.http3", false, "Also serve HTTP/3 (QUIC) on the UDP ports of the HTTPS listeners.")
+	hstsMaxAgeFlag            = flag.Duration("https.hsts.maxage", 0, "Send the Strict-Transport-Security header with this max-age on HTTPS responses, 0 to not send it.")
+	hstsIncludeSubdomainsFlag = flag.Bool("https.hsts.includesubdomains", false, "Add includeSubDomains to the Strict-Transport-Security header.")
+	clientAuthModeFlag        = flag.String("https.clientauth.mode", "none", "Ask HTTPS clients for a certificate: none, request (verify it if sent) or require.")
+	clientAuthCAFileFlag      = flag.String("https.clientauth.cafile", "", "PEM bundle of the CAs that issue client certificates.")
+	clientAuthIdentityFlag    = flag.String("https.clientauth.identity", "commonName", "Client certificate field that names the user: commonName, email, dns or uri.")
+	clientAuthRolesFlag       = flag.String("https.clientauth.roles", "", "Comma-separated roles of every user signed in with a client certificate.")
+
 	// HTTPS Certificate Flags
 	rootPrivateKeyFilePathFlag  = flag.String("https.certificate.rootprivatekey", "", "(optional) Root private key file path for generating derived certificates.")
 	rootCertificateFilePathFlag = flag.String("https.certificate.rootpath", "", "(optional) Root public certificate for derived certificates.")
@@ -58,9 +84,84 @@ var (
 	monitoringDebugEndpointFlag = flag.String("monitoring.debugendpoint", "", "URL path prefix for pprof and OpenTelemetry tracez debug endpoints. Leave empty to disable these endpoints.")
 	monitoringTraceURIFlag      = flag.String("monitoring.trace.uri", "", "OTLP HTTP endpoint URL for tracing (e.g. http://host:4318).")
 	monitoringMetricsPath       = flag.String("monitoring.metrics.path", "/metrics", "The URL path for exporting server metrics for Prometheus monitoring.")
+	monitoringAllowFlag         = flag.String("monitoring.ipfilter.allow", "", "Comma-separated IP addresses and CIDRs allowed to read the metrics endpoint, empty to allow all.")
+	monitoringDenyFlag          = flag.String("monitoring.ipfilter.deny", "", "Comma-separated IP addresses and CIDRs denied access to the metrics endpoint.")
+	debugAllowFlag              = flag.String("monitoring.debugipfilter.allow", "", "Comma-separated IP addresses and CIDRs allowed to use the pprof and tracez endpoints, empty to allow all.")
+	debugDenyFlag               = flag.String("monitoring.debugipfilter.deny", "", "Comma-separated IP addresses and CIDRs denied access to the pprof and tracez endpoints.")
+
+	// IP Filter Flags
+	ipFilterAllowFlag       = flag.String("ipfilter.allow", "", "Comma-separated IP addresses and CIDRs allowed to make requests, empty to allow all.")
+	ipFilterDenyFlag        = flag.String("ipfilter.deny", "", "Comma-separated IP addresses and CIDRs denied from making requests, deny takes precedence over allow.")
+	uploadIPFilterAllowFlag = flag.String("upload.ipfilter.allow", "", "Comma-separated IP addresses and CIDRs allowed to upload, empty to allow all.")
+	uploadIPFilterDenyFlag  = flag.String("upload.ipfilter.deny", "", "Comma-separated IP addresses and CIDRs denied from uploading.")
+	trustedProxiesFlag      = flag.String("clientip.trustedproxies", "", "Comma-separated IP addresses and CIDRs of reverse proxies whose client IP header is trusted.")
+	clientIPHeaderFlag      = flag.String("clientip.header", "X-Forwarded-For", "Header that trusted proxies put the client IP address in: X-Forwarded-For, X-Real-IP or Forwarded.")
+
+	// CORS Flags
+	corsDisabledFlag         = flag.Bool("cors.disabled", false, "Do not send CORS headers, cross-origin requests are then blocked by browsers.")
+	corsAllowedOriginsFlag   = flag.String("cors.allowedorigins", "", "Comma-separated origins allowed to make cross-origin requests, e.g. https://*.example.com. Defaults to all origins.")
+	corsAllowedMethodsFlag   = flag.String("cors.allowedmethods", "", "Comma-separated methods allowed in cross-origin requests. Defaults to GET, POST and HEAD.")
+	corsAllowedHeadersFlag   = flag.String("cors.allowedheaders", "", "Comma-separated non-simple headers allowed in cross-origin requests.")
+	corsExposedHeadersFlag   = flag.String("cors.exposedheaders", "", "Comma-separated response headers exposed to cross-origin requests.")
+	corsAllowCredentialsFlag = flag.Bool("cors.allowcredentials", false, "Allow cross-origin requests to include cookies and HTTP authentication.")
+	corsMaxAgeFlag           = flag.Duration("cors.maxage", 0, "How long browsers may cache the result of a preflight request, 0 for the browser default.")
+
+	// Rate Limit Flags
+	rateLimitRequestsPerSecondFlag       = flag.Float64("ratelimit.requestspersecond", 0, "Requests per second allowed for each client across the server, 0 for no limit.")
+	rateLimitBurstFlag                   = flag.Int("ratelimit.burst", 0, "Requests a client may make at once before being rate limited. Defaults to the requests per second rounded up.")
+	uploadRateLimitRequestsPerSecondFlag = flag.Float64("upload.ratelimit.requestspersecond", 0, "Uploads per second allowed for each client, 0 for no limit.")
+	uploadRateLimitBurstFlag             = flag.Int("upload.ratelimit.burst", 0, "Uploads a client may make at once before being rate limited. Defaults to the uploads per second rounded up.")
+
+	// Bandwidth Flags
+	downloadBytesPerSecondFlag              = flag.Int64("bandwidth.download.bytespersecond", 0, "Total bytes per second of the responses of all mounts, 0 for no limit.")
+	downloadPerConnectionBytesPerSecondFlag = flag.Int64("bandwidth.download.perconnectionbytespersecond", 0, "Bytes per second of the responses of each client connection, 0 for no limit.")
+	uploadBytesPerSecondFlag                = flag.Int64("bandwidth.upload.bytespersecond", 0, "Total bytes per second of all uploads, 0 for no limit.")
+	uploadPerConnectionBytesPerSecondFlag   = flag.Int64("bandwidth.upload.perconnectionbytespersecond", 0, "Bytes per second of the uploads of each client connection, 0 for no limit.")
+
+	// Auth Flags
+	htpasswdFileFlag   = flag.String("auth.htpasswdfile", "", "htpasswd file of the users that can sign in, only bcrypt (htpasswd -B) and argon2id hashes are supported.")
+	htpasswdRolesFlag  = flag.String("auth.htpasswdroles", "", "Comma-separated roles of the users of the htpasswd file.")
+	loginEndpointFlag  = flag.String("auth.loginendpoint", "/login", "URL path of the login page for browsers.")
+	sessionTimeoutFlag = flag.Duration("auth.sessiontimeout", 24*time.Hour, "How long a login page session lasts.")
+	jwtJWKSFlag        = flag.String("auth.jwt.jwks", "", "JSON Web Key Set file or URL that verifies Authorization: Bearer tokens. Leave empty to not accept bearer tokens.")
+	jwtIssuerFlag      = flag.String("auth.jwt.issuer", "", "Required iss claim of bearer tokens, empty to accept any issuer.")
+	jwtAudienceFlag    = flag.String("auth.jwt.audience", "", "Required aud claim of bearer tokens, empty to accept any audience.")
+	oidcIssuerFlag     = flag.String("auth.oidc.issuer", "", "OpenID Connect provider that browsers sign in with. Leave empty to disable single sign-on.")
+	oidcClientIDFlag   = flag.String("auth.oidc.clientid", "", "OpenID Connect client ID.")
+	oidcSecretFlag     = flag.String("auth.oidc.clientsecret", "", "OpenID Connect client secret, empty for a public client.")
+	oidcRedirectFlag   = flag.String("auth.oidc.redirecturl", "", "OpenID Connect redirect URL registered with the provider. Defaults to the callback path under the login endpoint of the requested host.")
+	oidcScopesFlag     = flag.String("auth.oidc.scopes", "openid,profile,email", "Comma-separated OpenID Connect scopes to request.")
+	claimsUsernameFlag = flag.String("auth.claims.username", "sub", "Claim of bearer and ID tokens that holds the user name.")
+	claimsRolesFlag    = flag.String("auth.claims.roles", "", "Claim of bearer and ID tokens that holds the user's roles, e.g. groups or realm_access.roles.")
+
+	// Access Log Flags
+	accessLogFormatFlag       = flag.String("accesslog.format", "", "Write an access log line per request in the common, combined, extended or json format. Leave empty to disable the access log.")
+	accessLogPathFlag         = flag.String("accesslog.path", "", "File to write the access log to, empty or - for stdout.")
+	accessLogMaxSizeBytesFlag = flag.Int64("accesslog.maxsizebytes", 0, "Rotate the access log file once it reaches this size in bytes, 0 to never rotate.")
+	accessLogMaxBackupsFlag   = flag.Int("accesslog.maxbackups", 0, "Number of rotated access log files to keep, 0 to keep all.")
+	accessLogCompressFlag     = flag.Bool("accesslog.compress", false, "Gzip rotated access log files.")
+
+	// Compression Flags
+	compressionEnabledFlag      = flag.Bool("compression.enabled", false, "Compress text responses such as HTML, CSS, JavaScript and directory listings for the clients that accept it.")
+	compressionEncodingsFlag    = flag.String("compression.encodings", "br,zstd,gzip", "Comma-separated content codings in order of preference, br, zstd and gzip are supported.")
+	compressionMinSizeBytesFlag = flag.Int64("compression.minsizebytes", 1024, "Responses smaller than this size in bytes are not compressed.")
+	compressionLevelFlag        = flag.String("compression.level", "default", "Compression level, fastest, default or best.")
+
+	// Admin Flags
+	adminEndpointFlag  = flag.String("admin.endpoint", "", "URL path prefix of the JSON admin API, e.g. /admin. Leave empty to disable the admin API.")
+	adminAddressesFlag = flag.String("admin.addresses", "", "Comma-separated addresses that serve only the admin API over HTTP, e.g. 127.0.0.1:9090 or unix:///run/gowebserver/admin.sock. Leave empty to serve it on the HTTP and HTTPS listeners.")
+	adminTokenFileFlag = flag.String("admin.tokenfile", "", "File with the bearer token of the admin API.")
+	adminRolesFlag     = flag.String("admin.roles", "", "Comma-separated roles of the users that can use the admin API, e.g. the roles of client certificates.")
+
+	// Shutdown Flags
+	drainTimeoutFlag = flag.Duration("shutdown.draintimeout", defaultDrainTimeout, "Time to wait for in-flight requests to complete on shutdown before closing their connections.")
 
 	enhancedListFlag = flag.Bool("enhancedindex", false, "Enable the enhanced directory listing UI with file previews and sorting.")
-	debugFlag        = flag.Bool("debug", false, "Expose the /diediedie shutdown endpoint for testing.")
+	debugFlag        = flag.Bool("debug", false, "Deprecated and ignored, use the shutdown of the admin API instead of /diediedie.")
+	validateFlag     = flag.Bool("validate", false, "Validate the configuration, print every problem found and exit with a non-zero status if any.")
+	printConfigFlag  = flag.Bool("print-config", false, "Print the effective configuration annotated with the source (default, file, env or flag) of each value and exit.")
+	configSchemaFlag = flag.Bool("config-schema", false, "Print the JSON Schema of the YAML configuration file and exit.")
+	hashPasswordFlag = flag.Bool("hash-password", false, "Read a password from stdin, print its bcrypt hash for auth.users and exit.")
 
 	version = "UNKNOWN"
 )
@@ -68,12 +169,82 @@ var (
 // HTTP holds the configuration for HTTP serving.
 type HTTP struct {
 	Port int `yaml:"port"`
+	// Addresses to listen on, such as 127.0.0.1, [::1]:8080,
+	// unix:///run/gowebserver.sock or systemd:http for a socket passed by
+	// systemd socket activation. Addresses without a port use Port. All
+	// interfaces are used if empty.
+	Addresses []string `yaml:"addresses,omitempty"`
+	// UnixSocketMode is the octal file mode of Unix domain sockets, e.g. 0660.
+	UnixSocketMode string `yaml:"unixSocketMode,omitempty"`
+	// Disabled turns off the HTTP listener.
+	Disabled bool `yaml:"disabled"`
+	// RedirectToHTTPS responds to every HTTP request with a 308 redirect to the
+	// same URL on the HTTPS listener.
+	RedirectToHTTPS bool   `yaml:"redirectToHTTPS"`
+	Limits          Limits `yaml:"limits"`
+}
+
+// Limits holds the timeouts and size limits of the HTTP and HTTPS servers. A
+// zero value means no limit.
+type Limits struct {
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	MaxHeaderBytes    int           `yaml:"maxHeaderBytes"`
+	// MaxBodyBytes is the maximum size of a request body.
+	MaxBodyBytes int64 `yaml:"maxBodyBytes"`
+	// EndpointMaxBodyBytes overrides MaxBodyBytes for the URL paths under each
+	// endpoint, e.g. "/upload.asp": 1073741824. The longest endpoint wins.
+	EndpointMaxBodyBytes map[string]int64 `yaml:"endpointMaxBodyBytes,omitempty"`
+	// MultipartMemoryBytes is how much of an upload is held in memory, zero uses
+	// the default of 32MiB.
+	MultipartMemoryBytes int64 `yaml:"multipartMemoryBytes"`
+	// MaxConnections is the maximum number of concurrent connections per
+	// listener, connections over the limit are closed right away.
+	MaxConnections int `yaml:"maxConnections"`
 }
 
 // HTTPS holds the configuration for HTTPS serving.
 type HTTPS struct {
-	Port        int         `yaml:"port"`
+	Port int `yaml:"port"`
+	// Addresses to listen on, see HTTP.Addresses.
+	Addresses []string `yaml:"addresses,omitempty"`
+	// UnixSocketMode is the octal file mode of Unix domain sockets, e.g. 0660.
+	UnixSocketMode string `yaml:"unixSocketMode,omitempty"`
+	// Disabled turns off the HTTPS listener.
+	Disabled bool `yaml:"disabled"`
+	// HTTP3 serves HTTP/3 over QUIC on the UDP ports of the HTTPS listeners and
+	// advertises it with the Alt-Svc header.
+	HTTP3       bool        `yaml:"http3"`
 	Certificate Certificate `yaml:"certificate"`
+	HSTS        HSTS        `yaml:"hsts"`
+	ClientAuth  ClientAuth  `yaml:"clientAuth"`
+}
+
+// ClientAuth asks HTTPS clients for certificates issued by the CA bundle. The
+// certificate names the user for the mounts' access rules, the user has Roles
+// and the roles that Users maps its name to.
+type ClientAuth struct {
+	// Mode is none, request to verify certificates that clients send or
+	// require to reject clients without one.
+	Mode   string `yaml:"mode"`
+	CAFile string `yaml:"caFile"`
+	// Identity is the certificate field that names the user, commonName or
+	// the first email, dns or uri subject alternative name.
+	Identity string `yaml:"identity"`
+	// Roles are granted to every client with a verified certificate.
+	Roles []string `yaml:"roles,omitempty"`
+	// Users maps certificate identities to additional roles. Certificate
+	// identities are separate from the users of Auth.Users.
+	Users map[string][]string `yaml:"users,omitempty"`
+}
+
+// HSTS holds the Strict-Transport-Security header settings.
+type HSTS struct {
+	// MaxAge of the policy, the header is not sent if zero.
+	MaxAge            time.Duration `yaml:"maxAge"`
+	IncludeSubdomains bool          `yaml:"includeSubdomains"`
 }
 
 // Certificate holds the certificate/private key configuration for HTTPS.
@@ -92,6 +263,11 @@ type Monitoring struct {
 	DebugEndpoint string  `yaml:"debugEndpoint"`
 	Metrics       Metrics `yaml:"metrics"`
 	Trace         Trace   `yaml:"trace"`
+	// IPFilter restricts the clients that can read the metrics endpoint.
+	IPFilter IPFilter `yaml:"ipFilter,omitempty"`
+	// DebugIPFilter restricts the clients that can use the pprof and tracez
+	// endpoints.
+	DebugIPFilter IPFilter `yaml:"debugIPFilter,omitempty"`
 }
 
 // Trace holds the trace configuration.
@@ -106,18 +282,206 @@ type Metrics struct {
 	Path    string `yaml:"path"`
 }
 
+// Shutdown holds the graceful shutdown configuration.
+type Shutdown struct {
+	// DrainTimeout is how long in-flight requests may run after shutdown begins,
+	// zero uses the default of 30s.
+	DrainTimeout time.Duration `yaml:"drainTimeout"`
+}
+
 // Config is the root of the server configuration.
 type Config struct {
 	Verbose           bool    `yaml:"verbose"`
 	Serve             []Serve `yaml:"serve"`
 	ConfigurationFile string  `yaml:"-"`
 	EnhancedList      bool    `yaml:"enhancedList"`
-	Debug             bool    `yaml:"debug"`
+	// Debug is ignored, it exposed the /diediedie endpoint that the admin API
+	// replaces.
+	Debug bool `yaml:"debug"`
 
 	HTTP       HTTP       `yaml:"http"`
 	HTTPS      HTTPS      `yaml:"https"`
 	Monitoring Monitoring `yaml:"monitoring"`
 	Upload     Serve      `yaml:"upload"`
+	Shutdown   Shutdown   `yaml:"shutdown"`
+	CORS       CORS       `yaml:"cors"`
+	AccessLog  AccessLog  `yaml:"accessLog"`
+	// RateLimit limits the requests of each client to the whole server, mounts
+	// and the upload endpoint can have additional limits.
+	RateLimit RateLimit `yaml:"rateLimit"`
+	Bandwidth Bandwidth `yaml:"bandwidth"`
+	Auth      Auth      `yaml:"auth"`
+	// IPFilter restricts the clients of the whole server, mounts, the upload
+	// endpoint and the monitoring endpoints can have additional filters.
+	IPFilter IPFilter `yaml:"ipFilter,omitempty"`
+	ClientIP ClientIP `yaml:"clientIP"`
+	Admin    Admin    `yaml:"admin"`
+	// Compression compresses the responses of the mounts, see
+	// Serve.Precompressed for precompressed files.
+	Compression Compression `yaml:"compression"`
+}
+
+// Compression negotiates a content coding with the clients and compresses the
+// responses of compressible types, such as text/*, that are at least
+// MinSizeBytes long.
+type Compression struct {
+	Enabled bool `yaml:"enabled"`
+	// Encodings are the content codings in order of preference, used to
+	// break ties in the client's Accept-Encoding header.
+	Encodings    []string `yaml:"encodings,omitempty"`
+	MinSizeBytes int64    `yaml:"minSizeBytes"`
+	// Level is fastest, default or best.
+	Level string `yaml:"level"`
+}
+
+// Admin is the JSON admin API that shuts down and reloads the server, lists
+// its mounts and connections, changes the log level and reloads the
+// certificate. Callers need the bearer token of TokenFile or one of Roles.
+type Admin struct {
+	// Endpoint is the path prefix of the API, e.g. /admin, the API is
+	// disabled if empty.
+	Endpoint string `yaml:"endpoint"`
+	// Addresses serve only the admin API over HTTP, it is then not served on
+	// the HTTP and HTTPS listeners.
+	Addresses []string `yaml:"addresses,omitempty"`
+	TokenFile string   `yaml:"tokenFile"`
+	Roles     []string `yaml:"roles,omitempty"`
+}
+
+// IPFilter allows or denies clients by IP address or CIDR range, such as
+// "192.0.2.1" or "10.0.0.0/8". Denied clients are rejected with 403 Forbidden
+// even if they are also allowed, and an empty Allow list allows all clients
+// that are not denied.
+type IPFilter struct {
+	Allow []string `yaml:"allow,omitempty"`
+	Deny  []string `yaml:"deny,omitempty"`
+}
+
+// ClientIP resolves the IP address of clients behind reverse proxies.
+type ClientIP struct {
+	// TrustedProxies are the IP addresses and CIDRs of the proxies, Header is
+	// only read from requests they send.
+	TrustedProxies []string `yaml:"trustedProxies,omitempty"`
+	// Header is X-Forwarded-For, X-Real-IP or Forwarded.
+	Header string `yaml:"header"`
+}
+
+// Auth signs users in with HTTP Basic auth, bearer tokens or, for browsers, a
+// login page and OpenID Connect.
+// Serve.Access grants the permissions on each mount to the users' roles.
+type Auth struct {
+	Users []User `yaml:"users,omitempty"`
+	// HtpasswdFile is an htpasswd file of more users.
+	HtpasswdFile string `yaml:"htpasswdFile"`
+	// HtpasswdRoles are the roles of the users of HtpasswdFile.
+	HtpasswdRoles  []string      `yaml:"htpasswdRoles,omitempty"`
+	LoginEndpoint  string        `yaml:"loginEndpoint"`
+	SessionTimeout time.Duration `yaml:"sessionTimeout"`
+	// JWT accepts the Authorization: Bearer tokens of other services.
+	JWT JWT `yaml:"jwt"`
+	// OIDC signs browsers in with an OpenID Connect provider.
+	OIDC OIDC `yaml:"oidc"`
+	// Claims maps the claims of bearer and ID tokens to users and roles.
+	Claims Claims `yaml:"claims"`
+}
+
+// JWT verifies bearer tokens against a JSON Web Key Set.
+type JWT struct {
+	// JWKS is a file or URL of the JSON Web Key Set, URLs are fetched again
+	// every hour and when a token is signed by an unknown key.
+	JWKS     string `yaml:"jwks"`
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+}
+
+// OIDC is an OpenID Connect provider that browsers sign in with using the
+// authorization code flow with PKCE.
+type OIDC struct {
+	Issuer       string `yaml:"issuer"`
+	ClientID     string `yaml:"clientId"`
+	ClientSecret string `yaml:"clientSecret"`
+	// RedirectURL must be registered with the provider, it defaults to the
+	// callback path under the login endpoint.
+	RedirectURL string   `yaml:"redirectUrl"`
+	Scopes      []string `yaml:"scopes,omitempty"`
+}
+
+// Claims maps token claims to the user name and roles. Roles is a claim path
+// such as groups or realm_access.roles, its values are the roles unless
+// RoleMapping maps them to other roles.
+type Claims struct {
+	Username    string              `yaml:"username"`
+	Roles       string              `yaml:"roles"`
+	RoleMapping map[string][]string `yaml:"roleMapping,omitempty"`
+}
+
+// User is a user that can sign in.
+type User struct {
+	Name string `yaml:"name"`
+	// PasswordHash is a bcrypt or argon2id hash of the password, see
+	// -hash-password.
+	PasswordHash string   `yaml:"passwordHash"`
+	Roles        []string `yaml:"roles,omitempty"`
+}
+
+// Bandwidth limits the transfer rate of the response bodies of the mounts,
+// including proxied HTTP sources, and of the request bodies of uploads.
+type Bandwidth struct {
+	Download BandwidthLimit `yaml:"download"`
+	Upload   BandwidthLimit `yaml:"upload"`
+}
+
+// BandwidthLimit is a transfer rate limit in bytes per second, zero for no
+// limit.
+type BandwidthLimit struct {
+	// BytesPerSecond is shared by all transfers.
+	BytesPerSecond int64 `yaml:"bytesPerSecond"`
+	// PerConnectionBytesPerSecond is shared by the transfers of each client
+	// connection.
+	PerConnectionBytesPerSecond int64 `yaml:"perConnectionBytesPerSecond"`
+}
+
+// RateLimit is a token bucket limit on the requests of each client. Clients
+// are identified by their authenticated user or their IP address.
+type RateLimit struct {
+	// RequestsPerSecond is the sustained request rate, zero for no limit.
+	RequestsPerSecond float64 `yaml:"requestsPerSecond"`
+	// Burst is the number of requests allowed at once, zero uses
+	// RequestsPerSecond rounded up.
+	Burst int `yaml:"burst"`
+}
+
+// AccessLog holds the per-request access log configuration.
+type AccessLog struct {
+	// Format is "common", "combined", "extended" or "json", the access log is
+	// disabled if empty. Only "extended" and "json" have the duration, mount
+	// and trace ID of the requests.
+	Format string `yaml:"format"`
+	// Path of the log file, empty or "-" writes to stdout.
+	Path string `yaml:"path"`
+	// MaxSizeBytes rotates the log file once it reaches this size, zero never
+	// rotates.
+	MaxSizeBytes int64 `yaml:"maxSizeBytes"`
+	// MaxBackups is the number of rotated log files to keep, zero keeps all.
+	MaxBackups int `yaml:"maxBackups"`
+	// Compress gzips the rotated log files.
+	Compress bool `yaml:"compress"`
+}
+
+// CORS holds the Cross-Origin Resource Sharing policy. The zero value allows
+// simple requests from any origin.
+type CORS struct {
+	// Disabled turns off CORS, no Access-Control-* headers are sent.
+	Disabled bool `yaml:"disabled"`
+	// AllowedOrigins such as "https://example.com" or "https://*.example.com",
+	// all origins are allowed if empty.
+	AllowedOrigins []string `yaml:"allowedOrigins,omitempty"`
+	// AllowedMethods defaults to GET, POST and HEAD.
+	AllowedMethods   []string      `yaml:"allowedMethods,omitempty"`
+	AllowedHeaders   []string      `yaml:"allowedHeaders,omitempty"`
+	ExposedHeaders   []string      `yaml:"exposedHeaders,omitempty"`
+	AllowCredentials bool          `yaml:"allowCredentials"`
+	MaxAge           time.Duration `yaml:"maxAge"`
 }
 
 // Serve maps the source to endpoint serving of content.
@@ -126,6 +490,84 @@ type Serve struct {
 	Source string `yaml:"source"`
 	// Endpoint on the HTTP server to serve the content.
 	Endpoint string `yaml:"endpoint"`
+	// Hosts restricts the mount to requests for these hostnames, e.g.
+	// "docs.lan" or "*.example.com". Mounts without hosts form the default
+	// site that serves all other hostnames.
+	Hosts []string `yaml:"hosts,omitempty"`
+	// EnhancedList overrides Config.EnhancedList for this mount.
+	EnhancedList *bool `yaml:"enhancedList,omitempty"`
+	// RichView enables the syntax highlighted file view, enabled if not set.
+	RichView *bool `yaml:"richView,omitempty"`
+	// DisableListing prevents directory contents from being listed. A
+	// directory's index.html is still served.
+	DisableListing bool `yaml:"disableListing,omitempty"`
+	// CacheControl is the Cache-Control header set on responses of this mount.
+	CacheControl string `yaml:"cacheControl,omitempty"`
+	// Cache are rules that set the Cache-Control header of the successful
+	// responses matching them, overriding CacheControl. The first matching
+	// rule applies.
+	Cache []CacheRule `yaml:"cache,omitempty"`
+	// ETag is "content" for strong ETags from a hash of the file contents,
+	// "off" for none besides the modification time or "auto", the default,
+	// which hashes the contents unless the source is a local directory.
+	ETag string `yaml:"etag,omitempty"`
+	// Hidden is a list of glob patterns, such as ".*", for file and directory
+	// names that are not listed and cannot be downloaded.
+	Hidden []string `yaml:"hidden,omitempty"`
+	// ReadOnly rejects all requests except GET, HEAD and OPTIONS.
+	ReadOnly bool `yaml:"readOnly,omitempty"`
+	// CORS overrides Config.CORS for this mount.
+	CORS *CORS `yaml:"cors,omitempty"`
+	// Headers are rules that add or remove response headers.
+	Headers []HeaderRule `yaml:"headers,omitempty"`
+	// RateLimit limits the requests of each client to this mount, in addition
+	// to Config.RateLimit.
+	RateLimit RateLimit `yaml:"rateLimit,omitempty"`
+	// IPFilter restricts the clients of this mount, in addition to
+	// Config.IPFilter.
+	IPFilter IPFilter `yaml:"ipFilter,omitempty"`
+	// Access maps roles to the permissions (read, upload, delete or admin)
+	// they have on this mount. The built-in anonymous role applies to every
+	// client and authenticated to every signed in user. Mounts without access
+	// rules are public.
+	Access map[string][]string `yaml:"access,omitempty"`
+	// Precompressed serves the precompressed siblings of files, such as
+	// app.js.br, app.js.zst or app.js.gz, to the clients that accept them.
+	Precompressed bool `yaml:"precompressed,omitempty"`
+}
+
+// CacheRule sets the Cache-Control header of the responses matching Path.
+type CacheRule struct {
+	// Path is a glob pattern, such as "*.js" or "/assets/*", matched against
+	// the request path within the mount. Patterns without a "/" match the
+	// file name.
+	Path string `yaml:"path,omitempty"`
+	// Hashed only matches file names with a content hash, such as
+	// app.3f9a2c1e.js or index-BZt3Qk9a.css, which can be cached as
+	// immutable.
+	Hashed bool `yaml:"hashed,omitempty"`
+	// CacheControl is the Cache-Control header value, such as
+	// "public, max-age=31536000, immutable".
+	CacheControl string `yaml:"cacheControl"`
+}
+
+// HeaderRule sets and removes response headers for the requests matching both
+// Path and ContentType, an empty pattern matches everything.
+type HeaderRule struct {
+	// Path is a glob pattern, such as "*.html" or "/assets/*", matched against
+	// the request path within the mount. Patterns without a "/" match the
+	// file name.
+	Path string `yaml:"path,omitempty"`
+	// ContentType is a glob pattern matched against the media type of the
+	// response, such as "text/html" or "image/*".
+	ContentType string `yaml:"contentType,omitempty"`
+	// Preset adds a predefined set of headers, "secure" for
+	// Content-Security-Policy, X-Frame-Options and similar headers.
+	Preset string `yaml:"preset,omitempty"`
+	// Set are the headers to set, overriding the preset.
+	Set map[string]string `yaml:"set,omitempty"`
+	// Remove are the headers to remove.
+	Remove []string `yaml:"remove,omitempty"`
 }
 
 // String returns a string representation of the config.
@@ -140,34 +582,304 @@ func (c *Config) String() string {
 	return b.String()
 }
 
+// clone returns a deep copy of the configuration.
+func (c *Config) clone() (*Config, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("cannot copy the configuration, %w", err)
+	}
+	dst := &Config{}
+	if err := yaml.Unmarshal(data, dst); err != nil {
+		return nil, fmt.Errorf("cannot copy the configuration, %w", err)
+	}
+	// The fields that are not in the YAML.
+	dst.ConfigurationFile = c.ConfigurationFile
+	dst.HTTPS.Certificate.ForceOverwrite = c.HTTPS.Certificate.ForceOverwrite
+	return dst, nil
+}
+
 // Load loads the configuration for the server.
 func Load() (*Config, error) {
 	flag.Parse()
-	conf, err := loadFromFlags()
+	return loadAndValidate(nil, *configFileFlag)
+}
+
+// loadAndValidate builds the layered configuration and validates the result.
+// The precedence is base < configuration file < environment < flags, a nil
+// base is the defaults of the flags. All decoding and validation problems are
+// returned together as ConfigErrors.
+func loadAndValidate(base *Config, configFile string) (*Config, error) {
+	conf, _, err := loadLayeredWithProvenance(base, configFile, os.Environ(), flag.Visit)
+	return conf, err
+}
+
+func loadLayered(configFile string, environ []string, visitSetFlags func(func(*flag.Flag))) (*Config, error) {
+	conf, _, err := loadLayeredWithProvenance(nil, configFile, environ, visitSetFlags)
+	return conf, err
+}
+
+// loadLayeredWithProvenance is loadLayered on top of base that also reports
+// which layer each configuration field was taken from. base is not modified.
+func loadLayeredWithProvenance(base *Config, configFile string, environ []string, visitSetFlags func(func(*flag.Flag))) (*Config, provenance, error) {
+	flagConf, err := loadFromFlags()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	conf := base
+	if conf == nil {
+		conf, err = loadFromFlags()
+	} else {
+		conf, err = conf.clone()
+	}
+	if err != nil {
+		return nil, nil, err
 	}
-	if *configFileFlag != "" {
-		err := loadWithConfigFile(*configFileFlag, conf)
+
+	prov := provenance{}
+	errs := ConfigErrors{}
+	var doc *yaml.Node
+	if configFile != "" {
+		doc, err = decodeConfigFile(configFile, conf)
 		if err != nil {
-			zap.S().With("error", err, "configFile", *configFileFlag).Warn("Error Loading File")
+			if doc == nil {
+				return nil, nil, fmt.Errorf("cannot load configuration file '%s', %w", configFile, err)
+			}
+			errs = append(errs, decodeErrorsToConfigErrors(err)...)
+		}
+		prov.setFromYAML(doc, sourceFile)
+	}
+
+	if err := applyEnvironment(conf, environ, prov); err != nil {
+		var envErrs ConfigErrors
+		if errors.As(err, &envErrs) {
+			errs = append(errs, envErrs...)
 		}
 	}
-	return conf, nil
+
+	visitSetFlags(func(f *flag.Flag) {
+		if override, ok := flagOverrides[f.Name]; ok {
+			override.apply(conf, flagConf)
+			prov.set(override.field, sourceFlag)
+		}
+	})
+
+	if err := conf.validate(doc); err != nil {
+		var validateErrs ConfigErrors
+		if errors.As(err, &validateErrs) {
+			errs = append(errs, validateErrs...)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+	return conf, prov, nil
 }
 
 func loadWithConfigFile(filePath string, conf *Config) error {
+	_, err := decodeConfigFile(filePath, conf)
+	return err
+}
+
+// decodeConfigFile strictly decodes the configuration file into conf, unknown
+// fields are reported as errors. The parsed YAML document is returned so that
+// problems can be reported with line numbers, it is nil if the file cannot be
+// read or is not valid YAML.
+func decodeConfigFile(filePath string, conf *Config) (*yaml.Node, error) {
+	defer func() {
+		conf.ConfigurationFile = filePath
+	}()
+
 	contents, err := os.ReadFile(filePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = yaml.Unmarshal(contents, &conf)
-	// Config file should always be the flag value.
-	conf.ConfigurationFile = *configFileFlag
-	if err != nil {
-		return err
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(contents, doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return doc, nil
+	}
+
+	d := yaml.NewDecoder(bytes.NewReader(contents))
+	d.KnownFields(true)
+	if err := d.Decode(conf); err != nil && !errors.Is(err, io.EOF) {
+		return doc, err
 	}
-	return nil
+	return doc, nil
+}
+
+// flagOverride copies the value of an explicitly set flag from the
+// flag-derived configuration so that it takes precedence over the
+// configuration file and the environment.
+type flagOverride struct {
+	// field is the YAML path of the configuration field set by the flag.
+	field string
+	apply func(dst *Config, src *Config)
+}
+
+var flagOverrides = map[string]flagOverride{
+	"path":      {"serve", func(dst *Config, src *Config) { dst.Serve = src.Serve }},
+	"servepath": {"serve", func(dst *Config, src *Config) { dst.Serve = src.Serve }},
+	"verbose":   {"verbose", func(dst *Config, src *Config) { dst.Verbose = src.Verbose }},
+
+	"upload.path":     {"upload.source", func(dst *Config, src *Config) { dst.Upload.Source = src.Upload.Source }},
+	"upload.httppath": {"upload.endpoint", func(dst *Config, src *Config) { dst.Upload.Endpoint = src.Upload.Endpoint }},
+
+	"http.port":  {"http.port", func(dst *Config, src *Config) { dst.HTTP.Port = src.HTTP.Port }},
+	"https.port": {"https.port", func(dst *Config, src *Config) { dst.HTTPS.Port = src.HTTPS.Port }},
+
+	"http.addresses":       {"http.addresses", func(dst *Config, src *Config) { dst.HTTP.Addresses = src.HTTP.Addresses }},
+	"https.addresses":      {"https.addresses", func(dst *Config, src *Config) { dst.HTTPS.Addresses = src.HTTPS.Addresses }},
+	"http.disabled":        {"http.disabled", func(dst *Config, src *Config) { dst.HTTP.Disabled = src.HTTP.Disabled }},
+	"http.redirecttohttps": {"http.redirectToHTTPS", func(dst *Config, src *Config) { dst.HTTP.RedirectToHTTPS = src.HTTP.RedirectToHTTPS }},
+	"https.disabled":       {"https.disabled", func(dst *Config, src *Config) { dst.HTTPS.Disabled = src.HTTPS.Disabled }},
+	"https.http3":          {"https.http3", func(dst *Config, src *Config) { dst.HTTPS.HTTP3 = src.HTTPS.HTTP3 }},
+	"https.hsts.maxage":    {"https.hsts.maxAge", func(dst *Config, src *Config) { dst.HTTPS.HSTS.MaxAge = src.HTTPS.HSTS.MaxAge }},
+	"https.hsts.includesubdomains": {"https.hsts.includeSubdomains", func(dst *Config, src *Config) {
+		dst.HTTPS.HSTS.IncludeSubdomains = src.HTTPS.HSTS.IncludeSubdomains
+	}},
+	"https.clientauth.mode":     {"https.clientAuth.mode", func(dst *Config, src *Config) { dst.HTTPS.ClientAuth.Mode = src.HTTPS.ClientAuth.Mode }},
+	"https.clientauth.cafile":   {"https.clientAuth.caFile", func(dst *Config, src *Config) { dst.HTTPS.ClientAuth.CAFile = src.HTTPS.ClientAuth.CAFile }},
+	"https.clientauth.identity": {"https.clientAuth.identity", func(dst *Config, src *Config) { dst.HTTPS.ClientAuth.Identity = src.HTTPS.ClientAuth.Identity }},
+	"https.clientauth.roles":    {"https.clientAuth.roles", func(dst *Config, src *Config) { dst.HTTPS.ClientAuth.Roles = src.HTTPS.ClientAuth.Roles }},
+
+	"http.limits.readheadertimeout": {"http.limits.readHeaderTimeout", func(dst *Config, src *Config) {
+		dst.HTTP.Limits.ReadHeaderTimeout = src.HTTP.Limits.ReadHeaderTimeout
+	}},
+	"http.limits.readtimeout": {"http.limits.readTimeout", func(dst *Config, src *Config) {
+		dst.HTTP.Limits.ReadTimeout = src.HTTP.Limits.ReadTimeout
+	}},
+	"http.limits.writetimeout": {"http.limits.writeTimeout", func(dst *Config, src *Config) {
+		dst.HTTP.Limits.WriteTimeout = src.HTTP.Limits.WriteTimeout
+	}},
+	"http.limits.idletimeout": {"http.limits.idleTimeout", func(dst *Config, src *Config) {
+		dst.HTTP.Limits.IdleTimeout = src.HTTP.Limits.IdleTimeout
+	}},
+	"http.limits.maxheaderbytes": {"http.limits.maxHeaderBytes", func(dst *Config, src *Config) {
+		dst.HTTP.Limits.MaxHeaderBytes = src.HTTP.Limits.MaxHeaderBytes
+	}},
+	"http.limits.maxbodybytes": {"http.limits.maxBodyBytes", func(dst *Config, src *Config) {
+		dst.HTTP.Limits.MaxBodyBytes = src.HTTP.Limits.MaxBodyBytes
+	}},
+	"http.limits.multipartmemorybytes": {"http.limits.multipartMemoryBytes", func(dst *Config, src *Config) {
+		dst.HTTP.Limits.MultipartMemoryBytes = src.HTTP.Limits.MultipartMemoryBytes
+	}},
+	"http.limits.maxconnections": {"http.limits.maxConnections", func(dst *Config, src *Config) {
+		dst.HTTP.Limits.MaxConnections = src.HTTP.Limits.MaxConnections
+	}},
+
+	"https.certificate.rootprivatekey": {"https.certificate.rootPrivateKey", func(dst *Config, src *Config) {
+		dst.HTTPS.Certificate.RootPrivateKeyFilePath = src.HTTPS.Certificate.RootPrivateKeyFilePath
+	}},
+	"https.certificate.rootpath": {"https.certificate.rootPath", func(dst *Config, src *Config) {
+		dst.HTTPS.Certificate.RootCertificateFilePath = src.HTTPS.Certificate.RootCertificateFilePath
+	}},
+	"https.certificate.privatekey": {"https.certificate.privateKey", func(dst *Config, src *Config) {
+		dst.HTTPS.Certificate.PrivateKeyFilePath = src.HTTPS.Certificate.PrivateKeyFilePath
+	}},
+	"https.certificate.path": {"https.certificate.path", func(dst *Config, src *Config) {
+		dst.HTTPS.Certificate.CertificateFilePath = src.HTTPS.Certificate.CertificateFilePath
+	}},
+	"https.certificate.hosts": {"https.certificate.hosts", func(dst *Config, src *Config) {
+		dst.HTTPS.Certificate.CertificateHosts = src.HTTPS.Certificate.CertificateHosts
+	}},
+	"https.certificate.duration": {"https.certificate.duration", func(dst *Config, src *Config) {
+		dst.HTTPS.Certificate.CertificateValidDuration = src.HTTPS.Certificate.CertificateValidDuration
+	}},
+
+	"monitoring.debugendpoint": {"monitoring.debugEndpoint", func(dst *Config, src *Config) { dst.Monitoring.DebugEndpoint = src.Monitoring.DebugEndpoint }},
+	"monitoring.trace.uri":     {"monitoring.trace", func(dst *Config, src *Config) { dst.Monitoring.Trace = src.Monitoring.Trace }},
+	"monitoring.metrics.path":  {"monitoring.metrics", func(dst *Config, src *Config) { dst.Monitoring.Metrics = src.Monitoring.Metrics }},
+	"monitoring.ipfilter.allow": {"monitoring.ipFilter.allow", func(dst *Config, src *Config) {
+		dst.Monitoring.IPFilter.Allow = src.Monitoring.IPFilter.Allow
+	}},
+	"monitoring.ipfilter.deny": {"monitoring.ipFilter.deny", func(dst *Config, src *Config) {
+		dst.Monitoring.IPFilter.Deny = src.Monitoring.IPFilter.Deny
+	}},
+	"monitoring.debugipfilter.allow": {"monitoring.debugIPFilter.allow", func(dst *Config, src *Config) {
+		dst.Monitoring.DebugIPFilter.Allow = src.Monitoring.DebugIPFilter.Allow
+	}},
+	"monitoring.debugipfilter.deny": {"monitoring.debugIPFilter.deny", func(dst *Config, src *Config) {
+		dst.Monitoring.DebugIPFilter.Deny = src.Monitoring.DebugIPFilter.Deny
+	}},
+
+	"ipfilter.allow":          {"ipFilter.allow", func(dst *Config, src *Config) { dst.IPFilter.Allow = src.IPFilter.Allow }},
+	"ipfilter.deny":           {"ipFilter.deny", func(dst *Config, src *Config) { dst.IPFilter.Deny = src.IPFilter.Deny }},
+	"upload.ipfilter.allow":   {"upload.ipFilter.allow", func(dst *Config, src *Config) { dst.Upload.IPFilter.Allow = src.Upload.IPFilter.Allow }},
+	"upload.ipfilter.deny":    {"upload.ipFilter.deny", func(dst *Config, src *Config) { dst.Upload.IPFilter.Deny = src.Upload.IPFilter.Deny }},
+	"clientip.trustedproxies": {"clientIP.trustedProxies", func(dst *Config, src *Config) { dst.ClientIP.TrustedProxies = src.ClientIP.TrustedProxies }},
+	"clientip.header":         {"clientIP.header", func(dst *Config, src *Config) { dst.ClientIP.Header = src.ClientIP.Header }},
+
+	"shutdown.draintimeout": {"shutdown.drainTimeout", func(dst *Config, src *Config) { dst.Shutdown.DrainTimeout = src.Shutdown.DrainTimeout }},
+
+	"cors.disabled":         {"cors.disabled", func(dst *Config, src *Config) { dst.CORS.Disabled = src.CORS.Disabled }},
+	"cors.allowedorigins":   {"cors.allowedOrigins", func(dst *Config, src *Config) { dst.CORS.AllowedOrigins = src.CORS.AllowedOrigins }},
+	"cors.allowedmethods":   {"cors.allowedMethods", func(dst *Config, src *Config) { dst.CORS.AllowedMethods = src.CORS.AllowedMethods }},
+	"cors.allowedheaders":   {"cors.allowedHeaders", func(dst *Config, src *Config) { dst.CORS.AllowedHeaders = src.CORS.AllowedHeaders }},
+	"cors.exposedheaders":   {"cors.exposedHeaders", func(dst *Config, src *Config) { dst.CORS.ExposedHeaders = src.CORS.ExposedHeaders }},
+	"cors.allowcredentials": {"cors.allowCredentials", func(dst *Config, src *Config) { dst.CORS.AllowCredentials = src.CORS.AllowCredentials }},
+	"cors.maxage":           {"cors.maxAge", func(dst *Config, src *Config) { dst.CORS.MaxAge = src.CORS.MaxAge }},
+
+	"accesslog.format":       {"accessLog.format", func(dst *Config, src *Config) { dst.AccessLog.Format = src.AccessLog.Format }},
+	"accesslog.path":         {"accessLog.path", func(dst *Config, src *Config) { dst.AccessLog.Path = src.AccessLog.Path }},
+	"accesslog.maxsizebytes": {"accessLog.maxSizeBytes", func(dst *Config, src *Config) { dst.AccessLog.MaxSizeBytes = src.AccessLog.MaxSizeBytes }},
+	"accesslog.maxbackups":   {"accessLog.maxBackups", func(dst *Config, src *Config) { dst.AccessLog.MaxBackups = src.AccessLog.MaxBackups }},
+	"accesslog.compress":     {"accessLog.compress", func(dst *Config, src *Config) { dst.AccessLog.Compress = src.AccessLog.Compress }},
+
+	"ratelimit.requestspersecond": {"rateLimit.requestsPerSecond", func(dst *Config, src *Config) {
+		dst.RateLimit.RequestsPerSecond = src.RateLimit.RequestsPerSecond
+	}},
+	"ratelimit.burst": {"rateLimit.burst", func(dst *Config, src *Config) { dst.RateLimit.Burst = src.RateLimit.Burst }},
+	"upload.ratelimit.requestspersecond": {"upload.rateLimit.requestsPerSecond", func(dst *Config, src *Config) {
+		dst.Upload.RateLimit.RequestsPerSecond = src.Upload.RateLimit.RequestsPerSecond
+	}},
+	"upload.ratelimit.burst": {"upload.rateLimit.burst", func(dst *Config, src *Config) { dst.Upload.RateLimit.Burst = src.Upload.RateLimit.Burst }},
+
+	"bandwidth.download.bytespersecond": {"bandwidth.download.bytesPerSecond", func(dst *Config, src *Config) {
+		dst.Bandwidth.Download.BytesPerSecond = src.Bandwidth.Download.BytesPerSecond
+	}},
+	"bandwidth.download.perconnectionbytespersecond": {"bandwidth.download.perConnectionBytesPerSecond", func(dst *Config, src *Config) {
+		dst.Bandwidth.Download.PerConnectionBytesPerSecond = src.Bandwidth.Download.PerConnectionBytesPerSecond
+	}},
+	"bandwidth.upload.bytespersecond": {"bandwidth.upload.bytesPerSecond", func(dst *Config, src *Config) {
+		dst.Bandwidth.Upload.BytesPerSecond = src.Bandwidth.Upload.BytesPerSecond
+	}},
+	"bandwidth.upload.perconnectionbytespersecond": {"bandwidth.upload.perConnectionBytesPerSecond", func(dst *Config, src *Config) {
+		dst.Bandwidth.Upload.PerConnectionBytesPerSecond = src.Bandwidth.Upload.PerConnectionBytesPerSecond
+	}},
+
+	"auth.htpasswdfile":   {"auth.htpasswdFile", func(dst *Config, src *Config) { dst.Auth.HtpasswdFile = src.Auth.HtpasswdFile }},
+	"auth.htpasswdroles":  {"auth.htpasswdRoles", func(dst *Config, src *Config) { dst.Auth.HtpasswdRoles = src.Auth.HtpasswdRoles }},
+	"auth.loginendpoint":  {"auth.loginEndpoint", func(dst *Config, src *Config) { dst.Auth.LoginEndpoint = src.Auth.LoginEndpoint }},
+	"auth.sessiontimeout": {"auth.sessionTimeout", func(dst *Config, src *Config) { dst.Auth.SessionTimeout = src.Auth.SessionTimeout }},
+	"auth.jwt.jwks":       {"auth.jwt.jwks", func(dst *Config, src *Config) { dst.Auth.JWT.JWKS = src.Auth.JWT.JWKS }},
+	"auth.jwt.issuer":     {"auth.jwt.issuer", func(dst *Config, src *Config) { dst.Auth.JWT.Issuer = src.Auth.JWT.Issuer }},
+	"auth.jwt.audience":   {"auth.jwt.audience", func(dst *Config, src *Config) { dst.Auth.JWT.Audience = src.Auth.JWT.Audience }},
+	"auth.oidc.issuer":    {"auth.oidc.issuer", func(dst *Config, src *Config) { dst.Auth.OIDC.Issuer = src.Auth.OIDC.Issuer }},
+	"auth.oidc.clientid":  {"auth.oidc.clientId", func(dst *Config, src *Config) { dst.Auth.OIDC.ClientID = src.Auth.OIDC.ClientID }},
+	"auth.oidc.clientsecret": {"auth.oidc.clientSecret", func(dst *Config, src *Config) {
+		dst.Auth.OIDC.ClientSecret = src.Auth.OIDC.ClientSecret
+	}},
+	"auth.oidc.redirecturl": {"auth.oidc.redirectUrl", func(dst *Config, src *Config) { dst.Auth.OIDC.RedirectURL = src.Auth.OIDC.RedirectURL }},
+	"auth.oidc.scopes":      {"auth.oidc.scopes", func(dst *Config, src *Config) { dst.Auth.OIDC.Scopes = src.Auth.OIDC.Scopes }},
+	"auth.claims.username":  {"auth.claims.username", func(dst *Config, src *Config) { dst.Auth.Claims.Username = src.Auth.Claims.Username }},
+	"auth.claims.roles":     {"auth.claims.roles", func(dst *Config, src *Config) { dst.Auth.Claims.Roles = src.Auth.Claims.Roles }},
+
+	"admin.endpoint":  {"admin.endpoint", func(dst *Config, src *Config) { dst.Admin.Endpoint = src.Admin.Endpoint }},
+	"admin.addresses": {"admin.addresses", func(dst *Config, src *Config) { dst.Admin.Addresses = src.Admin.Addresses }},
+	"admin.tokenfile": {"admin.tokenFile", func(dst *Config, src *Config) { dst.Admin.TokenFile = src.Admin.TokenFile }},
+	"admin.roles":     {"admin.roles", func(dst *Config, src *Config) { dst.Admin.Roles = src.Admin.Roles }},
+
+	"compression.enabled":      {"compression.enabled", func(dst *Config, src *Config) { dst.Compression.Enabled = src.Compression.Enabled }},
+	"compression.encodings":    {"compression.encodings", func(dst *Config, src *Config) { dst.Compression.Encodings = src.Compression.Encodings }},
+	"compression.minsizebytes": {"compression.minSizeBytes", func(dst *Config, src *Config) { dst.Compression.MinSizeBytes = src.Compression.MinSizeBytes }},
+	"compression.level":        {"compression.level", func(dst *Config, src *Config) { dst.Compression.Level = src.Compression.Level }},
+
+	"enhancedindex": {"enhancedList", func(dst *Config, src *Config) { dst.EnhancedList = src.EnhancedList }},
+	"debug":         {"debug", func(dst *Config, src *Config) { dst.Debug = src.Debug }},
 }
 
 func init() {
@@ -205,10 +917,36 @@ func loadFromFlags() (*Config, error) {
 		EnhancedList:      *enhancedListFlag,
 		Debug:             *debugFlag,
 		HTTP: HTTP{
-			Port: *httpPortFlag,
+			Port:            *httpPortFlag,
+			Addresses:       splitList(*httpAddressesFlag),
+			Disabled:        *httpDisabledFlag,
+			RedirectToHTTPS: *redirectToHTTPSFlag,
+			Limits: Limits{
+				ReadHeaderTimeout:    *readHeaderTimeoutFlag,
+				ReadTimeout:          *readTimeoutFlag,
+				WriteTimeout:         *writeTimeoutFlag,
+				IdleTimeout:          *idleTimeoutFlag,
+				MaxHeaderBytes:       *maxHeaderBytesFlag,
+				MaxBodyBytes:         *maxBodyBytesFlag,
+				MultipartMemoryBytes: *multipartMemoryBytesFlag,
+				MaxConnections:       *maxConnectionsFlag,
+			},
 		},
 		HTTPS: HTTPS{
-			Port: *httpsPortFlag,
+			Port:      *httpsPortFlag,
+			Addresses: splitList(*httpsAddressesFlag),
+			Disabled:  *httpsDisabledFlag,
+			HTTP3:     *http3Flag,
+			HSTS: HSTS{
+				MaxAge:            *hstsMaxAgeFlag,
+				IncludeSubdomains: *hstsIncludeSubdomainsFlag,
+			},
+			ClientAuth: ClientAuth{
+				Mode:     *clientAuthModeFlag,
+				CAFile:   *clientAuthCAFileFlag,
+				Identity: *clientAuthIdentityFlag,
+				Roles:    splitList(*clientAuthRolesFlag),
+			},
 			Certificate: Certificate{
 				PrivateKeyFilePath:       *privateKeyFilePathFlag,
 				CertificateFilePath:      *certificateFilePathFlag,
@@ -229,14 +967,116 @@ func loadFromFlags() (*Config, error) {
 				Enabled: *monitoringTraceURIFlag != "",
 				URI:     *monitoringTraceURIFlag,
 			},
+			IPFilter: IPFilter{
+				Allow: splitList(*monitoringAllowFlag),
+				Deny:  splitList(*monitoringDenyFlag),
+			},
+			DebugIPFilter: IPFilter{
+				Allow: splitList(*debugAllowFlag),
+				Deny:  splitList(*debugDenyFlag),
+			},
 		},
 		Upload: Serve{
 			Source:   *uploadPathFlag,
 			Endpoint: *uploadHTTPPathFlag,
+			RateLimit: RateLimit{
+				RequestsPerSecond: *uploadRateLimitRequestsPerSecondFlag,
+				Burst:             *uploadRateLimitBurstFlag,
+			},
+			IPFilter: IPFilter{
+				Allow: splitList(*uploadIPFilterAllowFlag),
+				Deny:  splitList(*uploadIPFilterDenyFlag),
+			},
+		},
+		Shutdown: Shutdown{
+			DrainTimeout: *drainTimeoutFlag,
+		},
+		CORS: CORS{
+			Disabled:         *corsDisabledFlag,
+			AllowedOrigins:   splitList(*corsAllowedOriginsFlag),
+			AllowedMethods:   splitList(*corsAllowedMethodsFlag),
+			AllowedHeaders:   splitList(*corsAllowedHeadersFlag),
+			ExposedHeaders:   splitList(*corsExposedHeadersFlag),
+			AllowCredentials: *corsAllowCredentialsFlag,
+			MaxAge:           *corsMaxAgeFlag,
+		},
+		AccessLog: AccessLog{
+			Format:       *accessLogFormatFlag,
+			Path:         *accessLogPathFlag,
+			MaxSizeBytes: *accessLogMaxSizeBytesFlag,
+			MaxBackups:   *accessLogMaxBackupsFlag,
+			Compress:     *accessLogCompressFlag,
+		},
+		RateLimit: RateLimit{
+			RequestsPerSecond: *rateLimitRequestsPerSecondFlag,
+			Burst:             *rateLimitBurstFlag,
+		},
+		Bandwidth: Bandwidth{
+			Download: BandwidthLimit{
+				BytesPerSecond:              *downloadBytesPerSecondFlag,
+				PerConnectionBytesPerSecond: *downloadPerConnectionBytesPerSecondFlag,
+			},
+			Upload: BandwidthLimit{
+				BytesPerSecond:              *uploadBytesPerSecondFlag,
+				PerConnectionBytesPerSecond: *uploadPerConnectionBytesPerSecondFlag,
+			},
+		},
+		Auth: Auth{
+			HtpasswdFile:   *htpasswdFileFlag,
+			HtpasswdRoles:  splitList(*htpasswdRolesFlag),
+			LoginEndpoint:  *loginEndpointFlag,
+			SessionTimeout: *sessionTimeoutFlag,
+			JWT: JWT{
+				JWKS:     *jwtJWKSFlag,
+				Issuer:   *jwtIssuerFlag,
+				Audience: *jwtAudienceFlag,
+			},
+			OIDC: OIDC{
+				Issuer:       *oidcIssuerFlag,
+				ClientID:     *oidcClientIDFlag,
+				ClientSecret: *oidcSecretFlag,
+				RedirectURL:  *oidcRedirectFlag,
+				Scopes:       splitList(*oidcScopesFlag),
+			},
+			Claims: Claims{
+				Username: *claimsUsernameFlag,
+				Roles:    *claimsRolesFlag,
+			},
+		},
+		IPFilter: IPFilter{
+			Allow: splitList(*ipFilterAllowFlag),
+			Deny:  splitList(*ipFilterDenyFlag),
+		},
+		ClientIP: ClientIP{
+			TrustedProxies: splitList(*trustedProxiesFlag),
+			Header:         *clientIPHeaderFlag,
+		},
+		Admin: Admin{
+			Endpoint:  *adminEndpointFlag,
+			Addresses: splitList(*adminAddressesFlag),
+			TokenFile: *adminTokenFileFlag,
+			Roles:     splitList(*adminRolesFlag),
+		},
+		Compression: Compression{
+			Enabled:      *compressionEnabledFlag,
+			Encodings:    splitList(*compressionEncodingsFlag),
+			MinSizeBytes: *compressionMinSizeBytesFlag,
+			Level:        *compressionLevelFlag,
 		},
 	}, nil
 }
 
+// splitList splits a comma-separated flag value, nil if it is empty.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func serveList(paths string, servePaths string) ([]Serve, error) {
 	pl := strings.Split(paths, ",")
 	spl := strings.Split(servePaths, ",")
@@ -51,10 +51,23 @@ func TestPopulatedConfig(t *testing.T) {
 			Endpoint: "/serving",
 		}},
 		HTTP: HTTP{
-			Port: 1000,
+			Port:            1000,
+			RedirectToHTTPS: true,
+			Limits: Limits{
+				ReadHeaderTimeout:    5 * time.Second,
+				ReadTimeout:          time.Minute,
+				WriteTimeout:         2 * time.Minute,
+				IdleTimeout:          3 * time.Minute,
+				MaxHeaderBytes:       4096,
+				MaxBodyBytes:         1024,
+				EndpointMaxBodyBytes: map[string]int64{"/postage": 2048},
+				MultipartMemoryBytes: 512,
+				MaxConnections:       100,
+			},
 		},
 		HTTPS: HTTPS{
-			Port: 2000,
+			Port:  2000,
+			HTTP3: true,
 			Certificate: Certificate{
 				RootPrivateKeyFilePath:   "root-private-key.pem",
 				RootCertificateFilePath:  "root-public-certificate.pem",
@@ -64,6 +77,16 @@ func TestPopulatedConfig(t *testing.T) {
 				CertificateValidDuration: time.Hour * 24,
 				ForceOverwrite:           true,
 			},
+			HSTS: HSTS{
+				MaxAge:            8760 * time.Hour,
+				IncludeSubdomains: true,
+			},
+			ClientAuth: ClientAuth{
+				Mode:     "require",
+				CAFile:   "client-ca.pem",
+				Identity: "email",
+				Roles:    []string{"clients"},
+			},
 		},
 		Monitoring: Monitoring{
 			DebugEndpoint: "/debugging",
@@ -75,10 +98,85 @@ func TestPopulatedConfig(t *testing.T) {
 				Enabled: true,
 				URI:     "remotehost",
 			},
+			IPFilter:      IPFilter{Allow: []string{"10.0.0.0/8"}},
+			DebugIPFilter: IPFilter{Allow: []string{"127.0.0.1", "::1"}},
 		},
 		Upload: Serve{
 			Source:   "/home/upload",
 			Endpoint: "/postage",
+			IPFilter: IPFilter{Allow: []string{"192.168.0.0/16"}},
+		},
+		Shutdown: Shutdown{
+			DrainTimeout: 10 * time.Second,
+		},
+		CORS: CORS{
+			AllowedOrigins:   []string{"https://*.gowebserver.com"},
+			AllowedMethods:   []string{"GET", "PUT"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		},
+		AccessLog: AccessLog{
+			Format:       "combined",
+			Path:         "/var/log/gowebserver/access.log",
+			MaxSizeBytes: 100 << 20,
+			MaxBackups:   5,
+			Compress:     true,
+		},
+		RateLimit: RateLimit{
+			RequestsPerSecond: 2.5,
+			Burst:             10,
+		},
+		Bandwidth: Bandwidth{
+			Download: BandwidthLimit{
+				BytesPerSecond:              10 << 20,
+				PerConnectionBytesPerSecond: 1 << 20,
+			},
+			Upload: BandwidthLimit{
+				BytesPerSecond: 2 << 20,
+			},
+		},
+		Auth: Auth{
+			Users: []User{
+				{Name: "frank", PasswordHash: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", Roles: []string{"staff"}},
+			},
+			HtpasswdFile:   "users.htpasswd",
+			HtpasswdRoles:  []string{"readers"},
+			LoginEndpoint:  "/signin",
+			SessionTimeout: 12 * time.Hour,
+			JWT: JWT{
+				JWKS:     "https://idp.example.com/jwks.json",
+				Issuer:   "https://idp.example.com",
+				Audience: "gowebserver",
+			},
+			OIDC: OIDC{
+				Issuer:       "https://idp.example.com",
+				ClientID:     "gowebserver",
+				ClientSecret: "secret",
+				RedirectURL:  "https://files.example.com/signin/callback",
+				Scopes:       []string{"openid", "groups"},
+			},
+			Claims: Claims{
+				Username:    "preferred_username",
+				Roles:       "groups",
+				RoleMapping: map[string][]string{"engineering": {"staff"}},
+			},
+		},
+		IPFilter: IPFilter{Deny: []string{"203.0.113.0/24"}},
+		ClientIP: ClientIP{
+			TrustedProxies: []string{"10.0.0.1"},
+			Header:         "X-Real-IP",
+		},
+		Admin: Admin{
+			Endpoint:  "/admin",
+			Addresses: []string{"127.0.0.1:9090"},
+			TokenFile: "/etc/gowebserver/admin.token",
+			Roles:     []string{"operator"},
+		},
+		Compression: Compression{
+			Enabled:      true,
+			Encodings:    []string{"zstd", "gzip"},
+			MinSizeBytes: 512,
+			Level:        "best",
 		},
 	}
 
@@ -111,10 +209,14 @@ func TestNoDefaultConfig(t *testing.T) {
 				Endpoint: "/serving",
 			},
 		},
-		ConfigurationFile: "",
+		ConfigurationFile: fp.Name(),
 		Debug:             true,
 		HTTP: HTTP{
 			Port: 1,
+			Limits: Limits{
+				ReadHeaderTimeout: time.Second,
+				MaxConnections:    10,
+			},
 		},
 		HTTPS: HTTPS{
 			Port: 2,
@@ -127,6 +229,9 @@ func TestNoDefaultConfig(t *testing.T) {
 				CertificateValidDuration: time.Minute,
 				ForceOverwrite:           false,
 			},
+			HSTS: HSTS{
+				MaxAge: time.Hour,
+			},
 		},
 		Monitoring: Monitoring{
 			DebugEndpoint: "/zdebug",
@@ -143,6 +248,28 @@ func TestNoDefaultConfig(t *testing.T) {
 			Source:   "dropsite",
 			Endpoint: "/upload.jspx",
 		},
+		Shutdown: Shutdown{
+			DrainTimeout: 5 * time.Second,
+		},
+		CORS: CORS{
+			AllowedOrigins: []string{"https://example.com"},
+			MaxAge:         time.Minute,
+		},
+		AccessLog: AccessLog{
+			Format:       "json",
+			MaxSizeBytes: 1024,
+		},
+		RateLimit: RateLimit{
+			RequestsPerSecond: 100,
+		},
+		Bandwidth: Bandwidth{
+			Upload: BandwidthLimit{
+				PerConnectionBytesPerSecond: 64 << 10,
+			},
+		},
+		Auth: Auth{
+			LoginEndpoint: "/signin",
+		},
 	}
 
 	if diff := cmp.Diff(want, got); diff != "" {
@@ -173,12 +300,25 @@ func TestPopulatedYamlConfig(t *testing.T) {
 				Endpoint: "/serving",
 			},
 		},
-		ConfigurationFile: "",
+		ConfigurationFile: fp.Name(),
 		HTTP: HTTP{
-			Port: 1000,
+			Port:            1000,
+			RedirectToHTTPS: true,
+			Limits: Limits{
+				ReadHeaderTimeout:    5 * time.Second,
+				ReadTimeout:          time.Minute,
+				WriteTimeout:         2 * time.Minute,
+				IdleTimeout:          3 * time.Minute,
+				MaxHeaderBytes:       4096,
+				MaxBodyBytes:         1024,
+				EndpointMaxBodyBytes: map[string]int64{"/postage": 2048},
+				MultipartMemoryBytes: 512,
+				MaxConnections:       100,
+			},
 		},
 		HTTPS: HTTPS{
-			Port: 2000,
+			Port:  2000,
+			HTTP3: true,
 			Certificate: Certificate{
 				RootPrivateKeyFilePath:   "root-private-key.pem",
 				RootCertificateFilePath:  "root-public-certificate.pem",
@@ -188,6 +328,16 @@ func TestPopulatedYamlConfig(t *testing.T) {
 				CertificateValidDuration: time.Hour * 24,
 				ForceOverwrite:           false,
 			},
+			HSTS: HSTS{
+				MaxAge:            8760 * time.Hour,
+				IncludeSubdomains: true,
+			},
+			ClientAuth: ClientAuth{
+				Mode:     "require",
+				CAFile:   "client-ca.pem",
+				Identity: "email",
+				Roles:    []string{"clients"},
+			},
 		},
 		Monitoring: Monitoring{
 			DebugEndpoint: "/debugging",
@@ -199,11 +349,86 @@ func TestPopulatedYamlConfig(t *testing.T) {
 				Enabled: true,
 				URI:     "remotehost",
 			},
+			IPFilter:      IPFilter{Allow: []string{"10.0.0.0/8"}},
+			DebugIPFilter: IPFilter{Allow: []string{"127.0.0.1", "::1"}},
 		},
 
 		Upload: Serve{
 			Source:   "/home/upload",
 			Endpoint: "/postage",
+			IPFilter: IPFilter{Allow: []string{"192.168.0.0/16"}},
+		},
+		Shutdown: Shutdown{
+			DrainTimeout: 10 * time.Second,
+		},
+		CORS: CORS{
+			AllowedOrigins:   []string{"https://*.gowebserver.com"},
+			AllowedMethods:   []string{"GET", "PUT"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		},
+		AccessLog: AccessLog{
+			Format:       "combined",
+			Path:         "/var/log/gowebserver/access.log",
+			MaxSizeBytes: 100 << 20,
+			MaxBackups:   5,
+			Compress:     true,
+		},
+		RateLimit: RateLimit{
+			RequestsPerSecond: 2.5,
+			Burst:             10,
+		},
+		Bandwidth: Bandwidth{
+			Download: BandwidthLimit{
+				BytesPerSecond:              10 << 20,
+				PerConnectionBytesPerSecond: 1 << 20,
+			},
+			Upload: BandwidthLimit{
+				BytesPerSecond: 2 << 20,
+			},
+		},
+		Auth: Auth{
+			Users: []User{
+				{Name: "frank", PasswordHash: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", Roles: []string{"staff"}},
+			},
+			HtpasswdFile:   "users.htpasswd",
+			HtpasswdRoles:  []string{"readers"},
+			LoginEndpoint:  "/signin",
+			SessionTimeout: 12 * time.Hour,
+			JWT: JWT{
+				JWKS:     "https://idp.example.com/jwks.json",
+				Issuer:   "https://idp.example.com",
+				Audience: "gowebserver",
+			},
+			OIDC: OIDC{
+				Issuer:       "https://idp.example.com",
+				ClientID:     "gowebserver",
+				ClientSecret: "secret",
+				RedirectURL:  "https://files.example.com/signin/callback",
+				Scopes:       []string{"openid", "groups"},
+			},
+			Claims: Claims{
+				Username:    "preferred_username",
+				Roles:       "groups",
+				RoleMapping: map[string][]string{"engineering": {"staff"}},
+			},
+		},
+		IPFilter: IPFilter{Deny: []string{"203.0.113.0/24"}},
+		ClientIP: ClientIP{
+			TrustedProxies: []string{"10.0.0.1"},
+			Header:         "X-Real-IP",
+		},
+		Admin: Admin{
+			Endpoint:  "/admin",
+			Addresses: []string{"127.0.0.1:9090"},
+			TokenFile: "/etc/gowebserver/admin.token",
+			Roles:     []string{"operator"},
+		},
+		Compression: Compression{
+			Enabled:      true,
+			Encodings:    []string{"zstd", "gzip"},
+			MinSizeBytes: 512,
+			Level:        "best",
 		},
 	}
 
@@ -246,6 +471,12 @@ func TestDefaultConfiguration(t *testing.T) {
 		ConfigurationFile: "",
 		HTTP: HTTP{
 			Port: *httpPortFlag,
+			Limits: Limits{
+				ReadHeaderTimeout:    10 * time.Second,
+				IdleTimeout:          2 * time.Minute,
+				MaxHeaderBytes:       1 << 20,
+				MultipartMemoryBytes: 32 << 20,
+			},
 		},
 		HTTPS: HTTPS{
 			Port: *httpsPortFlag,
@@ -258,6 +489,10 @@ func TestDefaultConfiguration(t *testing.T) {
 				CertificateValidDuration: 43800 * time.Hour,
 				ForceOverwrite:           false,
 			},
+			ClientAuth: ClientAuth{
+				Mode:     "none",
+				Identity: "commonName",
+			},
 		},
 		Monitoring: Monitoring{
 			DebugEndpoint: "",
@@ -274,6 +509,21 @@ func TestDefaultConfiguration(t *testing.T) {
 			Source:   "uploaded-files",
 			Endpoint: "/upload.asp",
 		},
+		Shutdown: Shutdown{
+			DrainTimeout: defaultDrainTimeout,
+		},
+		Auth: Auth{
+			LoginEndpoint:  "/login",
+			SessionTimeout: 24 * time.Hour,
+			OIDC:           OIDC{Scopes: []string{"openid", "profile", "email"}},
+			Claims:         Claims{Username: "sub"},
+		},
+		ClientIP: ClientIP{Header: "X-Forwarded-For"},
+		Compression: Compression{
+			Encodings:    []string{"br", "zstd", "gzip"},
+			MinSizeBytes: 1024,
+			Level:        "default",
+		},
 	}
 
 	if diff := cmp.Diff(want, got); diff != "" {
@@ -0,0 +1,87 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/rs/cors"
+)
+
+// handler wraps h with the CORS policy, h is returned as is if CORS is
+// disabled.
+func (c CORS) handler(h http.Handler) http.Handler {
+	if c.Disabled {
+		return h
+	}
+	return cors.New(c.options()).Handler(h)
+}
+
+func (c CORS) options() cors.Options {
+	return cors.Options{
+		AllowedOrigins:   c.AllowedOrigins,
+		AllowedMethods:   c.AllowedMethods,
+		AllowedHeaders:   c.AllowedHeaders,
+		ExposedHeaders:   c.ExposedHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           int(c.MaxAge.Seconds()),
+	}
+}
+
+// allowsAllOrigins reports whether any origin is allowed, which is the case
+// when no origins are configured.
+func (c CORS) allowsAllOrigins() bool {
+	return len(c.AllowedOrigins) == 0 || slices.Contains(c.AllowedOrigins, "*")
+}
+
+// trustedOrigins returns a policy that only matches the explicitly allowed
+// origins, or nil if there are none. Origins allowed by "*" are not trusted
+// for requests that change state such as uploads.
+func (c CORS) trustedOrigins() *cors.Cors {
+	if c.Disabled || c.allowsAllOrigins() {
+		return nil
+	}
+	return cors.New(c.options())
+}
+
+func (c CORS) equal(other CORS) bool {
+	return c.Disabled == other.Disabled &&
+		slices.Equal(c.AllowedOrigins, other.AllowedOrigins) &&
+		slices.Equal(c.AllowedMethods, other.AllowedMethods) &&
+		slices.Equal(c.AllowedHeaders, other.AllowedHeaders) &&
+		slices.Equal(c.ExposedHeaders, other.ExposedHeaders) &&
+		c.AllowCredentials == other.AllowCredentials &&
+		c.MaxAge == other.MaxAge
+}
+
+// validateOrigin checks that the origin is "*" or a scheme and host, e.g.
+// "https://*.example.com", with at most one wildcard.
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	if strings.Count(origin, "*") > 1 {
+		return fmt.Errorf("invalid origin '%s', only one wildcard is allowed", origin)
+	}
+	u, err := url.Parse(strings.Replace(origin, "*", "wildcard", 1))
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+		return fmt.Errorf("invalid origin '%s', want a scheme and host such as https://example.com", origin)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSHandler(t *testing.T) {
+	testCases := []struct {
+		name            string
+		cors            CORS
+		method          string
+		origin          string
+		wantOrigin      string
+		wantMaxAge      string
+		wantCredentials string
+	}{
+		{name: "default allows all", cors: CORS{}, method: http.MethodGet, origin: "https://a.com", wantOrigin: "*"},
+		{name: "disabled", cors: CORS{Disabled: true}, method: http.MethodGet, origin: "https://a.com", wantOrigin: ""},
+		{name: "wildcard match", cors: CORS{AllowedOrigins: []string{"https://*.example.com"}}, method: http.MethodGet, origin: "https://app.example.com", wantOrigin: "https://app.example.com"},
+		{name: "not allowed", cors: CORS{AllowedOrigins: []string{"https://*.example.com"}}, method: http.MethodGet, origin: "https://example.org", wantOrigin: ""},
+		{
+			name:            "preflight",
+			cors:            CORS{AllowedOrigins: []string{"https://a.com"}, AllowedMethods: []string{http.MethodPut}, AllowCredentials: true, MaxAge: time.Hour},
+			method:          http.MethodOptions,
+			origin:          "https://a.com",
+			wantOrigin:      "https://a.com",
+			wantMaxAge:      "3600",
+			wantCredentials: "true",
+		},
+	}
+
+	for _, tc := range testCases {
+		h := tc.cors.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		r := httptest.NewRequest(tc.method, "/", nil)
+		r.Header.Set("Origin", tc.origin)
+		if tc.method == http.MethodOptions {
+			r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tc.wantOrigin {
+			t.Errorf("%s: got Access-Control-Allow-Origin %q, want %q", tc.name, got, tc.wantOrigin)
+		}
+		if got := w.Header().Get("Access-Control-Max-Age"); got != tc.wantMaxAge {
+			t.Errorf("%s: got Access-Control-Max-Age %q, want %q", tc.name, got, tc.wantMaxAge)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tc.wantCredentials {
+			t.Errorf("%s: got Access-Control-Allow-Credentials %q, want %q", tc.name, got, tc.wantCredentials)
+		}
+	}
+}
+
+func TestWebServer_MountCORS(t *testing.T) {
+	baseURL, close := serveAsync(t, &Config{
+		Serve: []Serve{
+			{Source: mustTempDir(t), Endpoint: "/"},
+			{Source: mustTempDir(t), Endpoint: "/private", CORS: &CORS{Disabled: true}},
+			{Source: mustTempDir(t), Endpoint: "/api", CORS: &CORS{AllowedOrigins: []string{"https://app.example.com"}}},
+		},
+		CORS: CORS{AllowedOrigins: []string{"https://*.example.com"}},
+	})
+	defer close()
+
+	testCases := []struct {
+		path   string
+		origin string
+		want   string
+	}{
+		{path: "/", origin: "https://www.example.com", want: "https://www.example.com"},
+		{path: "/private/", origin: "https://www.example.com", want: ""},
+		{path: "/api/", origin: "https://www.example.com", want: ""},
+		{path: "/api/", origin: "https://app.example.com", want: "https://app.example.com"},
+	}
+
+	for _, tc := range testCases {
+		req, err := http.NewRequest(http.MethodGet, baseURL+tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Origin", tc.origin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != tc.want {
+			t.Errorf("GET %s from %s got Access-Control-Allow-Origin %q, want %q", tc.path, tc.origin, got, tc.want)
+		}
+	}
+}
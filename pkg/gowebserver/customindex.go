@@ -15,12 +15,14 @@
 package gowebserver
 
 import (
+	"bytes"
 	"context"
 	_ "embed"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"net/http"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -49,28 +51,68 @@ func isSupportedGit(filePath string) bool {
 	return strings.HasSuffix(strings.ToLower(filePath), ".git")
 }
 
-func newHandlerFromFS(fsSpec string, tp trace.TracerProvider, enhancedList bool) (http.Handler, func() error, error) {
+func newHandlerFromFS(fsSpec string, tp trace.TracerProvider, opts mountOptions) (http.Handler, func() error, error) {
 	ctx := context.Background()
 	// fsSpec is probably breaking this.
 	if !isSupportedGit(fsSpec) && isSupportedHTTP(fsSpec) {
 		handler, err := newHTTPReverseProxy(fsSpec)
-		return handler, nilFuncWithError, err
+		if err != nil {
+			return nil, nilFuncWithError, err
+		}
+		return opts.wrapHandler(handler), nilFuncWithError, nil
 	}
 
+	if opts.etag == "" || opts.etag == etagAuto {
+		// Local directories have reliable modification times.
+		if stat, err := os.Stat(fsSpec); err == nil && stat.IsDir() {
+			opts.etag = etagOff
+		}
+	}
 	nFS, err := ufs.New(ctx, fsSpec)
 	if err != nil {
 		return nil, nilFuncWithError, err
 	}
-
-	ci, err := newCustomIndex(http.FileServer(http.FS(nFS)), nFS, tp, enhancedList)
+	handler, err := newHandlerFromFSys(nFS, tp, opts)
 	if err != nil {
+		nFS.Close()
 		return nil, nilFuncWithError, err
 	}
-	rv, err := newRichViewHandler(ci, nFS, tp)
+	return handler, nFS.Close, nil
+}
+
+// newHandler serves the mount's file system.
+func (p servePath) newHandler(tp trace.TracerProvider) (http.Handler, func() error, error) {
+	if p.fsys != nil {
+		handler, err := newHandlerFromFSys(p.fsys, tp, p.options)
+		return handler, nilFuncWithError, err
+	}
+	return newHandlerFromFS(p.localPath, tp, p.options)
+}
+
+// newHandlerFromFSys serves the files of fsys with the mount's options.
+func newHandlerFromFSys(fsys fs.FS, tp trace.TracerProvider, opts mountOptions) (http.Handler, error) {
+	baseFS := opts.wrapFS(fsys)
+	fileServer := http.FileServer(http.FS(baseFS))
+	if opts.precompressed {
+		fileServer = &precompressedHandler{next: fileServer, fsys: baseFS}
+	}
+	if opts.etag != etagOff {
+		fileServer = newETagHandler(fileServer, baseFS)
+	}
+	handler, err := newCustomIndex(fileServer, baseFS, tp, opts.enhancedList, opts.richView)
 	if err != nil {
-		return nil, nilFuncWithError, err
+		return nil, err
 	}
-	return rv, nFS.Close, nil
+	if opts.richView {
+		handler, err = newRichViewHandler(handler, baseFS, tp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.disableListing {
+		handler = &noListingHandler{next: handler, fsys: baseFS}
+	}
+	return opts.wrapHandler(handler), nil
 }
 
 func cleanPath(path string) string {
@@ -165,6 +207,7 @@ type customIndexHandler struct {
 	baseHandler  http.Handler
 	baseFS       fs.FS
 	enhancedList bool
+	richView     bool
 	tp           trace.TracerProvider
 	tmpl         *template.Template
 }
@@ -179,22 +222,22 @@ func canonicalizeSortBy(v string) string {
 	return "name"
 }
 
-func tryListDir(fsys fs.FS, path string) {
+func tryListDir(logger *zap.SugaredLogger, fsys fs.FS, path string) {
 	f, err := fsys.Open(path)
 	if err != nil {
-		zap.S().With("path", path).With(zap.Error(err)).Warn("failed to open file")
+		logger.With("path", path).With(zap.Error(err)).Warn("failed to open file")
 		return
 	}
 	if dirList, ok := f.(fs.ReadDirFile); ok {
 		dirs, err := dirList.ReadDir(-1)
 		if err != nil {
-			zap.S().With("path", path).With(zap.Error(err)).Warn("failed to open file")
+			logger.With("path", path).With(zap.Error(err)).Warn("failed to open file")
 		}
 		for _, dir := range dirs {
-			zap.S().With("path", path).With("stat", statToString(dir.Info())).Infof("- %s", dir.Name())
+			logger.With("path", path).With("stat", statToString(dir.Info())).Infof("- %s", dir.Name())
 		}
 	} else {
-		zap.S().With("path", path).With("stat", statToString(f.Stat())).Infof("regular file")
+		logger.With("path", path).With("stat", statToString(f.Stat())).Infof("regular file")
 	}
 }
 
@@ -214,13 +257,15 @@ func (c *customIndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx, span := rootTrace.Start(r.Context(), r.URL.Path)
 	defer span.End()
 	span.SetAttributes(attribute.Bool("enhanced_list", c.enhancedList))
+	setSpanRequestID(ctx, span)
+	logger := requestLogger(ctx)
 	if c.enhancedList {
 		path := r.URL.Path
 		urlPath := r.URL.Path
 		path = cleanPath(strings.TrimPrefix(path, "/"))
 
-		tryListDir(c.baseFS, path)
-		zap.S().With("url", r.URL, "path", path).Info("customIndexHandler")
+		tryListDir(logger, c.baseFS, path)
+		logger.With("url", r.URL, "path", path).Info("customIndexHandler")
 		if strings.HasSuffix(urlPath, "/") || path == "." {
 			_, openSpan := rootTrace.Start(ctx, "Open")
 			openSpan.SetAttributes(attribute.String("path", path))
@@ -284,6 +329,9 @@ func (c *customIndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 							continue
 						}
 					}
+					if !isListed(r, "/"+strings.TrimPrefix(path, ".")+"/"+entry.Name()) {
+						continue
+					}
 
 					size := int64(0)
 					t := now
@@ -294,7 +342,7 @@ func (c *customIndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 					}
 
 					if strings.HasSuffix(entry.Name(), ".xz") {
-						zap.S().Infof("%s", entry.Name())
+						logger.Infof("%s", entry.Name())
 					}
 					_, isArchive := actualArchiveDir[entry.Name()]
 					isDir := entry.IsDir() || isArchive
@@ -305,7 +353,7 @@ func (c *customIndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 						ModTime:    t,
 						IsDir:      isDir,
 						IsArchive:  isArchive,
-						IsViewable: !isDir && isRichViewable(iconClass),
+						IsViewable: c.richView && !isDir && isRichViewable(iconClass),
 						IconClass:  iconClass,
 					}
 					files.add(newEntry)
@@ -340,12 +388,16 @@ func (c *customIndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				params.HasImage = hasImage
 				params.HasVideo = hasVideo
 
-				zap.S().Infof("Params: %s", params)
-				if err := c.tmpl.Execute(w, params); err != nil {
+				logger.Infof("Params: %s", params)
+				body := &bytes.Buffer{}
+				if err := c.tmpl.Execute(body, params); err != nil {
 					http.Error(w, err.Error(), http.StatusInternalServerError)
 					return
 				}
-
+				setSecureHeaders(w.Header())
+				// The listing has no reliable modification time, entries
+				// can change without changing the directory.
+				serveGenerated(w, r, body.Bytes(), time.Time{})
 				return
 			}
 		}
@@ -354,7 +406,7 @@ func (c *customIndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	c.baseHandler.ServeHTTP(w, r)
 }
 
-func newCustomIndex(baseHandler http.Handler, baseFS fs.FS, tp trace.TracerProvider, enhancedList bool) (http.Handler, error) {
+func newCustomIndex(baseHandler http.Handler, baseFS fs.FS, tp trace.TracerProvider, enhancedList bool, richView bool) (http.Handler, error) {
 	tmpl, err := createTemplate(customIndexHTML)
 	if err != nil {
 		return nil, err
@@ -363,6 +415,7 @@ func newCustomIndex(baseHandler http.Handler, baseFS fs.FS, tp trace.TracerProvi
 		baseHandler:  baseHandler,
 		baseFS:       baseFS,
 		enhancedList: enhancedList,
+		richView:     richView,
 		tp:           tp,
 		tmpl:         tmpl,
 	}, nil
@@ -0,0 +1,254 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// envPrefix is the prefix of all environment variables that override
+	// configuration fields, e.g. GOWEBSERVER_HTTPS_CERTIFICATE_PATH.
+	envPrefix = "GOWEBSERVER"
+	// envFileSuffix marks an environment variable whose value is the path of a
+	// file containing the actual value, e.g. a mounted secret.
+	envFileSuffix = "_FILE"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// applyEnvironment overrides the configuration fields from GOWEBSERVER_*
+// environment variables. The variable names are derived from the YAML field
+// path, for example monitoring.trace.uri is GOWEBSERVER_MONITORING_TRACE_URI and
+// serve[1].source is GOWEBSERVER_SERVE_1_SOURCE. Map fields take a YAML
+// mapping such as GOWEBSERVER_SERVE_0_ACCESS='{staff: [read, upload]}'.
+// Appending _FILE to the name reads the value from the file at the given path.
+// The fields that are set are recorded in prov if it is not nil.
+func applyEnvironment(conf *Config, environ []string, prov provenance) error {
+	vars := map[string]string{}
+	for _, kv := range environ {
+		k, v, ok := strings.Cut(kv, "=")
+		if ok && strings.HasPrefix(k, envPrefix+"_") {
+			vars[k] = v
+		}
+	}
+
+	errs := ConfigErrors{}
+	applyEnvToValue(reflect.ValueOf(conf).Elem(), envPrefix, "", vars, prov, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func applyEnvToValue(v reflect.Value, envName string, field string, vars map[string]string, prov provenance, errs *ConfigErrors) {
+	switch {
+	case v.Kind() == reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := yamlFieldName(f)
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			childField := name
+			if field != "" {
+				childField = field + "." + name
+			}
+			applyEnvToValue(v.Field(i), envName+"_"+envNameSegment(name), childField, vars, prov, errs)
+		}
+		return
+	case v.Kind() == reflect.Pointer && v.Type().Elem().Kind() == reflect.Struct:
+		// Optional sections such as serve[0].cors are only created if one of
+		// their fields is set.
+		if v.IsNil() {
+			if !hasEnvPrefix(vars, envName) {
+				return
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		applyEnvToValue(v.Elem(), envName, field, vars, prov, errs)
+		return
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		for i := 0; i <= maxEnvIndex(vars, envName); i++ {
+			if i >= v.Len() {
+				v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+			}
+			applyEnvToValue(v.Index(i), fmt.Sprintf("%s_%d", envName, i), fmt.Sprintf("%s[%d]", field, i), vars, prov, errs)
+		}
+		return
+	}
+
+	raw, ok, err := lookupEnv(vars, envName)
+	if err != nil {
+		*errs = append(*errs, &ConfigError{Field: field, Message: err.Error()})
+		return
+	}
+	if !ok {
+		return
+	}
+	if err := setFromString(v, raw); err != nil {
+		*errs = append(*errs, &ConfigError{Field: field, Message: fmt.Sprintf("invalid value for %s, %s", envName, err)})
+		return
+	}
+	prov.set(field, sourceEnv)
+}
+
+// lookupEnv returns the value of the environment variable or the contents of
+// the file referenced by its _FILE variant.
+func lookupEnv(vars map[string]string, envName string) (string, bool, error) {
+	value, hasValue := vars[envName]
+	filePath, hasFile := vars[envName+envFileSuffix]
+	switch {
+	case hasValue && hasFile:
+		return "", false, fmt.Errorf("only one of %s and %s%s can be set", envName, envName, envFileSuffix)
+	case hasFile:
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", false, fmt.Errorf("cannot read %s%s, %w", envName, envFileSuffix, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	}
+	return value, hasValue, nil
+}
+
+// hasEnvPrefix reports whether an environment variable sets a field under
+// envName.
+func hasEnvPrefix(vars map[string]string, envName string) bool {
+	for k := range vars {
+		if strings.HasPrefix(k, envName+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// maxEnvIndex returns the largest list index referenced by an environment
+// variable with the prefix, e.g. 2 for GOWEBSERVER_SERVE_2_SOURCE, or -1.
+func maxEnvIndex(vars map[string]string, envName string) int {
+	maxIndex := -1
+	for k := range vars {
+		rest, ok := strings.CutPrefix(k, envName+"_")
+		if !ok {
+			continue
+		}
+		indexStr, _, _ := strings.Cut(rest, "_")
+		if index, err := strconv.Atoi(indexStr); err == nil && index > maxIndex {
+			maxIndex = index
+		}
+	}
+	return maxIndex
+}
+
+func setFromString(v reflect.Value, raw string) error {
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := setFromString(elem.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Map:
+		m := reflect.New(v.Type())
+		if err := yaml.Unmarshal([]byte(raw), m.Interface()); err != nil {
+			return fmt.Errorf("want a YAML mapping, %w", err)
+		}
+		v.Set(m.Elem())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		items := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = reflect.Append(items, reflect.ValueOf(item).Convert(v.Type().Elem()))
+			}
+		}
+		v.Set(items)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// yamlFieldName returns the YAML key of the struct field.
+func yamlFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+// envNameSegment converts a YAML key to its environment variable form, e.g.
+// debugEndpoint to DEBUG_ENDPOINT.
+func envNameSegment(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
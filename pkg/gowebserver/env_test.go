@@ -0,0 +1,170 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestApplyEnvironment(t *testing.T) {
+	secretFile := filepath.Join(mustTempDir(t), "trace-uri")
+	if err := os.WriteFile(secretFile, []byte("http://collector:4318\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got := &Config{
+		Serve: []Serve{{Source: "/a", Endpoint: "/a"}},
+	}
+	err := applyEnvironment(got, []string{
+		"GOWEBSERVER_VERBOSE=true",
+		"GOWEBSERVER_ENHANCED_LIST=1",
+		"GOWEBSERVER_HTTP_PORT=81",
+		"GOWEBSERVER_HTTPS_CERTIFICATE_PATH=/certs/web.cert",
+		"GOWEBSERVER_HTTPS_CERTIFICATE_DURATION=24h",
+		"GOWEBSERVER_MONITORING_DEBUG_ENDPOINT=/debug",
+		"GOWEBSERVER_MONITORING_TRACE_URI_FILE=" + secretFile,
+		"GOWEBSERVER_SERVE_1_SOURCE=/b",
+		"GOWEBSERVER_SERVE_1_ENDPOINT=/b",
+		"GOWEBSERVER_SERVE_1_CORS_ALLOWED_ORIGINS=https://a.example, https://b.example",
+		"GOWEBSERVER_SERVE_1_ACCESS={staff: [read, upload]}",
+		"GOWEBSERVER_HTTP_LIMITS_ENDPOINT_MAX_BODY_BYTES={/upload: 2048}",
+		"GOWEBSERVER_RATE_LIMIT_REQUESTS_PER_SECOND=2.5",
+		"OTHER_VARIABLE=ignored",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Config{
+		Verbose:      true,
+		EnhancedList: true,
+		Serve: []Serve{
+			{Source: "/a", Endpoint: "/a"},
+			{
+				Source:   "/b",
+				Endpoint: "/b",
+				CORS:     &CORS{AllowedOrigins: []string{"https://a.example", "https://b.example"}},
+				Access:   map[string][]string{"staff": {"read", "upload"}},
+			},
+		},
+		HTTP: HTTP{Port: 81, Limits: Limits{EndpointMaxBodyBytes: map[string]int64{"/upload": 2048}}},
+		HTTPS: HTTPS{
+			Certificate: Certificate{
+				CertificateFilePath:      "/certs/web.cert",
+				CertificateValidDuration: 24 * time.Hour,
+			},
+		},
+		Monitoring: Monitoring{
+			DebugEndpoint: "/debug",
+			Trace: Trace{
+				URI: "http://collector:4318",
+			},
+		},
+		RateLimit: RateLimit{RequestsPerSecond: 2.5},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("config mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestApplyEnvironmentErrors(t *testing.T) {
+	err := applyEnvironment(&Config{}, []string{
+		"GOWEBSERVER_HTTP_PORT=eighty",
+		"GOWEBSERVER_VERBOSE=true",
+		"GOWEBSERVER_VERBOSE_FILE=/secret",
+		"GOWEBSERVER_HTTPS_CERTIFICATE_HOSTS_FILE=/does/not/exist",
+		"GOWEBSERVER_SERVE_0_ACCESS=[read]",
+	}, nil)
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("want ConfigErrors, got %v", err)
+	}
+
+	got := []string{}
+	for _, ce := range errs {
+		got = append(got, ce.Field)
+	}
+	want := []string{"verbose", "serve[0].access", "http.port", "https.certificate.hosts"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("error fields mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestEnvNameSegment(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{input: "uri", want: "URI"},
+		{input: "debugEndpoint", want: "DEBUG_ENDPOINT"},
+		{input: "rootPrivateKey", want: "ROOT_PRIVATE_KEY"},
+		{input: "http", want: "HTTP"},
+	}
+
+	for _, tc := range testCases {
+		if got := envNameSegment(tc.input); got != tc.want {
+			t.Errorf("envNameSegment(%q) got %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestLoadLayeredPrecedence(t *testing.T) {
+	fp, err := writeTempFile(`verbose: true
+http:
+  port: 1
+https:
+  port: 2
+monitoring:
+  trace:
+    uri: from-file
+`)
+	defer os.Remove(fp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setFlags := func(fn func(*flag.Flag)) {
+		fn(&flag.Flag{Name: "verbose"})
+	}
+	got, err := loadLayered(fp.Name(), []string{
+		"GOWEBSERVER_VERBOSE=true",
+		"GOWEBSERVER_HTTP_PORT=10",
+	}, setFlags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Verbose != *verboseFlag {
+		t.Errorf("want flag value %t for verbose, got %t", *verboseFlag, got.Verbose)
+	}
+	if got.HTTP.Port != 10 {
+		t.Errorf("want environment value 10 for http.port, got %d", got.HTTP.Port)
+	}
+	if got.HTTPS.Port != 2 {
+		t.Errorf("want file value 2 for https.port, got %d", got.HTTPS.Port)
+	}
+	if got.Monitoring.Trace.URI != "from-file" {
+		t.Errorf("want file value 'from-file' for monitoring.trace.uri, got %q", got.Monitoring.Trace.URI)
+	}
+	if got.Upload.Endpoint != *uploadHTTPPathFlag {
+		t.Errorf("want default value %q for upload.endpoint, got %q", *uploadHTTPPathFlag, got.Upload.Endpoint)
+	}
+}
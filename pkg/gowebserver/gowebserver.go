@@ -15,6 +15,7 @@
 package gowebserver
 
 import (
+	"flag"
 	"fmt"
 	"net"
 	"os"
@@ -30,6 +31,22 @@ func Run() {
 	_, syncFunc := configLogger(true)
 	defer syncFunc()
 
+	flag.Parse()
+	exitWith := func(code int) {
+		syncFunc()
+		os.Exit(code)
+	}
+	switch {
+	case *validateFlag:
+		exitWith(runValidate(os.Stdout))
+	case *printConfigFlag:
+		exitWith(runPrintConfig(os.Stdout))
+	case *configSchemaFlag:
+		exitWith(runConfigSchema(os.Stdout))
+	case *hashPasswordFlag:
+		exitWith(runHashPassword(os.Stdin, os.Stdout))
+	}
+
 	gomain.Run(runInteractive, gomain.Config{
 		ServiceName:        "gowebserver",
 		ServiceDescription: "A simple, convenient, reliable, well tested HTTP/HTTPS web server to host static files.",
@@ -37,15 +54,20 @@ func Run() {
 	})
 }
 
+// logLevel is the level of the logger of configLogger, the admin API changes
+// it at runtime.
+var logLevel = zap.NewAtomicLevel()
+
 func configLogger(verbose bool) (*zap.Logger, func() error) {
 	zapConfig := zap.NewDevelopmentConfig()
 	zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	zapConfig.Encoding = "console"
 	if verbose {
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
+		logLevel.SetLevel(zap.DebugLevel)
 	} else {
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
+		logLevel.SetLevel(zap.InfoLevel)
 	}
+	zapConfig.Level = logLevel
 
 	logger, err := zapConfig.Build()
 	if err == nil {
@@ -74,7 +96,7 @@ func runApplication(wait func()) error {
 
 	checkError(createCertificate(conf))
 
-	httpServer, err := New(conf)
+	httpServer, err := New(conf, withFlagDefaultsBase())
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,202 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"fmt"
+	"maps"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+const (
+	headerPresetSecure = "secure"
+)
+
+var (
+	// headerPresets are the header sets that can be referenced by
+	// HeaderRule.Preset.
+	headerPresets = map[string]map[string]string{
+		headerPresetSecure: {
+			// The generated pages use inline scripts and styles.
+			"Content-Security-Policy":    "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'",
+			"X-Content-Type-Options":     "nosniff",
+			"X-Frame-Options":            "DENY",
+			"Referrer-Policy":            "no-referrer",
+			"Cross-Origin-Opener-Policy": "same-origin",
+		},
+	}
+)
+
+// setSecureHeaders adds the secure preset to the response of a generated page
+// such as a directory listing.
+func setSecureHeaders(h http.Header) {
+	for name, value := range headerPresets[headerPresetSecure] {
+		h.Set(name, value)
+	}
+}
+
+func (r HeaderRule) equal(other HeaderRule) bool {
+	return r.Path == other.Path &&
+		r.ContentType == other.ContentType &&
+		r.Preset == other.Preset &&
+		maps.Equal(r.Set, other.Set) &&
+		slices.Equal(r.Remove, other.Remove)
+}
+
+// matchesPath reports whether the rule applies to the request path.
+func (r HeaderRule) matchesPath(urlPath string) bool {
+	return matchPathPattern(r.Path, urlPath)
+}
+
+// matchPathPattern matches a glob pattern against the request path, patterns
+// without a "/" match the file name and an empty pattern matches everything.
+func matchPathPattern(pattern string, urlPath string) bool {
+	if pattern == "" {
+		return true
+	}
+	name := urlPath
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(urlPath)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// matchesContentType reports whether the rule applies to the response
+// Content-Type.
+func (r HeaderRule) matchesContentType(contentType string) bool {
+	if r.ContentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	ok, _ := path.Match(strings.ToLower(r.ContentType), mediaType)
+	return ok
+}
+
+func (r HeaderRule) apply(h http.Header) {
+	for name, value := range headerPresets[r.Preset] {
+		h.Set(name, value)
+	}
+	for name, value := range r.Set {
+		h.Set(name, value)
+	}
+	for _, name := range r.Remove {
+		h.Del(name)
+	}
+}
+
+// validate checks the patterns, preset and header names of the rule.
+func (r HeaderRule) validate() error {
+	if _, err := path.Match(r.Path, ""); err != nil {
+		return fmt.Errorf("invalid path pattern '%s', %w", r.Path, err)
+	}
+	if _, err := path.Match(r.ContentType, ""); err != nil {
+		return fmt.Errorf("invalid content type pattern '%s', %w", r.ContentType, err)
+	}
+	if _, ok := headerPresets[r.Preset]; r.Preset != "" && !ok {
+		return fmt.Errorf("unknown preset '%s', want one of %s", r.Preset, strings.Join(slices.Sorted(maps.Keys(headerPresets)), ", "))
+	}
+	if r.Preset == "" && len(r.Set) == 0 && len(r.Remove) == 0 {
+		return fmt.Errorf("rule does not set or remove any headers")
+	}
+	for _, name := range append(slices.Sorted(maps.Keys(r.Set)), r.Remove...) {
+		if !isValidHeaderName(name) {
+			return fmt.Errorf("invalid header name '%s'", name)
+		}
+	}
+	return nil
+}
+
+// isValidHeaderName reports whether the name is an RFC 9110 token.
+func isValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c > 0x7e || !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || strings.ContainsRune("!#$%&'*+-.^_`|~", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// headerRulesHandler applies the header rules to the responses once their
+// Content-Type is known.
+type headerRulesHandler struct {
+	next  http.Handler
+	rules []HeaderRule
+}
+
+func (h *headerRulesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rules := []HeaderRule{}
+	for _, rule := range h.rules {
+		if rule.matchesPath(r.URL.Path) {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	h.next.ServeHTTP(&headerRulesWriter{ResponseWriter: w, rules: rules}, r)
+}
+
+type headerRulesWriter struct {
+	http.ResponseWriter
+	rules   []HeaderRule
+	applied bool
+}
+
+func (w *headerRulesWriter) applyRules() {
+	if w.applied {
+		return
+	}
+	w.applied = true
+	h := w.Header()
+	contentType := h.Get("Content-Type")
+	for _, rule := range w.rules {
+		if rule.matchesContentType(contentType) {
+			rule.apply(h)
+		}
+	}
+}
+
+func (w *headerRulesWriter) WriteHeader(statusCode int) {
+	w.applyRules()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *headerRulesWriter) Write(b []byte) (int, error) {
+	if !w.applied {
+		if _, ok := w.Header()["Content-Type"]; !ok && len(b) > 0 {
+			// Same as net/http so that the rules see the final Content-Type.
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.applyRules()
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *headerRulesWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
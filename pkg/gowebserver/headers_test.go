@@ -0,0 +1,129 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHeaderRuleMatches(t *testing.T) {
+	testCases := []struct {
+		rule        HeaderRule
+		path        string
+		contentType string
+		want        bool
+	}{
+		{rule: HeaderRule{}, path: "/a/b.txt", contentType: "text/plain", want: true},
+		{rule: HeaderRule{Path: "*.html"}, path: "/a/index.html", contentType: "text/html", want: true},
+		{rule: HeaderRule{Path: "*.html"}, path: "/a/index.htm", contentType: "text/html", want: false},
+		{rule: HeaderRule{Path: "/assets/*"}, path: "/assets/app.js", contentType: "text/javascript", want: true},
+		{rule: HeaderRule{Path: "/assets/*"}, path: "/assets/img/a.png", contentType: "image/png", want: false},
+		{rule: HeaderRule{ContentType: "image/*"}, path: "/a.png", contentType: "image/png", want: true},
+		{rule: HeaderRule{ContentType: "text/html"}, path: "/a", contentType: "text/html; charset=utf-8", want: true},
+		{rule: HeaderRule{ContentType: "text/html"}, path: "/a", contentType: "", want: false},
+		{rule: HeaderRule{Path: "*.svg", ContentType: "image/*"}, path: "/a.svg", contentType: "text/plain", want: false},
+	}
+
+	for _, tc := range testCases {
+		got := tc.rule.matchesPath(tc.path) && tc.rule.matchesContentType(tc.contentType)
+		if got != tc.want {
+			t.Errorf("%+v matches(%q, %q) got %t, want %t", tc.rule, tc.path, tc.contentType, got, tc.want)
+		}
+	}
+}
+
+func TestHeaderRuleValidate(t *testing.T) {
+	testCases := []struct {
+		rule HeaderRule
+		want string
+	}{
+		{rule: HeaderRule{Preset: "secure"}, want: ""},
+		{rule: HeaderRule{Path: "*.html", Set: map[string]string{"X-Frame-Options": "DENY"}}, want: ""},
+		{rule: HeaderRule{Path: "["}, want: "invalid path pattern '[', syntax error in pattern"},
+		{rule: HeaderRule{ContentType: "[", Remove: []string{"Server"}}, want: "invalid content type pattern '[', syntax error in pattern"},
+		{rule: HeaderRule{Preset: "strict"}, want: "unknown preset 'strict', want one of secure"},
+		{rule: HeaderRule{Path: "*"}, want: "rule does not set or remove any headers"},
+		{rule: HeaderRule{Remove: []string{"Bad Header"}}, want: "invalid header name 'Bad Header'"},
+	}
+
+	for _, tc := range testCases {
+		got := ""
+		if err := tc.rule.validate(); err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("%+v validate() got %q, want %q", tc.rule, got, tc.want)
+		}
+	}
+}
+
+func TestHeaderRulesHandler(t *testing.T) {
+	fsys := fstest.MapFS{
+		"page.html":   {Data: []byte("<html></html>")},
+		"app.js":      {Data: []byte("alert(1)")},
+		"data.bin":    {Data: []byte{0, 1, 2}},
+		"assets/a.js": {Data: []byte("alert(2)")},
+	}
+	h := mountOptions{
+		cacheControl: "no-cache",
+		headers: []HeaderRule{
+			{ContentType: "text/html", Preset: "secure", Set: map[string]string{"X-Frame-Options": "SAMEORIGIN"}},
+			{Path: "/assets/*", Set: map[string]string{"Cache-Control": "public, max-age=31536000"}},
+			{Path: "*.bin", Set: map[string]string{"Content-Disposition": "attachment"}, Remove: []string{"Cache-Control"}},
+		},
+	}.wrapHandler(http.FileServer(http.FS(fsys)))
+
+	testCases := []struct {
+		path string
+		want map[string]string
+	}{
+		{path: "/page.html", want: map[string]string{"X-Frame-Options": "SAMEORIGIN", "X-Content-Type-Options": "nosniff", "Cache-Control": "no-cache", "Content-Disposition": ""}},
+		{path: "/app.js", want: map[string]string{"X-Frame-Options": "", "Cache-Control": "no-cache"}},
+		{path: "/assets/a.js", want: map[string]string{"X-Frame-Options": "", "Cache-Control": "public, max-age=31536000"}},
+		{path: "/data.bin", want: map[string]string{"Content-Disposition": "attachment", "Cache-Control": ""}},
+	}
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		got := map[string]string{}
+		for name := range tc.want {
+			got[name] = w.Header().Get(name)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("GET %s headers mismatch (-want +got):\n%s", tc.path, diff)
+		}
+	}
+}
+
+func TestGeneratedPagesAreSecure(t *testing.T) {
+	h, err := newIndexHTTPHandler([]string{"/a/"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	for name, want := range headerPresets[headerPresetSecure] {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("got %s %q, want %q", name, got, want)
+		}
+	}
+}
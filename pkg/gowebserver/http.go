@@ -34,9 +34,18 @@ func overlayURLProxy(target *url.URL, client *http.Client) *httputil.ReverseProx
 			req.URL.RawQuery = targetQuery + "&" + req.URL.RawQuery
 		}
 	}
+	// The request ID is forwarded in the request header, the one of the
+	// proxied server's response is dropped since the response already has it.
+	modifyResponse := func(resp *http.Response) error {
+		if requestID(resp.Request.Context()) != "" {
+			resp.Header.Del(requestIDHeader)
+		}
+		return nil
+	}
 	return &httputil.ReverseProxy{
-		Director:  director,
-		Transport: client.Transport,
+		Director:       director,
+		ModifyResponse: modifyResponse,
+		Transport:      client.Transport,
 	}
 }
 
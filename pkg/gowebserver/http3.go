@@ -0,0 +1,167 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/quic-go/quic-go/http3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	// altSvcMaxAge is how long, in seconds, clients may remember that HTTP/3 is
+	// available.
+	altSvcMaxAge = 86400
+)
+
+// http3Listener is a UDP socket and the HTTP/3 server that serves it.
+type http3Listener struct {
+	conn   net.PacketConn
+	server *http3.Server
+}
+
+// listenHTTP3 opens a UDP socket on the address and port of each TCP HTTPS
+// listener. Unix socket and systemd listeners are skipped. clientTLS holds
+// the client certificate settings, nil if there are none.
+func listenHTTP3(listeners []*serverListener, certs *certificateStore, clientTLS *tls.Config) ([]*http3Listener, error) {
+	result := []*http3Listener{}
+	for _, l := range listeners {
+		if l.name != "https" {
+			continue
+		}
+		addr, ok := l.socket.Addr().(*net.TCPAddr)
+		if !ok {
+			zap.S().With("address", l.server.Addr).Warn("HTTP/3 is only served on TCP listeners")
+			continue
+		}
+		conn, err := net.ListenPacket("udp", addr.String())
+		if err != nil {
+			for _, h3 := range result {
+				h3.conn.Close()
+			}
+			return nil, fmt.Errorf("cannot listen for HTTP/3 on '%s', %w", addr, err)
+		}
+		result = append(result, &http3Listener{
+			conn: conn,
+			server: &http3.Server{
+				Addr:      addr.String(),
+				TLSConfig: certs.tlsConfig(clientTLS),
+			},
+		})
+	}
+	return result, nil
+}
+
+// altSvcValue returns the Alt-Svc header value that advertises the HTTP/3
+// listeners, empty if there are none.
+func altSvcValue(listeners []*http3Listener) string {
+	values := []string{}
+	for _, l := range listeners {
+		if addr, ok := l.conn.LocalAddr().(*net.UDPAddr); ok {
+			value := fmt.Sprintf(`%s=":%d"; ma=%d`, http3.NextProtoH3, addr.Port, altSvcMaxAge)
+			if !slices.Contains(values, value) {
+				values = append(values, value)
+			}
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// altSvcHandler advertises HTTP/3 on the responses of the HTTPS listeners.
+func altSvcHandler(next http.Handler, altSvc string) http.Handler {
+	if altSvc == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", altSvc)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// http3MetricsHandler counts the requests served over HTTP/3 by method and
+// status code.
+type http3MetricsHandler struct {
+	next http.Handler
+	// monitoring returns the current monitoring context, which a reload can
+	// replace while the listener keeps serving.
+	monitoring func() *monitoringContext
+}
+
+func newHTTP3MetricsHandler(next http.Handler, monitoring func() *monitoringContext) http.Handler {
+	return &http3MetricsHandler{
+		next:       next,
+		monitoring: monitoring,
+	}
+}
+
+func (h *http3MetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sw := &statusResponseWriter{ResponseWriter: w}
+	defer func() {
+		mc := h.monitoring()
+		if mc == nil || mc.http3Requests == nil {
+			return
+		}
+		mc.http3Requests.Add(r.Context(), 1, metric.WithAttributes(
+			attribute.String("method", r.Method),
+			attribute.String("code", strconv.Itoa(sw.statusCode())),
+		))
+	}()
+	h.next.ServeHTTP(sw, r)
+}
+
+// statusResponseWriter records the status code and body size of the
+// response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,270 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremyje/gowebserver/v2/pkg/certtool"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestAltSvcHandler(t *testing.T) {
+	testCases := []struct {
+		altSvc string
+		want   string
+	}{
+		{altSvc: "", want: ""},
+		{altSvc: `h3=":8443"; ma=86400`, want: `h3=":8443"; ma=86400`},
+	}
+
+	for _, tc := range testCases {
+		h := altSvcHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), tc.altSvc)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got := w.Header().Get("Alt-Svc"); got != tc.want {
+			t.Errorf("altSvcHandler(%q) got Alt-Svc %q, want %q", tc.altSvc, got, tc.want)
+		}
+	}
+}
+
+func TestStatusResponseWriter(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{name: "empty", handler: func(w http.ResponseWriter, r *http.Request) {}, want: http.StatusOK},
+		{name: "write", handler: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }, want: http.StatusOK},
+		{name: "not found", handler: http.NotFound, want: http.StatusNotFound},
+		{name: "superfluous", handler: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+			w.WriteHeader(http.StatusOK)
+		}, want: http.StatusTeapot},
+	}
+
+	for _, tc := range testCases {
+		sw := &statusResponseWriter{ResponseWriter: httptest.NewRecorder()}
+		tc.handler(sw, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got := sw.statusCode(); got != tc.want {
+			t.Errorf("%s: got status %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWebServer_HTTP3(t *testing.T) {
+	dir := mustTempDir(t)
+	certFile := filepath.Join(dir, "web.cert")
+	keyFile := filepath.Join(dir, "web.key")
+	if _, err := certtool.GenerateAndWriteKeyPair(&certtool.Args{
+		KeyType:   &certtool.KeyType{Algorithm: "ECDSA", KeyLength: 256},
+		Validity:  time.Hour,
+		Hostnames: []string{"localhost", "127.0.0.1"},
+	}, certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{
+		Serve: []Serve{{Source: dir, Endpoint: "/"}},
+		HTTP:  HTTP{Addresses: []string{"127.0.0.1:0"}},
+		HTTPS: HTTPS{
+			Addresses: []string{"127.0.0.1:0"},
+			HTTP3:     true,
+			Certificate: Certificate{
+				CertificateFilePath: certFile,
+				PrivateKeyFilePath:  keyFile,
+			},
+		},
+		Monitoring: Monitoring{
+			Metrics: Metrics{Enabled: true, Path: "/metrics"},
+		},
+		Admin: Admin{Endpoint: "/admin", Addresses: []string{"127.0.0.1:0"}, TokenFile: mustAdminTokenFile(t)},
+	}
+	ws, baseURL, close := serveAsyncServer(t, cfg)
+	defer close()
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	httpsClient := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	h3Transport := &http3.Transport{TLSClientConfig: tlsConfig}
+	defer h3Transport.Close()
+	h3Client := &http.Client{Transport: h3Transport}
+
+	_, httpsPort := ws.getPorts()
+	httpsURL := fmt.Sprintf("https://127.0.0.1:%d", httpsPort)
+	resp, err := httpsClient.Get(httpsURL + "/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	altSvc := resp.Header.Get("Alt-Svc")
+	m := regexp.MustCompile(`^h3=":(\d+)"; ma=\d+$`).FindStringSubmatch(altSvc)
+	if m == nil {
+		t.Fatalf("got Alt-Svc %q, want an h3 entry", altSvc)
+	}
+
+	h3URL := fmt.Sprintf("https://127.0.0.1:%s", m[1])
+	resp, err = h3Client.Get(h3URL + "/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ProtoMajor != 3 {
+		t.Errorf("got protocol %s, want HTTP/3", resp.Proto)
+	}
+	if string(body) != "hello" {
+		t.Errorf("got body %q, want %q", body, "hello")
+	}
+
+	// The admin API is only served on its own listener.
+	req, err := http.NewRequest(http.MethodGet, h3URL+"/admin/v1/mounts", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	resp, err = h3Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /admin/v1/mounts over HTTP/3 got %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	metrics := mustGetBody(t, baseURL+"/metrics")
+	if !strings.Contains(metrics, `http3_requests_total{code="200",method="GET"`) {
+		t.Errorf("want http3_requests_total in the metrics, got:\n%s", metrics)
+	}
+}
+
+func TestWebServer_ReloadKeepsListenerMetrics(t *testing.T) {
+	dir := mustTempDir(t)
+	certFile := filepath.Join(dir, "web.cert")
+	keyFile := filepath.Join(dir, "web.key")
+	if err := certtool.WriteKeyPair(mustGenerateKeyPair(t, &certtool.Args{Hostnames: []string{"127.0.0.1"}}), certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(mustTempDir(t), "gowebserver.yaml")
+	writeConfig := func(content string) {
+		if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig("enhancedList: true\n")
+
+	ws, baseURL, close := serveAsyncServer(t, &Config{
+		Serve: []Serve{{Source: dir, Endpoint: "/"}},
+		HTTP:  HTTP{Addresses: []string{"127.0.0.1:0"}, Limits: Limits{MaxConnections: 1}},
+		HTTPS: HTTPS{
+			Addresses:   []string{"127.0.0.1:0"},
+			HTTP3:       true,
+			Certificate: Certificate{CertificateFilePath: certFile, PrivateKeyFilePath: keyFile},
+		},
+		Monitoring:        Monitoring{Metrics: Metrics{Enabled: true, Path: "/metrics"}},
+		ConfigurationFile: configFile,
+	})
+	defer close()
+
+	mc := ws.currentMonitoring()
+	if err := ws.reloadAndReport("test"); err != nil {
+		t.Fatal(err)
+	}
+	if ws.currentMonitoring() != mc {
+		t.Error("want the monitoring context kept by a reload that does not change it")
+	}
+	writeConfig("monitoring:\n  metrics:\n    enabled: true\n    path: /metrics\n  debugEndpoint: /debug\n")
+	if err := ws.reloadAndReport("test"); err != nil {
+		t.Fatal(err)
+	}
+	if ws.currentMonitoring() == mc {
+		t.Error("want a new monitoring context after the monitoring configuration changed")
+	}
+	// Let the previous generation retire and shut its monitoring context down.
+	time.Sleep(100 * time.Millisecond)
+
+	h3Transport := &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	defer h3Transport.Close()
+	_, httpsPort := ws.getPorts()
+	resp, err := (&http.Client{Transport: h3Transport}).Get(fmt.Sprintf("https://127.0.0.1:%d/hello.txt", httpsPort))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// One of two connections is over the limit of the listener.
+	http.DefaultClient.CloseIdleConnections()
+	httpAddr := strings.TrimPrefix(baseURL, "http://")
+	closed := 0
+	conns := []net.Conn{}
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", httpAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		if _, err := conn.Read(make([]byte, 1)); errors.Is(err, io.EOF) {
+			closed++
+		}
+		conn.Close()
+	}
+	if closed == 0 {
+		t.Error("want a connection over the limit closed")
+	}
+
+	// The connections are released asynchronously.
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	defer client.CloseIdleConnections()
+	metrics := ""
+	for i := 0; i < 50 && !strings.Contains(metrics, "rejected_connections_total"); i++ {
+		resp, err := client.Get(baseURL + "/metrics")
+		if err != nil {
+			time.Sleep(20 * time.Millisecond)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		metrics = string(body)
+	}
+	for _, name := range []string{"http3_requests_total", "rejected_connections_total", "config_reloads_total"} {
+		if !strings.Contains(metrics, name) {
+			t.Errorf("want %s in the metrics after a reload, got:\n%s", name, metrics)
+		}
+	}
+}
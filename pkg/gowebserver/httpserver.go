@@ -15,28 +15,47 @@
 package gowebserver
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cloudfra/ufs"
-	"github.com/rs/cors"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.uber.org/zap"
 )
 
 // WebServer is a convenience wrapper for Go's HTTP/HTTPS Web serving API.
 type WebServer interface {
-	// Serve starts serving the HTTP/HTTPS server synchronously.
-	Serve(func()) error
+	// Serve starts serving the HTTP/HTTPS server synchronously, until wait
+	// returns or the server is shut down.
+	Serve(wait func()) error
+	// Start opens the listeners and serves in the background.
+	Start() error
+	// Shutdown stops the server. In-flight requests are drained until ctx is
+	// done or the drain timeout passes, Shutdown returns ctx's error if it is
+	// done first.
+	Shutdown(ctx context.Context) error
+	// Handler returns the handler of the mounts, uploads and monitoring
+	// endpoints, e.g. to serve them from another http.Server. It follows
+	// configuration reloads and stops working once the server is shut down.
+	Handler() (http.Handler, error)
+	// Addrs returns the addresses of the HTTP and HTTPS listeners, empty until
+	// the server is started.
+	Addrs() []net.Addr
 }
 
 type webServerImpl struct {
-	httpAddr            string
-	httpsAddr           string
+	httpListen          []listenSpec
+	httpsListen         []listenSpec
 	metricsEnabled      bool
 	fileSystemServePath []servePath
 	metricsServePath    string
@@ -46,8 +65,52 @@ type webServerImpl struct {
 	uploadPath          string
 	uploadHTTPPath      string
 	enhancedListMode    bool
-	enableDebugMethods  bool
 	monitoringCtx       *monitoringContext
+	configurationFile   string
+	// baseConfig is the lowest layer of the reloaded configuration, the
+	// defaults of the flags if nil.
+	baseConfig      *Config
+	handler         *swappableHandler
+	killFunc        func()
+	drainTimeout    time.Duration
+	requests        *requestTracker
+	limits          Limits
+	httpDisabled    bool
+	httpsDisabled   bool
+	redirectToHTTPS bool
+	hsts            HSTS
+	http3Enabled    bool
+	cors            CORS
+	accessLog       AccessLog
+	rateLimit       RateLimit
+	uploadRateLimit RateLimit
+	ipFilter        IPFilter
+	uploadIPFilter  IPFilter
+	metricsIPFilter IPFilter
+	debugIPFilter   IPFilter
+	clientIP        ClientIP
+	bandwidth       Bandwidth
+	auth            Auth
+	clientAuth      ClientAuth
+	uploadAccess    map[string][]string
+	admin           Admin
+	adminListen     []listenSpec
+	compression     Compression
+	middleware      []Middleware
+	opts            []Option
+	// server is the listening server, the admin API of a reloaded
+	// configuration controls it.
+	server *webServerImpl
+	certs  *certificateStore
+	conns  *connTracker
+
+	loadMu   sync.Mutex
+	reloadMu sync.Mutex
+	// reloadStopped rejects the reloads that race with the shutdown, guarded
+	// by reloadMu.
+	reloadStopped bool
+	run           *serverRun
+	addrs         []net.Addr
 
 	httpListenPort  int
 	httpsListenPort int
@@ -57,7 +120,11 @@ type webServerImpl struct {
 
 type servePath struct {
 	localPath string
-	httpPath  string
+	// fsys is served instead of localPath if set.
+	fsys     fs.FS
+	httpPath string
+	options  mountOptions
+	hosts    []string
 }
 
 func expandPath(dir string) (string, error) {
@@ -72,6 +139,7 @@ func expandPath(dir string) (string, error) {
 }
 
 func (ws *webServerImpl) addHandler(serverMux *http.ServeMux, servePath string, handler http.Handler) {
+	handler = withRequestIDSpan(withAccessLogMount(handler, servePath))
 	if ws.metricsEnabled {
 		serverMux.Handle(servePath, otelhttp.NewHandler(handler, servePath, otelhttp.WithTracerProvider(ws.monitoringCtx.getTraceProvider()), otelhttp.WithMeterProvider(ws.monitoringCtx.getMeterProvider())))
 	} else {
@@ -102,165 +170,644 @@ func (ws *webServerImpl) getPorts() (int, int) {
 	return httpPort, httpsPort
 }
 
-type killHTTPServerHandler struct {
-	killFunc func()
+// serverListener is an enabled listener and the server that serves it.
+type serverListener struct {
+	name   string
+	socket *onceCloseListener
+	server *http.Server
 }
 
-func (k *killHTTPServerHandler) ServeHTTP(http.ResponseWriter, *http.Request) {
-	if k.killFunc != nil {
-		k.killFunc()
-		os.Exit(0)
+// serverRun is the state of a started server.
+type serverRun struct {
+	listeners []*serverListener
+	stopOnce  sync.Once
+	// stopping is closed when shutdown begins, ctx is then the context of the
+	// drain.
+	stopping chan struct{}
+	ctx      context.Context
+	// closing is set before the listeners are closed so that their accept
+	// errors are not reported.
+	closing atomic.Bool
+	// stopped is closed once the server has shut down.
+	stopped chan struct{}
+}
+
+// stop begins the shutdown. New connections are refused right away, draining
+// happens in the background.
+func (r *serverRun) stop(ctx context.Context) {
+	r.stopOnce.Do(func() {
+		r.ctx = ctx
+		r.closing.Store(true)
+		for _, l := range r.listeners {
+			l.socket.Close()
+		}
+		close(r.stopping)
+	})
+}
+
+// kill stops the server like Shutdown without waiting, it does nothing if the
+// server is not running.
+func (ws *webServerImpl) kill() {
+	ws.RLock()
+	run := ws.run
+	ws.RUnlock()
+	if run != nil {
+		run.stop(context.Background())
 	}
 }
 
 func (ws *webServerImpl) Serve(wait func()) error {
-	allCleanups := []func() error{}
+	if err := ws.Start(); err != nil {
+		return err
+	}
+	ws.RLock()
+	run := ws.run
+	ws.RUnlock()
+
+	waitDone := make(chan struct{})
+	go func() {
+		wait()
+		close(waitDone)
+	}()
+	select {
+	case <-waitDone:
+	case <-run.stopping:
+	}
+	return ws.Shutdown(context.Background())
+}
+
+func (ws *webServerImpl) Start() error {
+	ws.Lock()
+	if ws.run != nil {
+		ws.Unlock()
+		return fmt.Errorf("cannot start, the server has already been started")
+	}
+	run := &serverRun{stopping: make(chan struct{}), stopped: make(chan struct{})}
+	ws.run = run
+	ws.Unlock()
 
-	displayPath := ""
-	for i, paths := range ws.fileSystemServePath {
-		if i > 0 {
-			displayPath += ","
+	// cleanups run in reverse order once the server has stopped, or if it
+	// cannot start.
+	cleanups := []func(){}
+	started := false
+	defer func() {
+		if !started {
+			run.stop(context.Background())
+			for i := len(cleanups) - 1; i >= 0; i-- {
+				cleanups[i]()
+			}
+			close(run.stopped)
 		}
-		displayPath += paths.localPath
+	}()
+	cleanups = append(cleanups, func() {
+		for _, l := range run.listeners {
+			l.socket.Close()
+		}
+	})
+
+	listen := func(name string, specs []listenSpec) error {
+		for _, spec := range specs {
+			lis, err := spec.listen()
+			if err != nil {
+				return fmt.Errorf("cannot listen on '%s', %w", spec.address, err)
+			}
+			run.listeners = append(run.listeners, &serverListener{
+				name:   name,
+				socket: &onceCloseListener{Listener: lis},
+				server: &http.Server{Addr: spec.address},
+			})
+		}
+		return nil
 	}
-	serverMux := http.NewServeMux()
-	if ws.monitoringCtx != nil {
-		for endpoint, h := range ws.monitoringCtx.handlers {
-			zap.S().With("http", endpoint).Info("Endpoint")
-			serverMux.Handle(endpoint, h)
+	if !ws.httpDisabled {
+		if err := listen("http", ws.httpListen); err != nil {
+			return err
 		}
-		allCleanups = append(allCleanups, func() error {
-			ws.monitoringCtx.shutdown()
-			return nil
-		})
 	}
-
-	mounts := map[string]string{}
-	rootPath := ""
-	for _, paths := range ws.fileSystemServePath {
-		zap.S().With("localPath", paths.localPath, "http", paths.httpPath).Info("Endpoint")
-		if paths.httpPath == "" || paths.httpPath == "/" {
-			rootPath = paths.localPath
-		} else {
-			mounts[strings.TrimLeft(paths.httpPath, "/")] = paths.localPath
+	if !ws.httpsDisabled {
+		if err := listen("https", ws.httpsListen); err != nil {
+			return err
 		}
 	}
+	if len(run.listeners) == 0 {
+		return fmt.Errorf("cannot serve, both the HTTP and HTTPS listeners are disabled")
+	}
+	if err := listen("admin", ws.adminListen); err != nil {
+		return err
+	}
+	listeners := run.listeners
 
-	if rootPath == "" && len(mounts) > 0 {
-		// No root endpoint configured but non-root mounts exist: generate a root
-		// index listing and register each mount with its own handler.
-		servePaths := make([]string, 0, len(ws.fileSystemServePath))
-		for _, paths := range ws.fileSystemServePath {
-			servePaths = append(servePaths, paths.httpPath)
-		}
-		indexHandler, err := newIndexHTTPHandler(servePaths, ws.enhancedListMode)
+	if err := ws.loadHandler(); err != nil {
+		return err
+	}
+	// Runs after draining so that the file systems and monitoring outlive the
+	// requests that use them.
+	cleanups = append(cleanups, ws.handler.close)
+
+	if ws.configurationFile != "" {
+		stopWatching, err := ws.watchConfig()
 		if err != nil {
-			return err
+			zap.S().With("error", err, "configFile", ws.configurationFile).Warn("cannot watch configuration file for changes")
+		} else {
+			cleanups = append(cleanups, stopWatching)
 		}
-		ws.addHandler(serverMux, "/", indexHandler)
+	}
 
-		for _, paths := range ws.fileSystemServePath {
-			fsHandler, cleanup, err := newHandlerFromFS(paths.localPath, ws.monitoringCtx.getTraceProvider(), ws.enhancedListMode)
-			if err != nil {
-				return err
-			}
-			allCleanups = append(allCleanups, cleanup)
-			httpPath := paths.httpPath
-			strippedPrefix := strings.TrimRight(httpPath, "/")
-			ws.addHandler(serverMux, httpPath, http.StripPrefix(strippedPrefix, fsHandler))
+	// The first TCP listener of each protocol determines its port, e.g. for
+	// redirects to HTTPS.
+	httpPort, httpsPort := 0, 0
+	httpURLs, httpsURLs := []string{}, []string{}
+	addrs := []net.Addr{}
+	adminURLs := []string{}
+	for _, l := range listeners {
+		if l.name == "admin" {
+			adminURLs = append(adminURLs, listenerURL("http", l.socket))
+			continue
 		}
-	} else {
-		if rootPath == "" {
-			rootPath = "null://"
+		port, _ := getPort(l.socket)
+		addrs = append(addrs, l.socket.Addr())
+		if l.name == "http" {
+			httpURLs = append(httpURLs, listenerURL("http", l.socket))
+			if httpPort == 0 {
+				httpPort = port
+			}
+		} else {
+			httpsURLs = append(httpsURLs, listenerURL("https", l.socket))
+			if httpsPort == 0 {
+				httpsPort = port
+			}
 		}
-		fsSpec, err := ufs.CreateURI(rootPath, mounts)
+	}
+	zap.S().With("HTTP", httpURLs, "HTTPS", httpsURLs).Info("Serving")
+	if len(adminURLs) > 0 {
+		zap.S().With("admin", adminURLs).Info("Serving")
+	}
+
+	ws.setPorts(httpPort, httpsPort)
+	ws.Lock()
+	ws.addrs = addrs
+	ws.Unlock()
+
+	servers := []shutdownServer{}
+	wg := &sync.WaitGroup{}
+
+	clientTLS, err := ws.clientAuth.tlsConfig()
+	if err != nil {
+		return err
+	}
+	if clientTLS != nil && !ws.httpsDisabled {
+		zap.S().With("mode", ws.clientAuth.Mode, "caFile", ws.clientAuth.CAFile, "identity", ws.clientAuth.Identity).Info("Client certificate authentication")
+	}
+
+	var certs *certificateStore
+	if !ws.httpsDisabled && ws.certificateFilePath != "" {
+		certs, err = newCertificateStore(ws.certificateFilePath, ws.privateKeyFilePath)
 		if err != nil {
 			return err
 		}
-		fsHandler, cleanup, err := newHandlerFromFS(fsSpec, ws.monitoringCtx.getTraceProvider(), ws.enhancedListMode)
+		ws.Lock()
+		ws.certs = certs
+		ws.Unlock()
+	}
+
+	altSvc := ""
+	if ws.http3Enabled && certs != nil {
+		h3Listeners, err := listenHTTP3(listeners, certs, clientTLS)
 		if err != nil {
 			return err
 		}
-		allCleanups = append(allCleanups, cleanup)
-		ws.addHandler(serverMux, "/", fsHandler)
+		cleanups = append(cleanups, func() {
+			for _, l := range h3Listeners {
+				l.conn.Close()
+			}
+		})
+		var handler http.Handler = ws.handler
+		if len(ws.adminListen) > 0 {
+			handler = ws.adminListenerHandler(handler, false)
+		}
+		h3Handler := newHTTP3MetricsHandler(ws.hstsHandler(handler), ws.currentMonitoring)
+		h3URLs := []string{}
+		for _, l := range h3Listeners {
+			l.server.Handler = ws.requests.wrap(h3Handler)
+			l.server.MaxHeaderBytes = ws.limits.MaxHeaderBytes
+			l.server.IdleTimeout = ws.limits.IdleTimeout
+			servers = append(servers, l.server)
+			h3URLs = append(h3URLs, "https://"+l.conn.LocalAddr().String())
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				run.checkServeError(l.server.Serve(l.conn))
+			}()
+		}
+		altSvc = altSvcValue(h3Listeners)
+		zap.S().With("HTTP3", h3URLs).Info("Serving")
 	}
 
-	defer func() {
-		for _, cleanup := range allCleanups {
-			if err := cleanup(); err != nil {
-				zap.S().With("error", err).Error("cleanup error")
+	for _, l := range listeners {
+		lis := newLimitListener(l.socket, ws.limits.MaxConnections, l.name, ws.currentMonitoring)
+		ws.limits.applyTo(l.server)
+		l.server.ConnState = ws.conns.connState(l.name)
+		servers = append(servers, l.server)
+
+		adminListener := l.name == "admin"
+		var handler http.Handler = ws.handler
+		if len(ws.adminListen) > 0 {
+			handler = ws.adminListenerHandler(handler, adminListener)
+		}
+		switch {
+		case l.name == "http" || adminListener:
+			if !adminListener {
+				handler = ws.httpHandler(handler)
 			}
+			l.server.Handler = ws.requests.wrap(handler)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				run.checkServeError(l.server.Serve(lis))
+			}()
+		case certs != nil:
+			l.server.Handler = ws.requests.wrap(altSvcHandler(ws.hstsHandler(handler), altSvc))
+			l.server.TLSConfig = certs.tlsConfig(clientTLS)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				run.checkServeError(l.server.ServeTLS(lis, "", ""))
+			}()
 		}
-	}()
+	}
 
-	if len(ws.uploadHTTPPath) > 0 {
-		uploadHandler, err := newUploadHandler(ws.monitoringCtx, ws.uploadHTTPPath, ws.uploadPath)
-		if err != nil {
-			return err
+	started = true
+	go func() {
+		<-run.stopping
+		ws.drain(run.ctx, servers)
+		wg.Wait()
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
 		}
-		ws.addHandler(serverMux, ws.uploadHTTPPath, uploadHandler)
+		close(run.stopped)
+	}()
+	return nil
+}
+
+func (ws *webServerImpl) Shutdown(ctx context.Context) error {
+	ws.RLock()
+	run := ws.run
+	ws.RUnlock()
+	if run == nil {
+		// Only the handler of Handler can be running.
+		ws.handler.close()
+		return nil
 	}
+	run.stop(ctx)
+	select {
+	case <-run.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (ws *webServerImpl) Handler() (http.Handler, error) {
+	if err := ws.loadHandler(); err != nil {
+		return nil, err
+	}
+	return ws.handler, nil
+}
 
-	httpSocket, err := net.Listen("tcp", ws.httpAddr)
+func (ws *webServerImpl) Addrs() []net.Addr {
+	ws.RLock()
+	defer ws.RUnlock()
+	return slices.Clone(ws.addrs)
+}
+
+// currentMonitoring returns the monitoring context of the current
+// configuration, for the parts of the server that outlive a reload.
+func (ws *webServerImpl) currentMonitoring() *monitoringContext {
+	ws.RLock()
+	defer ws.RUnlock()
+	return ws.monitoringCtx
+}
+
+// loadHandler builds the handler tree unless Handler or Start already did.
+func (ws *webServerImpl) loadHandler() error {
+	ws.loadMu.Lock()
+	defer ws.loadMu.Unlock()
+	if ws.handler.loaded() {
+		return nil
+	}
+	handler, cleanup, err := ws.buildHandler()
 	if err != nil {
 		return err
 	}
+	ws.handler.swap(handler, cleanup)
+	return nil
+}
 
-	defer httpSocket.Close()
+// checkServeError logs the error that stopped a server unless it comes from
+// the shutdown, which closes the listeners before the servers.
+func (r *serverRun) checkServeError(err error) {
+	if errors.Is(err, http.ErrServerClosed) || (r.closing.Load() && errors.Is(err, net.ErrClosed)) {
+		return
+	}
+	checkError(err)
+}
+
+// buildHandler constructs the complete HTTP handler tree for the current
+// configuration. The returned cleanup function releases the file systems and
+// monitoring resources owned by the handler.
+func (ws *webServerImpl) buildHandler() (http.Handler, func(), error) {
+	allCleanups := []func() error{}
+	cleanupAll := func() {
+		for _, cleanup := range allCleanups {
+			if err := cleanup(); err != nil {
+				zap.S().With("error", err).Error("cleanup error")
+			}
+		}
+	}
+	// The monitoring context of this generation, ws.monitoringCtx is
+	// replaced by a reload before this generation retires.
+	mc := ws.monitoringCtx
+	mc.acquire()
+	allCleanups = append(allCleanups, func() error {
+		mc.release()
+		return nil
+	})
+
+	downloads, closeDownloads, err := newBandwidthLimiter(bandwidthDownload, ws.bandwidth.Download, ws.monitoringCtx)
+	if err != nil {
+		return nil, nilFunc, err
+	}
+	allCleanups = append(allCleanups, closeDownloads)
+	uploads, closeUploads, err := newBandwidthLimiter(bandwidthUpload, ws.bandwidth.Upload, ws.monitoringCtx)
+	if err != nil {
+		cleanupAll()
+		return nil, nilFunc, err
+	}
+	allCleanups = append(allCleanups, closeUploads)
 
-	httpsSocket, err := net.Listen("tcp", ws.httpsAddr)
+	auth, err := newAuthenticator(ws.auth, ws.clientAuth)
 	if err != nil {
-		return err
+		cleanupAll()
+		return nil, nilFunc, err
+	}
+	compression, err := newCompressor(ws.compression)
+	if err != nil {
+		cleanupAll()
+		return nil, nilFunc, err
 	}
 
-	if ws.enableDebugMethods {
-		killFunc := func() {
-			if httpsSocket != nil {
-				httpsSocket.Close()
+	serverMux := http.NewServeMux()
+	if ws.monitoringCtx != nil {
+		for endpoint, h := range ws.monitoringCtx.handlers {
+			zap.S().With("http", endpoint).Info("Endpoint")
+			filter, scope := ws.debugIPFilter, "debug"
+			if endpoint == ws.metricsServePath {
+				filter, scope = ws.metricsIPFilter, "metrics"
 			}
-			if httpSocket != nil {
-				httpSocket.Close()
+			h, err = newIPFilterHandler(h, filter, scope, ws.monitoringCtx)
+			if err != nil {
+				cleanupAll()
+				return nil, nilFunc, err
 			}
+			serverMux.Handle(endpoint, ws.cors.handler(h))
 		}
+	}
 
-		zap.S().With("http", "/diediedie").Info("Endpoint")
-		ws.addHandler(serverMux, "/diediedie", &killHTTPServerHandler{killFunc: killFunc})
+	defaultSite, virtualHosts := groupVirtualHosts(ws.fileSystemServePath)
+	if len(virtualHosts) == 0 {
+		cleanups, err := ws.addSite(serverMux, defaultSite, downloads, compression, auth)
+		allCleanups = append(allCleanups, cleanups...)
+		if err != nil {
+			cleanupAll()
+			return nil, nilFunc, err
+		}
+	} else {
+		router := &hostRouter{hosts: virtualHosts}
+		sites := append([]*virtualHost{{paths: defaultSite}}, virtualHosts...)
+		for _, vh := range sites {
+			siteMux := http.NewServeMux()
+			cleanups, err := ws.addSite(siteMux, vh.paths, downloads, compression, auth)
+			allCleanups = append(allCleanups, cleanups...)
+			if err != nil {
+				cleanupAll()
+				return nil, nilFunc, err
+			}
+			vh.handler = siteMux
+		}
+		router.fallback = sites[0].handler
+		serverMux.Handle("/", router)
+	}
+
+	if len(ws.uploadHTTPPath) > 0 {
+		uploadHandler, err := newUploadHandler(ws.monitoringCtx, ws.uploadHTTPPath, ws.uploadPath, ws.limits.multipartMemoryBytes(), ws.cors)
+		if err != nil {
+			cleanupAll()
+			return nil, nilFunc, err
+		}
+		uploadHandler = auth.accessHandler(uploads.requestHandler(uploadHandler), ws.uploadAccess, uploadPermission)
+		uploadHandler, err = newRateLimitHandler(uploadHandler, ws.uploadRateLimit, ws.uploadHTTPPath, ws.monitoringCtx)
+		if err != nil {
+			cleanupAll()
+			return nil, nilFunc, err
+		}
+		uploadHandler, err = newIPFilterHandler(uploadHandler, ws.uploadIPFilter, ws.uploadHTTPPath, ws.monitoringCtx)
+		if err != nil {
+			cleanupAll()
+			return nil, nilFunc, err
+		}
+		ws.addHandler(serverMux, ws.uploadHTTPPath, ws.cors.handler(uploadHandler))
 	}
 
-	defer httpsSocket.Close()
+	if auth != nil {
+		zap.S().With("http", auth.loginPath, "users", len(auth.users)).Info("Login endpoint")
+		ws.addHandler(serverMux, auth.loginPath, http.HandlerFunc(auth.serveLogin))
+		if auth.oidc != nil {
+			zap.S().With("issuer", auth.oidc.conf.Issuer, "http", auth.oidc.startPath, "callback", auth.oidc.callbackPath).Info("Single sign-on")
+			ws.addHandler(serverMux, auth.oidc.startPath, http.HandlerFunc(auth.serveOIDCStart))
+			ws.addHandler(serverMux, auth.oidc.callbackPath, http.HandlerFunc(auth.serveOIDCCallback))
+		}
+	}
 
-	httpHandler := cors.Default().Handler(serverMux)
+	if ws.admin.Endpoint != "" {
+		admin, err := newAdminHandler(ws.server, ws.admin)
+		if err != nil {
+			cleanupAll()
+			return nil, nilFunc, err
+		}
+		zap.S().With("http", adminPrefix(ws.admin.Endpoint), "separateListener", len(ws.adminListen) > 0).Info("Admin API")
+		serverMux.Handle(adminPrefix(ws.admin.Endpoint)+"/", admin)
+	}
 
-	httpPort, err := getPort(httpSocket)
+	handler, err := newBodyLimitHandler(serverMux, ws.limits, ws.monitoringCtx)
 	if err != nil {
-		zap.S().With("error", err).Error("cannot get port from HTTP listener")
+		cleanupAll()
+		return nil, nilFunc, err
 	}
-	httpsPort, err := getPort(httpsSocket)
+	handler, err = newRateLimitHandler(handler, ws.rateLimit, "global", ws.monitoringCtx)
 	if err != nil {
-		zap.S().With("error", err).Error("cannot get port from HTTPS listener")
+		cleanupAll()
+		return nil, nilFunc, err
+	}
+	clientIP, err := newClientIPResolver(ws.clientIP)
+	if err != nil {
+		cleanupAll()
+		return nil, nilFunc, err
 	}
 
-	zap.S().With("HTTP", fmt.Sprintf("http://localhost:%d/", httpPort), "HTTPS", fmt.Sprintf("https://localhost:%d/", httpsPort)).Info("Serving")
+	accessLogger, closeAccessLog, err := newAccessLogger(ws.accessLog)
+	if err != nil {
+		cleanupAll()
+		return nil, nilFunc, err
+	}
+	allCleanups = append(allCleanups, closeAccessLog)
+	filtered, err := newIPFilterHandler(auth.handler(applyMiddleware(handler, ws.middleware)), ws.ipFilter, "global", ws.monitoringCtx)
+	if err != nil {
+		cleanupAll()
+		return nil, nilFunc, err
+	}
+	return requestIDHandler(clientIP.handler(accessLogger.handler(filtered))), cleanupAll, nil
+}
 
-	ws.setPorts(httpPort, httpsPort)
+// addSite registers the handlers of the mounts of a single site on the mux and
+// returns the cleanup functions of their file systems.
+func (ws *webServerImpl) addSite(serverMux *http.ServeMux, sitePaths []servePath, downloads *bandwidthLimiter, compression *compressor, auth *authenticator) ([]func() error, error) {
+	cleanups := []func() error{}
+	mounts := map[string]string{}
+	// restricted are the access rules of the mounts, keyed by HTTP path, so
+	// that the root listing hides the mounts the user cannot read.
+	restricted := map[string]map[string][]string{}
+	// Mounts of an fs.FS cannot be part of the ufs root file system and always
+	// get their own handler.
+	rootPath := ""
+	var rootFS fs.FS
+	rootOptions := newMountOptions(Serve{}, ws.enhancedListMode, ws.cors)
+	for _, paths := range sitePaths {
+		zap.S().With("localPath", paths.localPath, "fs", paths.fsys != nil, "http", paths.httpPath, "hosts", paths.hosts).Info("Endpoint")
+		if paths.httpPath == "" || paths.httpPath == "/" {
+			rootPath = paths.localPath
+			rootFS = paths.fsys
+			rootOptions = paths.options
+		} else {
+			if paths.fsys == nil {
+				mounts[strings.TrimLeft(paths.httpPath, "/")] = paths.localPath
+			}
+			if len(paths.options.access) > 0 {
+				restricted[paths.httpPath] = paths.options.access
+			}
+		}
+	}
 
-	go func() {
-		if ws.certificateFilePath != "" {
-			checkError(http.ServeTLS(httpsSocket, httpHandler, ws.certificateFilePath, ws.privateKeyFilePath))
+	addMount := func(paths servePath) error {
+		fsHandler, cleanup, err := paths.newHandler(ws.monitoringCtx.getTraceProvider())
+		if err != nil {
+			return err
 		}
-	}()
-	go func() {
-		checkError(http.Serve(httpSocket, httpHandler))
-	}()
+		cleanups = append(cleanups, cleanup)
+		fsHandler = auth.accessHandler(downloads.responseHandler(compression.handler(fsHandler)), paths.options.access, methodPermission)
+		fsHandler, err = newRateLimitHandler(fsHandler, paths.options.rateLimit, paths.httpPath, ws.monitoringCtx)
+		if err != nil {
+			return err
+		}
+		fsHandler, err = newIPFilterHandler(fsHandler, paths.options.ipFilter, paths.httpPath, ws.monitoringCtx)
+		if err != nil {
+			return err
+		}
+		httpPath := paths.httpPath
+		strippedPrefix := strings.TrimRight(httpPath, "/")
+		ws.addHandler(serverMux, httpPath, http.StripPrefix(strippedPrefix, fsHandler))
+		return nil
+	}
 
-	wait()
-	return nil
+	if rootPath == "" && rootFS == nil && len(sitePaths) > 0 {
+		// No root endpoint configured but non-root mounts exist: generate a root
+		// index listing and register each mount with its own handler.
+		servePaths := make([]string, 0, len(sitePaths))
+		for _, paths := range sitePaths {
+			servePaths = append(servePaths, paths.httpPath)
+		}
+		indexHandler, err := newIndexHTTPHandler(servePaths, ws.enhancedListMode)
+		if err != nil {
+			return cleanups, err
+		}
+		ws.addHandler(serverMux, "/", ws.cors.handler(auth.listingHandler(compression.handler(indexHandler), restricted)))
+
+		for _, paths := range sitePaths {
+			if err := addMount(paths); err != nil {
+				return cleanups, err
+			}
+		}
+	} else {
+		root := servePath{localPath: rootPath, fsys: rootFS, options: rootOptions}
+		if rootFS == nil {
+			if rootPath == "" {
+				rootPath = "null://"
+			}
+			fsSpec, err := ufs.CreateURI(rootPath, mounts)
+			if err != nil {
+				return cleanups, err
+			}
+			root.localPath = fsSpec
+		}
+		fsHandler, cleanup, err := root.newHandler(ws.monitoringCtx.getTraceProvider())
+		if err != nil {
+			return cleanups, err
+		}
+		cleanups = append(cleanups, cleanup)
+		fsHandler = auth.accessHandler(downloads.responseHandler(compression.handler(fsHandler)), rootOptions.access, methodPermission)
+		fsHandler, err = newRateLimitHandler(auth.listingHandler(fsHandler, restricted), rootOptions.rateLimit, "/", ws.monitoringCtx)
+		if err != nil {
+			return cleanups, err
+		}
+		fsHandler, err = newIPFilterHandler(fsHandler, rootOptions.ipFilter, "/", ws.monitoringCtx)
+		if err != nil {
+			return cleanups, err
+		}
+		ws.addHandler(serverMux, "/", fsHandler)
+
+		// Mounts are part of the root file system so that they show up in its
+		// listing. Mounts with their own options also get a dedicated handler
+		// which takes precedence for requests under the mount's path.
+		for _, paths := range sitePaths {
+			if paths.httpPath == "" || paths.httpPath == "/" {
+				continue
+			}
+			if paths.fsys == nil && rootFS == nil && paths.options.equal(rootOptions) {
+				continue
+			}
+			if err := addMount(paths); err != nil {
+				return cleanups, err
+			}
+		}
+	}
+
+	return cleanups, nil
 }
 
-func New(conf *Config) (WebServer, error) {
+// New creates a WebServer from the configuration and options.
+func New(conf *Config, opts ...Option) (WebServer, error) {
+	return newWebServer(conf, opts...)
+}
+
+func newWebServer(conf *Config, opts ...Option) (*webServerImpl, error) {
 	if conf == nil {
 		conf = &Config{}
 	}
+	o := &serverOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if conf.Debug {
+		zap.S().Warn("debug is deprecated and ignored, /diediedie is replaced by the shutdown of the admin API")
+	}
+	// Reloads apply the configuration file, environment and flags on top of
+	// the configuration given to New.
+	var baseConfig *Config
+	if !o.flagDefaultsBase {
+		var err error
+		if baseConfig, err = conf.clone(); err != nil {
+			return nil, err
+		}
+	}
 	sp := []servePath{}
 	for _, paths := range conf.Serve {
 		p, err := expandPath(paths.Source)
@@ -270,6 +817,16 @@ func New(conf *Config) (WebServer, error) {
 		sp = append(sp, servePath{
 			localPath: p,
 			httpPath:  normalizeHTTPPath(paths.Endpoint),
+			options:   newMountOptions(paths, conf.EnhancedList, conf.CORS),
+			hosts:     paths.Hosts,
+		})
+	}
+	for _, m := range o.mounts {
+		sp = append(sp, servePath{
+			fsys:     m.fsys,
+			httpPath: normalizeHTTPPath(m.serve.Endpoint),
+			options:  newMountOptions(m.serve, conf.EnhancedList, conf.CORS),
+			hosts:    m.serve.Hosts,
 		})
 	}
 
@@ -282,13 +839,38 @@ func New(conf *Config) (WebServer, error) {
 		uploadPath = dir
 	}
 
-	monitoringCtx, err := setupMonitoring(conf.Monitoring)
+	httpListen, err := newListenSpecs(conf.HTTP.Port, conf.HTTP.Addresses, conf.HTTP.UnixSocketMode)
+	if err != nil {
+		return nil, fmt.Errorf("cannot use HTTP addresses %v, %w", conf.HTTP.Addresses, err)
+	}
+	httpsListen, err := newListenSpecs(conf.HTTPS.Port, conf.HTTPS.Addresses, conf.HTTPS.UnixSocketMode)
 	if err != nil {
-		return nil, fmt.Errorf("cannot setup monitoring '%+v', %w", conf.Monitoring, err)
+		return nil, fmt.Errorf("cannot use HTTPS addresses %v, %w", conf.HTTPS.Addresses, err)
+	}
+
+	adminListen := []listenSpec{}
+	if len(conf.Admin.Addresses) > 0 {
+		adminListen, err = newListenSpecs(0, conf.Admin.Addresses, "")
+		if err != nil {
+			return nil, fmt.Errorf("cannot use admin addresses %v, %w", conf.Admin.Addresses, err)
+		}
 	}
+
+	monitoringCtx := o.monitoringCtx
+	if !monitoringCtx.reusableFor(conf.Monitoring) {
+		monitoringCtx, err = setupMonitoring(conf.Monitoring)
+		if err != nil {
+			return nil, fmt.Errorf("cannot setup monitoring '%+v', %w", conf.Monitoring, err)
+		}
+	}
+	drainTimeout := conf.Shutdown.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = defaultDrainTimeout
+	}
+
 	ws := &webServerImpl{
-		httpAddr:            toAddr(conf.HTTP.Port),
-		httpsAddr:           toAddr(conf.HTTPS.Port),
+		httpListen:          httpListen,
+		httpsListen:         httpsListen,
 		monitoringCtx:       monitoringCtx,
 		metricsEnabled:      conf.Monitoring.Metrics.Enabled,
 		fileSystemServePath: sp,
@@ -296,11 +878,42 @@ func New(conf *Config) (WebServer, error) {
 		certificateFilePath: conf.HTTPS.Certificate.CertificateFilePath,
 		privateKeyFilePath:  conf.HTTPS.Certificate.PrivateKeyFilePath,
 		enhancedListMode:    conf.EnhancedList,
-		enableDebugMethods:  conf.Debug,
 		uploadPath:          uploadPath,
 		uploadHTTPPath:      conf.Upload.Endpoint,
 		verbose:             conf.Verbose,
+		configurationFile:   conf.ConfigurationFile,
+		baseConfig:          baseConfig,
+		handler:             &swappableHandler{},
+		drainTimeout:        drainTimeout,
+		requests:            newRequestTracker(),
+		limits:              conf.HTTP.Limits,
+		httpDisabled:        conf.HTTP.Disabled,
+		httpsDisabled:       conf.HTTPS.Disabled,
+		redirectToHTTPS:     conf.HTTP.RedirectToHTTPS,
+		hsts:                conf.HTTPS.HSTS,
+		http3Enabled:        conf.HTTPS.HTTP3,
+		cors:                conf.CORS,
+		accessLog:           conf.AccessLog,
+		rateLimit:           conf.RateLimit,
+		uploadRateLimit:     conf.Upload.RateLimit,
+		ipFilter:            conf.IPFilter,
+		uploadIPFilter:      conf.Upload.IPFilter,
+		metricsIPFilter:     conf.Monitoring.IPFilter,
+		debugIPFilter:       conf.Monitoring.DebugIPFilter,
+		clientIP:            conf.ClientIP,
+		bandwidth:           conf.Bandwidth,
+		auth:                conf.Auth,
+		clientAuth:          conf.HTTPS.ClientAuth,
+		uploadAccess:        conf.Upload.Access,
+		admin:               conf.Admin,
+		compression:         conf.Compression,
+		adminListen:         adminListen,
+		conns:               newConnTracker(),
+		middleware:          o.middleware,
+		opts:                opts,
 	}
+	ws.killFunc = ws.kill
+	ws.server = ws
 
 	return ws, nil
 }
@@ -15,6 +15,7 @@
 package gowebserver
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -27,6 +28,9 @@ import (
 	"github.com/google/go-cmp/cmp"
 	gomainTesting "github.com/jeremyje/gomain/testing"
 	gowsTesting "github.com/jeremyje/gowebserver/v2/internal/gowebserver/testing"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 var (
@@ -38,34 +42,24 @@ var (
 	wantAssets1Txt = []byte("assets/1.txt")
 )
 
-func TestServeAndDie(t *testing.T) {
+func TestDieDieDieRemoved(t *testing.T) {
 	baseURL, close := serveAsync(t, &Config{Debug: true})
 	defer close()
 
 	resp, err := http.Get(baseURL + "/diediedie")
-	if err == nil || resp != nil {
-		t.Errorf("got response when server should be dead., Response: %v, Err: %s", resp, err)
-	}
-
-	resp, err = http.Get(baseURL)
-	if err == nil || resp != nil {
-		t.Errorf("got response when server should be dead., Response: %v, Err: %s", resp, err)
+	if err != nil {
+		t.Fatal(err)
 	}
-}
-
-func TestDieDieDieDisabled(t *testing.T) {
-	baseURL, close := serveAsync(t, &Config{})
-	defer close()
-
-	resp, err := http.Get(baseURL + "/diediedie")
-	if resp.StatusCode != http.StatusOK || err != nil {
-		t.Errorf("got error response, Response: %v, Err: %s", resp, err)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /diediedie got %d, want %d", resp.StatusCode, http.StatusNotFound)
 	}
 
 	resp, err = http.Get(baseURL)
-	if resp.StatusCode != http.StatusOK || err != nil {
-		t.Errorf("server should still be alive, Response: %v, Err: %s", resp, err)
+	if err != nil {
+		t.Fatalf("server should still be alive, %s", err)
 	}
+	resp.Body.Close()
 }
 
 func TestServe(t *testing.T) {
@@ -238,7 +232,32 @@ func TestWebServer_Serve(t *testing.T) {
 	}
 }
 
+func TestWebServer_ShutdownLogsNoErrors(t *testing.T) {
+	ws, err := New(&Config{HTTP: HTTP{Addresses: []string{"127.0.0.1:0", "127.0.0.1:0"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Start(); err != nil {
+		t.Fatal(err)
+	}
+	core, logs := observer.New(zapcore.ErrorLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+	if err := ws.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range logs.All() {
+		t.Errorf("got an error logged during the shutdown, %s %v", entry.Message, entry.ContextMap())
+	}
+}
+
 func serveAsync(tb testing.TB, cfg *Config) (string, func()) {
+	_, baseURL, close := serveAsyncServer(tb, cfg)
+	return baseURL, close
+}
+
+// serveAsyncServer is serveAsync that also returns the server, e.g. to get the
+// HTTPS port.
+func serveAsyncServer(tb testing.TB, cfg *Config) (*webServerImpl, string, func()) {
 	ws, err := New(cfg)
 	if err != nil {
 		tb.Fatal(err)
@@ -268,14 +287,15 @@ func serveAsync(tb testing.TB, cfg *Config) (string, func()) {
 		tb.Error(err)
 	}
 
-	return baseURL, func() {
+	return wsi, baseURL, func() {
 		close()
 	}
 }
 
 func waitAvailable(url string) error {
 	for i := 0; i < 10; i++ {
-		if _, err := http.Get(url); err == nil {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
 			return nil
 		}
 		time.Sleep(time.Millisecond * 100)
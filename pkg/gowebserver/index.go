@@ -16,6 +16,7 @@ package gowebserver
 
 import (
 	"bytes"
+	"html/template"
 	"net/http"
 	"strings"
 	"time"
@@ -31,7 +32,8 @@ var (
 )
 
 type indexHTTPHandler struct {
-	page []byte
+	servePaths []string
+	tmpl       *template.Template
 }
 
 func newIndexHTTPHandler(servePaths []string, modern bool) (*indexHTTPHandler, error) {
@@ -39,10 +41,24 @@ func newIndexHTTPHandler(servePaths []string, modern bool) (*indexHTTPHandler, e
 	if modern {
 		templateHTML = customIndexHTML
 	}
-	w := &bytes.Buffer{}
+	tmpl, err := createTemplate(templateHTML)
+	if err != nil {
+		return nil, err
+	}
+	return &indexHTTPHandler{
+		servePaths: servePaths,
+		tmpl:       tmpl,
+	}, nil
+}
 
+func (h *indexHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The page is rendered per request as the mounts the user cannot read are
+	// not listed.
 	entries := []*DirEntry{}
-	for _, servePath := range servePaths {
+	for _, servePath := range h.servePaths {
+		if !isListed(r, servePath) {
+			continue
+		}
 		entries = append(entries, &DirEntry{
 			Name:      strings.Trim(servePath, "/"),
 			IsDir:     true,
@@ -60,19 +76,14 @@ func newIndexHTTPHandler(servePaths []string, modern bool) (*indexHTTPHandler, e
 		HasNonMediaEntry: true,
 	}
 
-	zap.S().With("params", params).Debug("newIndexHTTPHandler")
-	if err := executeTemplate(templateHTML, params, w); err != nil {
-		return nil, err
+	page := &bytes.Buffer{}
+	if err := h.tmpl.Execute(page, params); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-
-	return &indexHTTPHandler{
-		page: w.Bytes(),
-	}, nil
-}
-
-func (h *indexHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "text/html")
-	if _, err := w.Write(h.page); err != nil {
+	setSecureHeaders(w.Header())
+	if _, err := w.Write(page.Bytes()); err != nil {
 		zap.S().With("error", err).Warn("cannot write index response")
 	}
 }
@@ -0,0 +1,225 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// forwardedHeader is the standard RFC 7239 proxy header, other headers such
+// as X-Forwarded-For hold a plain list of addresses.
+const forwardedHeader = "Forwarded"
+
+// clientIPHeaders are the supported headers of the client IP address.
+var clientIPHeaders = []string{"X-Forwarded-For", "X-Real-IP", forwardedHeader}
+
+// parsePrefixes parses IP addresses and CIDR ranges, addresses match only
+// themselves.
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR '%s', %w", value, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address '%s', %w", value, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	return slices.ContainsFunc(prefixes, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// empty reports whether the filter lets every client through.
+func (f IPFilter) empty() bool {
+	return len(f.Allow) == 0 && len(f.Deny) == 0
+}
+
+func (f IPFilter) equal(other IPFilter) bool {
+	return slices.Equal(f.Allow, other.Allow) && slices.Equal(f.Deny, other.Deny)
+}
+
+// ipFilterHandler responds with 403 Forbidden to the clients that the filter
+// does not allow.
+type ipFilterHandler struct {
+	next     http.Handler
+	allow    []netip.Prefix
+	deny     []netip.Prefix
+	scope    string
+	rejected metric.Int64Counter
+}
+
+// newIPFilterHandler only lets the allowed clients through to next, next is
+// returned as is if the filter is empty. The scope, e.g. the mount's endpoint,
+// is recorded in the rejected_requests_total metric.
+func newIPFilterHandler(next http.Handler, conf IPFilter, scope string, mc *monitoringContext) (http.Handler, error) {
+	if conf.empty() {
+		return next, nil
+	}
+	allow, err := parsePrefixes(conf.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parsePrefixes(conf.Deny)
+	if err != nil {
+		return nil, err
+	}
+	rejected, err := mc.getMeterProvider().Meter("gowebserver").Int64Counter("rejected_requests_total", metric.WithDescription("Number of requests rejected for exceeding a limit."))
+	if err != nil {
+		return nil, err
+	}
+	return &ipFilterHandler{next: next, allow: allow, deny: deny, scope: scope, rejected: rejected}, nil
+}
+
+// allowed reports whether the client may make requests, denied addresses take
+// precedence and an empty allow list allows everyone else.
+func (h *ipFilterHandler) allowed(addr netip.Addr) bool {
+	if containsAddr(h.deny, addr) {
+		return false
+	}
+	return len(h.allow) == 0 || containsAddr(h.allow, addr)
+}
+
+func (h *ipFilterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if addr := requestClientIP(r); !h.allowed(addr) {
+		zap.S().With("client", addr, "scope", h.scope, "path", r.URL.Path).Debug("Rejecting request, client IP not allowed")
+		h.rejected.Add(r.Context(), 1, metric.WithAttributes(attribute.String("reason", "ip_denied"), attribute.String("scope", h.scope)))
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// clientIPKey is the context key of the resolved client IP address of a
+// request.
+type clientIPKey struct{}
+
+// requestClientIP returns the client IP address of the request, resolved
+// through the trusted proxies. It is invalid for Unix socket clients.
+func requestClientIP(r *http.Request) netip.Addr {
+	if addr, ok := r.Context().Value(clientIPKey{}).(netip.Addr); ok {
+		return addr
+	}
+	return remoteAddrIP(r.RemoteAddr)
+}
+
+// remoteAddrIP parses the IP address of an address with an optional port.
+func remoteAddrIP(remoteAddr string) netip.Addr {
+	host := strings.TrimSpace(remoteAddr)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// clientIPResolver finds the client address of requests that come through
+// trusted reverse proxies.
+type clientIPResolver struct {
+	trusted []netip.Prefix
+	header  string
+}
+
+func newClientIPResolver(conf ClientIP) (*clientIPResolver, error) {
+	trusted, err := parsePrefixes(conf.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	header := conf.Header
+	if header == "" {
+		header = "X-Forwarded-For"
+	}
+	return &clientIPResolver{trusted: trusted, header: http.CanonicalHeaderKey(header)}, nil
+}
+
+// resolve returns the client address of the request. The proxy header is only
+// read if the peer is a trusted proxy, and the rightmost address that is not
+// a trusted proxy is the client since the addresses to its left can be forged.
+func (c *clientIPResolver) resolve(r *http.Request) netip.Addr {
+	addr := remoteAddrIP(r.RemoteAddr)
+	if !addr.IsValid() || !containsAddr(c.trusted, addr) {
+		return addr
+	}
+	hops := []string{}
+	for _, value := range r.Header.Values(c.header) {
+		for _, hop := range strings.Split(value, ",") {
+			if c.header == forwardedHeader {
+				hop = forwardedFor(hop)
+			}
+			hops = append(hops, hop)
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := remoteAddrIP(strings.Trim(strings.TrimSpace(hops[i]), `"`))
+		if !hop.IsValid() {
+			break
+		}
+		addr = hop
+		if !containsAddr(c.trusted, addr) {
+			break
+		}
+	}
+	return addr
+}
+
+// forwardedFor returns the for parameter of a Forwarded header element.
+func forwardedFor(element string) string {
+	for _, pair := range strings.Split(element, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(name, "for") {
+			return value
+		}
+	}
+	return ""
+}
+
+// handler records the resolved client address of the requests in their
+// context, h is returned as is if there are no trusted proxies.
+func (c *clientIPResolver) handler(h http.Handler) http.Handler {
+	if len(c.trusted) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, c.resolve(r))))
+	})
+}
@@ -0,0 +1,191 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIPFilterHandler(t *testing.T) {
+	testCases := []struct {
+		name   string
+		filter IPFilter
+		remote string
+		want   int
+	}{
+		{name: "no rules", filter: IPFilter{Deny: []string{}}, remote: "192.0.2.1:1234", want: http.StatusOK},
+		{name: "allowed", filter: IPFilter{Allow: []string{"192.0.2.0/24"}}, remote: "192.0.2.1:1234", want: http.StatusOK},
+		{name: "not allowed", filter: IPFilter{Allow: []string{"10.0.0.0/8"}}, remote: "192.0.2.1:1234", want: http.StatusForbidden},
+		{name: "denied", filter: IPFilter{Deny: []string{"192.0.2.1"}}, remote: "192.0.2.1:1234", want: http.StatusForbidden},
+		{name: "deny wins", filter: IPFilter{Allow: []string{"192.0.2.0/24"}, Deny: []string{"192.0.2.1"}}, remote: "192.0.2.1:1234", want: http.StatusForbidden},
+		{name: "other denied", filter: IPFilter{Allow: []string{"192.0.2.0/24"}, Deny: []string{"192.0.2.1"}}, remote: "192.0.2.2:1234", want: http.StatusOK},
+		{name: "ipv6", filter: IPFilter{Allow: []string{"2001:db8::/32"}}, remote: "[2001:db8::1]:1234", want: http.StatusOK},
+		{name: "ipv4 mapped", filter: IPFilter{Allow: []string{"192.0.2.1"}}, remote: "[::ffff:192.0.2.1]:1234", want: http.StatusOK},
+		{name: "unix socket", filter: IPFilter{Allow: []string{"127.0.0.1"}}, remote: "@", want: http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := newIPFilterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), tc.filter, "test", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remote
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Errorf("got %d, want %d", w.Code, tc.want)
+			}
+		})
+	}
+
+	if _, err := newIPFilterHandler(http.NotFoundHandler(), IPFilter{Allow: []string{"10.0.0.0/33"}}, "test", nil); err == nil {
+		t.Error("want an error for an invalid CIDR")
+	}
+}
+
+func TestClientIPResolver(t *testing.T) {
+	testCases := []struct {
+		name    string
+		conf    ClientIP
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:    "untrusted peer",
+			conf:    ClientIP{TrustedProxies: []string{"10.0.0.1"}},
+			remote:  "192.0.2.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:    "192.0.2.1",
+		},
+		{
+			name:    "trusted proxy",
+			conf:    ClientIP{TrustedProxies: []string{"10.0.0.1"}},
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "forged hops",
+			conf:    ClientIP{TrustedProxies: []string{"10.0.0.0/8"}},
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.9, 198.51.100.7", "10.0.0.2"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "only proxies",
+			conf:    ClientIP{TrustedProxies: []string{"10.0.0.0/8"}},
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:    "10.0.0.3",
+		},
+		{
+			name:    "invalid hop",
+			conf:    ClientIP{TrustedProxies: []string{"10.0.0.1"}},
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Forwarded-For": {"unknown"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "x-real-ip",
+			conf:    ClientIP{TrustedProxies: []string{"10.0.0.1"}, Header: "X-Real-IP"},
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"X-Real-Ip": {"198.51.100.7"}, "X-Forwarded-For": {"203.0.113.9"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "forwarded",
+			conf:    ClientIP{TrustedProxies: []string{"10.0.0.1"}, Header: "forwarded"},
+			remote:  "10.0.0.1:1234",
+			headers: map[string][]string{"Forwarded": {`for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"`}},
+			want:    "2001:db8:cafe::17",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := newClientIPResolver(tc.conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remote
+			for k, v := range tc.headers {
+				req.Header[k] = v
+			}
+			var got netip.Addr
+			c.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = requestClientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+			if got.String() != tc.want {
+				t.Errorf("got client IP %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWebServer_IPFilter(t *testing.T) {
+	dir := mustTempDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	baseURL, close := serveAsync(t, &Config{
+		Serve: []Serve{
+			{Source: dir, Endpoint: "/"},
+			{Source: dir, Endpoint: "/office", IPFilter: IPFilter{Allow: []string{"198.51.100.0/24"}}},
+		},
+		Upload:     Serve{Source: filepath.Join(dir, "uploads"), Endpoint: "/upload", IPFilter: IPFilter{Deny: []string{"127.0.0.1", "::1"}}},
+		Monitoring: Monitoring{DebugEndpoint: "/debug", DebugIPFilter: IPFilter{Allow: []string{"10.0.0.0/8"}}},
+		ClientIP:   ClientIP{TrustedProxies: []string{"127.0.0.1", "::1"}},
+	})
+	defer close()
+
+	get := func(path string, forwardedFor string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, baseURL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	got := []int{
+		get("/a.txt", ""),
+		get("/office/a.txt", ""),
+		get("/office/a.txt", "198.51.100.7"),
+		get("/office/a.txt", "198.51.100.7, 203.0.113.9"),
+		get("/upload", ""),
+		get("/debug/pprof/", ""),
+	}
+	want := []int{http.StatusOK, http.StatusForbidden, http.StatusOK, http.StatusForbidden, http.StatusForbidden, http.StatusForbidden}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("status codes mismatch (-want +got):\n%s", diff)
+	}
+}
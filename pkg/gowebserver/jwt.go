@@ -0,0 +1,460 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// jwksRefreshInterval is how often a key set URL is fetched again so that
+	// rotated keys are picked up.
+	jwksRefreshInterval = time.Hour
+	// jwksMinRefreshInterval limits how often tokens signed by an unknown key
+	// fetch the key set URL.
+	jwksMinRefreshInterval = time.Minute
+	// identityProviderTimeout bounds the fetches from an identity provider,
+	// which are shared by the requests that wait for them and so do not use
+	// their contexts.
+	identityProviderTimeout = 10 * time.Second
+	// jwtLeeway is the clock skew allowed when checking the times of a token.
+	jwtLeeway  = time.Minute
+	maxJWKSize = 1 << 20
+)
+
+// jsonWebKey is a public key of a JSON Web Key Set, RFC 7517.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC and OKP keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// verificationKey is a parsed signing key of a key set.
+type verificationKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(name string, value string) ([]byte, error) {
+		b, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("key '%s' has an invalid '%s'", k.Kid, name)
+		}
+		return b, nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key '%s' has an invalid 'e'", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("key '%s' has unsupported curve '%s'", k.Kid, k.Crv)
+		}
+		x, err := decode("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) > size || len(y) > size {
+			return nil, fmt.Errorf("key '%s' has coordinates that do not fit curve '%s'", k.Kid, k.Crv)
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		copy(point[1+size-len(x):1+size], x)
+		copy(point[1+2*size-len(y):], y)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("key '%s' has unsupported curve '%s'", k.Kid, k.Crv)
+		}
+		x, err := decode("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key '%s' has an invalid 'x'", k.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("key '%s' has unsupported type '%s'", k.Kid, k.Kty)
+}
+
+// parseJWKS returns the signing keys of a JSON Web Key Set, encryption keys and
+// keys of unsupported types are skipped.
+func parseJWKS(data []byte) ([]verificationKey, error) {
+	set := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("cannot parse JSON Web Key Set, %w", err)
+	}
+	keys := []verificationKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, verificationKey{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JSON Web Key Set has no supported signing keys")
+	}
+	return keys, nil
+}
+
+// keySet is a JSON Web Key Set read from a file or fetched from a URL. URLs are
+// fetched on first use and again periodically.
+type keySet struct {
+	source string
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.Mutex
+	keys    []verificationKey
+	fetched time.Time
+	// fetchErr is the error of the last fetch, nil if it succeeded.
+	fetchErr error
+	// fetching is closed once the fetch in progress completes, nil if there
+	// is none.
+	fetching chan struct{}
+}
+
+// newKeySet reads the key set right away if the source is a file.
+func newKeySet(source string, client *http.Client) (*keySet, error) {
+	s := &keySet{source: source, client: client, now: time.Now}
+	if !isSupportedHTTP(source) {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read JSON Web Key Set '%s', %w", source, err)
+		}
+		if s.keys, err = parseJWKS(data); err != nil {
+			return nil, fmt.Errorf("cannot load JSON Web Key Set '%s', %w", source, err)
+		}
+	}
+	return s, nil
+}
+
+// lookup returns the keys that may have signed a token with the key ID and
+// algorithm. The key set is fetched in the background, lookups only wait for
+// it if the current keys do not have the key ID and stop waiting when ctx is
+// done.
+func (s *keySet) lookup(ctx context.Context, kid string, alg string) ([]verificationKey, error) {
+	if isSupportedHTTP(s.source) {
+		s.mu.Lock()
+		known := slices.ContainsFunc(s.keys, func(k verificationKey) bool { return kid == "" || k.kid == kid })
+		age := s.now().Sub(s.fetched)
+		stale := s.fetched.IsZero() || age > jwksRefreshInterval || (age > jwksMinRefreshInterval && !known)
+		done := s.fetching
+		if stale && done == nil {
+			done = make(chan struct{})
+			s.fetching = done
+			s.fetched = s.now()
+			go s.fetch(done)
+		}
+		s.mu.Unlock()
+		if done != nil && !known {
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	s.mu.Lock()
+	keys, fetchErr := s.keys, s.fetchErr
+	s.mu.Unlock()
+	matches := []verificationKey{}
+	for _, k := range keys {
+		if (kid == "" || k.kid == kid) && (k.alg == "" || k.alg == alg) {
+			matches = append(matches, k)
+		}
+	}
+	if len(matches) == 0 {
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return nil, fmt.Errorf("no key '%s' for algorithm %s", kid, alg)
+	}
+	return matches, nil
+}
+
+// fetch downloads the key set and closes done once the keys are updated.
+func (s *keySet) fetch(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), identityProviderTimeout)
+	defer cancel()
+	data, err := getJSON(ctx, s.client, s.source)
+	var keys []verificationKey
+	if err != nil {
+		err = fmt.Errorf("cannot fetch JSON Web Key Set, %w", err)
+	} else if keys, err = parseJWKS(data); err != nil {
+		err = fmt.Errorf("cannot load JSON Web Key Set '%s', %w", s.source, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		s.keys = keys
+	} else {
+		zap.S().With("error", err, "source", s.source).Warn("cannot refresh JSON Web Key Set")
+	}
+	s.fetchErr = err
+	s.fetching = nil
+	close(done)
+}
+
+// getJSON returns the body of a successful GET request.
+func getJSON(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSize))
+}
+
+// jwtHashes are the hashes of the supported signature algorithms.
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+	"EdDSA": 0,
+}
+
+// verifyJWT checks the signature of the compact serialized token against the
+// key set and returns its claims. The claims themselves are not checked.
+func verifyJWT(ctx context.Context, token string, keys *keySet) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token header, %w", err)
+	}
+	header := struct {
+		Alg  string   `json:"alg"`
+		Kid  string   `json:"kid"`
+		Crit []string `json:"crit"`
+	}{}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, fmt.Errorf("malformed token header, %w", err)
+	}
+	if _, ok := jwtHashes[header.Alg]; !ok {
+		return nil, fmt.Errorf("unsupported token algorithm '%s'", header.Alg)
+	}
+	if len(header.Crit) > 0 {
+		return nil, fmt.Errorf("unsupported critical token header %v", header.Crit)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature, %w", err)
+	}
+
+	candidates, err := keys.lookup(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	if !slices.ContainsFunc(candidates, func(k verificationKey) bool { return verifySignature(header.Alg, k.key, signed, signature) }) {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token payload, %w", err)
+	}
+	claims := map[string]any{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed token payload, %w", err)
+	}
+	return claims, nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed []byte, signature []byte) bool {
+	hash := jwtHashes[alg]
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "RS") {
+			return rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil
+		}
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(k, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	case ed25519.PublicKey:
+		return alg == "EdDSA" && ed25519.Verify(k, signed, signature)
+	}
+	return false
+}
+
+// checkClaims checks the times, issuer and audience of a token. Empty issuer
+// and audience are not checked.
+func checkClaims(claims map[string]any, issuer string, audience string, now time.Time) error {
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("token is not valid yet")
+	}
+	if iss, _ := claims["iss"].(string); issuer != "" && iss != issuer {
+		return fmt.Errorf("token issuer '%s' is not '%s'", iss, issuer)
+	}
+	if audience != "" && !slices.Contains(audiences(claims["aud"]), audience) {
+		return fmt.Errorf("token audience is not '%s'", audience)
+	}
+	return nil
+}
+
+// claimValue returns the claim at a dotted path, e.g. realm_access.roles.
+func claimValue(claims map[string]any, path string) any {
+	var value any = claims
+	for _, name := range strings.Split(path, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[name]
+	}
+	return value
+}
+
+// claimStrings returns the strings of a claim that is a string or a list.
+// Strings are split on spaces as in the scope claim.
+func claimStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		values := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// audiences returns the values of the aud claim, a single string or a list of
+// strings as in RFC 7519.
+func audiences(value any) []string {
+	if s, ok := value.(string); ok {
+		return []string{s}
+	}
+	return claimStrings(value)
+}
+
+// identity returns the user named by the claims and the roles they map to.
+func (c Claims) identity(claims map[string]any, source string) (*identity, error) {
+	usernameClaim := c.Username
+	if usernameClaim == "" {
+		usernameClaim = "sub"
+	}
+	name, _ := claimValue(claims, usernameClaim).(string)
+	if name == "" {
+		return nil, fmt.Errorf("token has no '%s' claim", usernameClaim)
+	}
+	id := &identity{name: name, source: source}
+	if c.Roles == "" {
+		return id, nil
+	}
+	for _, value := range claimStrings(claimValue(claims, c.Roles)) {
+		if len(c.RoleMapping) == 0 {
+			id.roles = append(id.roles, value)
+			continue
+		}
+		id.roles = append(id.roles, c.RoleMapping[value]...)
+	}
+	slices.Sort(id.roles)
+	id.roles = slices.Compact(id.roles)
+	return id, nil
+}
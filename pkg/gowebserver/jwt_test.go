@@ -0,0 +1,479 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testSigner signs test tokens with a key of a JSON Web Key Set.
+type testSigner struct {
+	kid string
+	alg string
+	key crypto.Signer
+}
+
+func mustNewTestSigner(tb testing.TB, kid string, alg string) testSigner {
+	tb.Helper()
+	var key crypto.Signer
+	var err error
+	switch alg[:2] {
+	case "RS", "PS":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES":
+		curves := map[string]elliptic.Curve{"ES256": elliptic.P256(), "ES384": elliptic.P384(), "ES512": elliptic.P521()}
+		key, err = ecdsa.GenerateKey(curves[alg], rand.Reader)
+	default:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return testSigner{kid: kid, alg: alg, key: key}
+}
+
+// jwk returns the public key as a JSON Web Key.
+func (s testSigner) jwk() jsonWebKey {
+	b64 := base64.RawURLEncoding.EncodeToString
+	k := jsonWebKey{Kid: s.kid, Alg: s.alg, Use: "sig"}
+	switch pub := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		k.Kty, k.N, k.E = "RSA", b64(pub.N.Bytes()), b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		point, _ := pub.Bytes()
+		size := (len(point) - 1) / 2
+		k.Kty, k.Crv, k.X, k.Y = "EC", pub.Curve.Params().Name, b64(point[1:1+size]), b64(point[1+size:])
+	case ed25519.PublicKey:
+		k.Kty, k.Crv, k.X = "OKP", "Ed25519", b64(pub)
+	}
+	return k
+}
+
+func (s testSigner) sign(tb testing.TB, claims map[string]any) string {
+	tb.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": s.alg, "kid": s.kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := jwtHashes[s.alg]
+	digest := []byte(signed)
+	var opts crypto.SignerOpts = crypto.Hash(0)
+	if hash != 0 {
+		h := hash.New()
+		h.Write(digest)
+		digest = h.Sum(nil)
+		opts = hash
+	}
+	if s.alg[:2] == "PS" {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+	}
+	var signature []byte
+	var err error
+	if k, ok := s.key.(*ecdsa.PrivateKey); ok {
+		// JWS uses the raw r || s encoding rather than ASN.1.
+		r, sig, signErr := ecdsa.Sign(rand.Reader, k, digest)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature, err = make([]byte, 2*size), signErr
+		r.FillBytes(signature[:size])
+		sig.FillBytes(signature[size:])
+	} else {
+		signature, err = s.key.Sign(rand.Reader, digest, opts)
+	}
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func mustWriteJWKS(tb testing.TB, signers ...testSigner) string {
+	tb.Helper()
+	keys := []jsonWebKey{}
+	for _, s := range signers {
+		keys = append(keys, s.jwk())
+	}
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	name := filepath.Join(mustTempDir(tb), "jwks.json")
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		tb.Fatal(err)
+	}
+	return name
+}
+
+func mustNewKeySet(tb testing.TB, signers ...testSigner) *keySet {
+	tb.Helper()
+	keys, err := newKeySet(mustWriteJWKS(tb, signers...), nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return keys
+}
+
+func TestParseJWKS(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    string
+		wantIDs []string
+		wantErr bool
+	}{
+		{
+			name:    "skips encryption and unsupported keys",
+			data:    `{"keys":[{"kty":"OKP","crv":"Ed25519","kid":"a","x":"` + base64.RawURLEncoding.EncodeToString(make([]byte, 32)) + `"},{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"},{"kty":"oct","kid":"hmac","k":"c2VjcmV0"}]}`,
+			wantIDs: []string{"a"},
+		},
+		{
+			name:    "no supported keys",
+			data:    `{"keys":[{"kty":"EC","crv":"P-192","kid":"a","x":"AQ","y":"AQ"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			data:    `keys`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keys, err := parseJWKS([]byte(tc.data))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseJWKS() error = %v, wantErr %t", err, tc.wantErr)
+			}
+			gotIDs := []string{}
+			for _, k := range keys {
+				gotIDs = append(gotIDs, k.kid)
+			}
+			if !tc.wantErr {
+				if diff := cmp.Diff(tc.wantIDs, gotIDs); diff != "" {
+					t.Errorf("key IDs mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyJWT(t *testing.T) {
+	claims := map[string]any{"sub": "frank"}
+	for _, alg := range []string{"RS256", "PS384", "ES256", "ES384", "ES512", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			signer := mustNewTestSigner(t, "k1", alg)
+			keys := mustNewKeySet(t, signer)
+			got, err := verifyJWT(context.Background(), signer.sign(t, claims), keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(claims, got); diff != "" {
+				t.Errorf("claims mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	signer := mustNewTestSigner(t, "k1", "ES256")
+	other := mustNewTestSigner(t, "k2", "ES256")
+	keys := mustNewKeySet(t, signer)
+	token := signer.sign(t, claims)
+	header, _, _ := strings.Cut(token, ".")
+	_, signature, _ := strings.Cut(token[len(header)+1:], ".")
+	encode := func(v string) string { return base64.RawURLEncoding.EncodeToString([]byte(v)) }
+
+	invalid := map[string]string{
+		"unknown key":  other.sign(t, claims),
+		"other key":    testSigner{kid: "k1", alg: "ES256", key: other.key}.sign(t, claims),
+		"tampered":     header + "." + encode(`{"sub":"admin"}`) + "." + signature,
+		"alg none":     encode(`{"alg":"none","kid":"k1"}`) + "." + encode(`{"sub":"frank"}`) + ".",
+		"alg HS256":    encode(`{"alg":"HS256","kid":"k1"}`) + "." + encode(`{"sub":"frank"}`) + "." + signature,
+		"critical":     encode(`{"alg":"ES256","kid":"k1","crit":["exp"]}`) + "." + encode(`{"sub":"frank"}`) + "." + signature,
+		"two parts":    header + "." + signature,
+		"not base64":   "!." + encode(`{}`) + "." + signature,
+		"wrong length": header + "." + encode(`{"sub":"frank"}`) + "." + signature[:10],
+	}
+	for name, token := range invalid {
+		if _, err := verifyJWT(context.Background(), token, keys); err == nil {
+			t.Errorf("%s: want the token rejected", name)
+		}
+	}
+}
+
+func TestCheckClaims(t *testing.T) {
+	now := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	exp := float64(now.Add(time.Hour).Unix())
+	testCases := []struct {
+		name    string
+		claims  map[string]any
+		wantErr bool
+	}{
+		{name: "valid", claims: map[string]any{"exp": exp, "iss": "https://idp", "aud": "files"}},
+		{name: "audience list", claims: map[string]any{"exp": exp, "iss": "https://idp", "aud": []any{"other", "files"}}},
+		{name: "within leeway", claims: map[string]any{"exp": float64(now.Add(-30 * time.Second).Unix()), "iss": "https://idp", "aud": "files"}},
+		{name: "expired", claims: map[string]any{"exp": float64(now.Add(-time.Hour).Unix()), "iss": "https://idp", "aud": "files"}, wantErr: true},
+		{name: "no expiry", claims: map[string]any{"iss": "https://idp", "aud": "files"}, wantErr: true},
+		{name: "not yet valid", claims: map[string]any{"exp": exp, "nbf": exp, "iss": "https://idp", "aud": "files"}, wantErr: true},
+		{name: "wrong issuer", claims: map[string]any{"exp": exp, "iss": "https://evil", "aud": "files"}, wantErr: true},
+		{name: "wrong audience", claims: map[string]any{"exp": exp, "iss": "https://idp", "aud": []any{"other"}}, wantErr: true},
+		{name: "audience with spaces", claims: map[string]any{"exp": exp, "iss": "https://idp", "aud": "evil files"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := checkClaims(tc.claims, "https://idp", "files", now); (err != nil) != tc.wantErr {
+				t.Errorf("checkClaims() error = %v, wantErr %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestClaims_Identity(t *testing.T) {
+	claims := map[string]any{
+		"sub":                "1234",
+		"preferred_username": "frank",
+		"groups":             []any{"engineering", "everyone", 7},
+		"scope":              "files:read files:write",
+		"realm_access":       map[string]any{"roles": []any{"staff"}},
+	}
+	testCases := []struct {
+		name    string
+		conf    Claims
+		want    *identity
+		wantErr bool
+	}{
+		{name: "default username", conf: Claims{}, want: &identity{name: "1234", source: identitySourceJWT}},
+		{name: "roles from list", conf: Claims{Username: "preferred_username", Roles: "groups"}, want: &identity{name: "frank", roles: []string{"engineering", "everyone"}, source: identitySourceJWT}},
+		{name: "roles from space separated string", conf: Claims{Roles: "scope"}, want: &identity{name: "1234", roles: []string{"files:read", "files:write"}, source: identitySourceJWT}},
+		{name: "nested claim", conf: Claims{Roles: "realm_access.roles"}, want: &identity{name: "1234", roles: []string{"staff"}, source: identitySourceJWT}},
+		{
+			name: "role mapping",
+			conf: Claims{Roles: "groups", RoleMapping: map[string][]string{"engineering": {"staff", "uploaders"}, "everyone": {"staff"}}},
+			want: &identity{name: "1234", roles: []string{"staff", "uploaders"}, source: identitySourceJWT},
+		},
+		{name: "missing username", conf: Claims{Username: "email"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.conf.identity(claims, identitySourceJWT)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("identity() error = %v, wantErr %t", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(identity{})); diff != "" {
+				t.Errorf("identity mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestKeySet_URL(t *testing.T) {
+	signer := mustNewTestSigner(t, "k1", "ES256")
+	rotated := mustNewTestSigner(t, "k2", "ES256")
+	current := atomic.Pointer[[]testSigner]{}
+	current.Store(&[]testSigner{signer})
+	fetches := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		keys := []jsonWebKey{}
+		for _, s := range *current.Load() {
+			keys = append(keys, s.jwk())
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	defer srv.Close()
+
+	keys, err := newKeySet(srv.URL, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	keys.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	if fetches.Load() != 0 {
+		t.Error("want the key set fetched on first use")
+	}
+	if _, err := verifyJWT(ctx, signer.sign(t, nil), keys); err != nil {
+		t.Fatal(err)
+	}
+	// Unknown keys only fetch the key set again once a minute.
+	current.Store(&[]testSigner{signer, rotated})
+	if _, err := verifyJWT(ctx, rotated.sign(t, nil), keys); err == nil {
+		t.Error("want the rotated key unknown right after fetching")
+	}
+	clock = clock.Add(2 * jwksMinRefreshInterval)
+	if _, err := verifyJWT(ctx, rotated.sign(t, nil), keys); err != nil {
+		t.Errorf("want the rotated key fetched, got %v", err)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("want 2 fetches, got %d", got)
+	}
+}
+
+func TestKeySet_SlowFetch(t *testing.T) {
+	signer := mustNewTestSigner(t, "k1", "ES256")
+	release := make(chan struct{})
+	fetches := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": []jsonWebKey{signer.jwk()}})
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	keys, err := newKeySet(srv.URL, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	keys.now = func() time.Time { return clock }
+	ctx := context.Background()
+	if _, err := verifyJWT(ctx, signer.sign(t, nil), keys); err != nil {
+		t.Fatal(err)
+	}
+
+	// The refresh blocks on the slow server while other lookups keep using
+	// the current keys.
+	clock = clock.Add(2 * jwksRefreshInterval)
+	go verifyJWT(ctx, signer.sign(t, nil), keys)
+	for fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	verified := make(chan error)
+	go func() {
+		_, err := verifyJWT(ctx, signer.sign(t, nil), keys)
+		verified <- err
+	}()
+	select {
+	case err := <-verified:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the lookup to use the current keys during a refresh")
+	}
+}
+
+func TestKeySet_CancelledLookup(t *testing.T) {
+	signer := mustNewTestSigner(t, "k1", "ES256")
+	release := make(chan struct{})
+	fetches := atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		json.NewEncoder(w).Encode(map[string]any{"keys": []jsonWebKey{signer.jwk()}})
+	}))
+	defer srv.Close()
+
+	keys, err := newKeySet(srv.URL, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The lookup that starts the fetch gives up, the fetch goes on and its
+	// keys are used by the lookups after it.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := verifyJWT(ctx, signer.sign(t, nil), keys); !errors.Is(err, context.Canceled) {
+		t.Errorf("verifyJWT() with a cancelled context got %v, want %v", err, context.Canceled)
+	}
+	close(release)
+	if _, err := verifyJWT(context.Background(), signer.sign(t, nil), keys); err != nil {
+		t.Fatal(err)
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("got %d fetches, want 1", got)
+	}
+}
+
+func TestAuthenticator_Bearer(t *testing.T) {
+	signer := mustNewTestSigner(t, "k1", "RS256")
+	a, err := newAuthenticator(Auth{
+		JWT:    JWT{JWKS: mustWriteJWKS(t, signer), Issuer: "https://idp", Audience: "files"},
+		Claims: Claims{Username: "sub", Roles: "groups", RoleMapping: map[string][]string{"engineering": {"staff"}}},
+	}, ClientAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := a.handler(a.accessHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(requestUser(r)))
+	}), map[string][]string{"staff": {permissionRead}}, methodPermission))
+
+	exp := time.Now().Add(time.Hour).Unix()
+	testCases := []struct {
+		name           string
+		authorization  string
+		wantStatus     int
+		wantBody       string
+		wantChallenges []string
+	}{
+		{
+			name:          "mapped role",
+			authorization: "Bearer " + signer.sign(t, map[string]any{"sub": "frank", "iss": "https://idp", "aud": "files", "exp": exp, "groups": []string{"engineering"}}),
+			wantStatus:    http.StatusOK,
+			wantBody:      "frank",
+		},
+		{
+			name:          "no role",
+			authorization: "Bearer " + signer.sign(t, map[string]any{"sub": "bob", "iss": "https://idp", "aud": "files", "exp": exp, "groups": []string{"sales"}}),
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:           "wrong audience",
+			authorization:  "Bearer " + signer.sign(t, map[string]any{"sub": "frank", "iss": "https://idp", "aud": "other", "exp": exp, "groups": []string{"engineering"}}),
+			wantStatus:     http.StatusUnauthorized,
+			wantChallenges: []string{`Bearer realm="gowebserver", error="invalid_token"`},
+		},
+		{
+			name:           "anonymous",
+			wantStatus:     http.StatusUnauthorized,
+			wantChallenges: []string{`Bearer realm="gowebserver"`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/private/a.txt", nil)
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tc.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tc.wantStatus)
+			}
+			if tc.wantBody != "" && w.Body.String() != tc.wantBody {
+				t.Errorf("got body %q, want %q", w.Body.String(), tc.wantBody)
+			}
+			if diff := cmp.Diff(tc.wantChallenges, w.Header().Values("WWW-Authenticate")); diff != "" {
+				t.Errorf("WWW-Authenticate mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultMultipartMemoryBytes is how much of an upload is held in memory when
+	// Limits.MultipartMemoryBytes is not set.
+	defaultMultipartMemoryBytes = 32 << 20
+)
+
+// applyTo sets the timeouts and header limits on the server.
+func (l Limits) applyTo(srv *http.Server) {
+	srv.ReadHeaderTimeout = l.ReadHeaderTimeout
+	srv.ReadTimeout = l.ReadTimeout
+	srv.WriteTimeout = l.WriteTimeout
+	srv.IdleTimeout = l.IdleTimeout
+	srv.MaxHeaderBytes = l.MaxHeaderBytes
+}
+
+// serverSettingsEqual reports whether the settings that are fixed once the
+// servers are started are the same.
+func (l Limits) serverSettingsEqual(other Limits) bool {
+	return l.ReadHeaderTimeout == other.ReadHeaderTimeout &&
+		l.ReadTimeout == other.ReadTimeout &&
+		l.WriteTimeout == other.WriteTimeout &&
+		l.IdleTimeout == other.IdleTimeout &&
+		l.MaxHeaderBytes == other.MaxHeaderBytes &&
+		l.MaxConnections == other.MaxConnections
+}
+
+// maxBodyBytesFor returns the request body limit for the URL path, 0 if there
+// is no limit.
+func (l Limits) maxBodyBytesFor(urlPath string) int64 {
+	limit := l.MaxBodyBytes
+	longest := -1
+	for endpoint, endpointLimit := range l.EndpointMaxBodyBytes {
+		if isUnderEndpoint(urlPath, endpoint) && len(endpoint) > longest {
+			limit = endpointLimit
+			longest = len(endpoint)
+		}
+	}
+	return limit
+}
+
+func (l Limits) multipartMemoryBytes() int64 {
+	if l.MultipartMemoryBytes <= 0 {
+		return defaultMultipartMemoryBytes
+	}
+	return l.MultipartMemoryBytes
+}
+
+// isUnderEndpoint reports whether the URL path is the endpoint or below it.
+func isUnderEndpoint(urlPath string, endpoint string) bool {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	rest, ok := strings.CutPrefix(urlPath, endpoint)
+	return ok && (rest == "" || rest[0] == '/')
+}
+
+// bodyLimitHandler rejects requests whose body is larger than the limit of
+// their endpoint with 413 Request Entity Too Large.
+type bodyLimitHandler struct {
+	next     http.Handler
+	limits   Limits
+	rejected metric.Int64Counter
+}
+
+func newBodyLimitHandler(next http.Handler, limits Limits, mc *monitoringContext) (http.Handler, error) {
+	if limits.MaxBodyBytes <= 0 && len(limits.EndpointMaxBodyBytes) == 0 {
+		return next, nil
+	}
+	rejected, err := mc.getMeterProvider().Meter("gowebserver").Int64Counter("rejected_requests_total", metric.WithDescription("Number of requests rejected for exceeding a limit."))
+	if err != nil {
+		return nil, err
+	}
+	return &bodyLimitHandler{
+		next:     next,
+		limits:   limits,
+		rejected: rejected,
+	}, nil
+}
+
+func (h *bodyLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	limit := h.limits.maxBodyBytesFor(r.URL.Path)
+	if limit > 0 {
+		if r.ContentLength > limit {
+			h.rejected.Add(r.Context(), 1, metric.WithAttributes(attribute.String("reason", "body_too_large")))
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// limitListener closes the connections accepted over the maximum number of
+// concurrent connections instead of leaving them queued in the backlog.
+type limitListener struct {
+	net.Listener
+	sem  chan struct{}
+	name string
+	// monitoring returns the current monitoring context, which a reload can
+	// replace while the listener keeps accepting.
+	monitoring func() *monitoringContext
+}
+
+func newLimitListener(lis net.Listener, maxConnections int, name string, monitoring func() *monitoringContext) net.Listener {
+	if maxConnections <= 0 {
+		return lis
+	}
+	return &limitListener{
+		Listener:   lis,
+		sem:        make(chan struct{}, maxConnections),
+		name:       name,
+		monitoring: monitoring,
+	}
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case l.sem <- struct{}{}:
+			return &limitConn{Conn: conn, release: func() { <-l.sem }}, nil
+		default:
+			zap.S().With("remote", conn.RemoteAddr(), "listener", l.name).Debug("Rejecting connection, maximum concurrent connections reached")
+			if mc := l.monitoring(); mc != nil && mc.rejectedConnections != nil {
+				mc.rejectedConnections.Add(context.Background(), 1, metric.WithAttributes(attribute.String("reason", "max_connections"), attribute.String("listener", l.name)))
+			}
+			conn.Close()
+		}
+	}
+}
+
+// limitConn releases its slot of the limitListener when closed. Listeners
+// without a limit do not wrap their connections.
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+// ReadFrom lets the server copy files with the ReadFrom of the underlying
+// connection, which uses sendfile for a *net.TCPConn.
+func (c *limitConn) ReadFrom(r io.Reader) (int64, error) {
+	if rf, ok := c.Conn.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(struct{ io.Writer }{c.Conn}, r)
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
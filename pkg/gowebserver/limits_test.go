@@ -0,0 +1,181 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitsMaxBodyBytesFor(t *testing.T) {
+	limits := Limits{
+		MaxBodyBytes: 100,
+		EndpointMaxBodyBytes: map[string]int64{
+			"/upload.asp": 1000,
+			"/api/":       10,
+			"/api/bulk":   0,
+		},
+	}
+
+	testCases := []struct {
+		path string
+		want int64
+	}{
+		{path: "/", want: 100},
+		{path: "/upload.asp", want: 1000},
+		{path: "/upload.aspx", want: 100},
+		{path: "/api", want: 10},
+		{path: "/api/items", want: 10},
+		{path: "/api/bulk/items", want: 0},
+	}
+	for _, tc := range testCases {
+		if got := limits.maxBodyBytesFor(tc.path); got != tc.want {
+			t.Errorf("maxBodyBytesFor(%q) got %d, want %d", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestBodyLimitHandler(t *testing.T) {
+	h, err := newBodyLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}), Limits{MaxBodyBytes: 4}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name          string
+		body          string
+		contentLength int64
+		want          int
+	}{
+		{name: "within limit", body: "1234", contentLength: 4, want: http.StatusOK},
+		{name: "content length over limit", body: "12345", contentLength: 5, want: http.StatusRequestEntityTooLarge},
+		{name: "chunked over limit", body: "12345", contentLength: -1, want: http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+		req.ContentLength = tc.contentLength
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: got status %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestLimitListener(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limited := newLimitListener(lis, 1, "test", func() *monitoringContext { return nil })
+	defer limited.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := limited.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	first, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	firstServer := <-accepted
+
+	second, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("want the connection over the limit to be closed, got %v", err)
+	}
+
+	// Closing the first connection frees up its slot.
+	firstServer.Close()
+	third, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Close()
+	select {
+	case conn := <-accepted:
+		conn.Close()
+	case <-time.After(5 * time.Second):
+		t.Error("connection was not accepted after a slot was freed")
+	}
+}
+
+func TestLimitConn_ReadFrom(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	client, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	server, err := lis.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeServer, pipeClient := net.Pipe()
+	defer pipeClient.Close()
+
+	testCases := []struct {
+		name   string
+		server net.Conn
+		client net.Conn
+	}{
+		{name: "tcp", server: server, client: client},
+		{name: "no ReadFrom", server: pipeServer, client: pipeClient},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := &limitConn{Conn: tc.server, release: func() {}}
+			go func() {
+				conn.ReadFrom(strings.NewReader("hello"))
+				conn.Close()
+			}()
+			got, err := io.ReadAll(tc.client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "hello" {
+				t.Errorf("got %q, want %q", got, "hello")
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	unixAddressPrefix    = "unix://"
+	systemdAddressPrefix = "systemd:"
+	// systemdListenFDsStart is the first file descriptor passed by systemd
+	// socket activation, SD_LISTEN_FDS_START.
+	systemdListenFDsStart = 3
+)
+
+// listenSpec is a single address to listen on.
+type listenSpec struct {
+	// address is host:port, unix:///path/to.sock or systemd:name.
+	address string
+	// socketMode is the file mode of Unix domain sockets, 0 keeps the default.
+	socketMode os.FileMode
+}
+
+// newListenSpecs returns the listen addresses of a protocol. Without addresses
+// all interfaces are used. Addresses without a port use the port.
+func newListenSpecs(port int, addresses []string, unixSocketMode string) ([]listenSpec, error) {
+	mode, err := parseUnixSocketMode(unixSocketMode)
+	if err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return []listenSpec{{address: toAddr(port)}}, nil
+	}
+
+	specs := []listenSpec{}
+	for _, address := range addresses {
+		network, addr, err := parseListenAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		if network == "tcp" {
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				address = net.JoinHostPort(strings.Trim(addr, "[]"), strconv.Itoa(port))
+			}
+		}
+		specs = append(specs, listenSpec{address: address, socketMode: mode})
+	}
+	return specs, nil
+}
+
+// parseListenAddress returns the network and the address to listen on.
+func parseListenAddress(address string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(address, unixAddressPrefix):
+		path := strings.TrimPrefix(address, unixAddressPrefix)
+		if path == "" {
+			return "", "", fmt.Errorf("missing socket path in '%s'", address)
+		}
+		return "unix", path, nil
+	case strings.HasPrefix(address, systemdAddressPrefix):
+		name := strings.TrimPrefix(address, systemdAddressPrefix)
+		if name == "" {
+			return "", "", fmt.Errorf("missing socket name or index in '%s'", address)
+		}
+		return "systemd", name, nil
+	case strings.Contains(address, "://"):
+		return "", "", fmt.Errorf("unsupported listen address '%s'", address)
+	}
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return "", "", fmt.Errorf("invalid port in listen address '%s'", address)
+		}
+		if strings.Contains(host, "/") {
+			return "", "", fmt.Errorf("invalid host in listen address '%s'", address)
+		}
+	}
+	return "tcp", address, nil
+}
+
+func parseUnixSocketMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m > 0777 {
+		return 0, fmt.Errorf("invalid Unix socket mode '%s', want octal permissions such as 0660", mode)
+	}
+	return os.FileMode(m), nil
+}
+
+// listen opens the listener of the spec.
+func (s listenSpec) listen() (net.Listener, error) {
+	network, addr, err := parseListenAddress(s.address)
+	if err != nil {
+		return nil, err
+	}
+	switch network {
+	case "unix":
+		return listenUnix(addr, s.socketMode)
+	case "systemd":
+		return systemdListener(addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	// A socket left behind by an unclean exit prevents listening again.
+	if info, err := os.Lstat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("cannot remove stale socket '%s', %w", path, err)
+		}
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			lis.Close()
+			return nil, fmt.Errorf("cannot set the mode of socket '%s', %w", path, err)
+		}
+	}
+	return lis, nil
+}
+
+var (
+	systemdListenersOnce = sync.OnceValues(func() (map[string]net.Listener, error) {
+		return systemdListenersFromEnv(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"), os.Getpid())
+	})
+)
+
+// systemdListener returns the socket passed by systemd socket activation with
+// the name (FileDescriptorName=) or index.
+func systemdListener(name string) (net.Listener, error) {
+	listeners, err := systemdListenersOnce()
+	if err != nil {
+		return nil, err
+	}
+	lis, ok := listeners[name]
+	if !ok {
+		return nil, fmt.Errorf("no systemd socket named '%s' was passed, check LISTEN_FDS and LISTEN_FDNAMES", name)
+	}
+	return lis, nil
+}
+
+// systemdListenersFromEnv converts the file descriptors passed by systemd into
+// listeners indexed by both their position and their name.
+func systemdListenersFromEnv(listenPID string, listenFDs string, listenFDNames string, pid int) (map[string]net.Listener, error) {
+	listeners := map[string]net.Listener{}
+	if listenFDs == "" {
+		return listeners, errors.New("no sockets were passed by systemd, LISTEN_FDS is not set")
+	}
+	if listenPID != "" && listenPID != strconv.Itoa(pid) {
+		return listeners, fmt.Errorf("sockets were passed to process %s, not this process %d", listenPID, pid)
+	}
+	count, err := strconv.Atoi(listenFDs)
+	if err != nil || count < 0 {
+		return listeners, fmt.Errorf("invalid LISTEN_FDS '%s'", listenFDs)
+	}
+	names := strings.Split(listenFDNames, ":")
+	for i := 0; i < count; i++ {
+		f := os.NewFile(uintptr(systemdListenFDsStart+i), fmt.Sprintf("LISTEN_FD_%d", systemdListenFDsStart+i))
+		lis, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return listeners, fmt.Errorf("cannot use systemd socket %d, %w", i, err)
+		}
+		listeners[strconv.Itoa(i)] = lis
+		if i < len(names) && names[i] != "" {
+			listeners[names[i]] = lis
+		}
+	}
+	return listeners, nil
+}
+
+// listenerURL returns a URL for the listener to print in the logs.
+func listenerURL(scheme string, lis net.Listener) string {
+	addr := lis.Addr()
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		host := "localhost"
+		if !tcpAddr.IP.IsUnspecified() {
+			host = tcpAddr.IP.String()
+		}
+		return fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(host, strconv.Itoa(tcpAddr.Port)))
+	}
+	return fmt.Sprintf("%s+%s:%s", scheme, addr.Network(), addr.String())
+}
@@ -0,0 +1,170 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewListenSpecs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		port      int
+		addresses []string
+		mode      string
+		want      []listenSpec
+	}{
+		{
+			name: "all interfaces",
+			port: 8080,
+			want: []listenSpec{{address: ":8080"}},
+		},
+		{
+			name:      "addresses",
+			port:      8080,
+			addresses: []string{"127.0.0.1", "192.168.1.2:9000", "::1", "[::1]", "localhost"},
+			want: []listenSpec{
+				{address: "127.0.0.1:8080"},
+				{address: "192.168.1.2:9000"},
+				{address: "[::1]:8080"},
+				{address: "[::1]:8080"},
+				{address: "localhost:8080"},
+			},
+		},
+		{
+			name:      "unix and systemd",
+			port:      8080,
+			addresses: []string{"unix:///run/gowebserver.sock", "systemd:http"},
+			mode:      "0660",
+			want: []listenSpec{
+				{address: "unix:///run/gowebserver.sock", socketMode: 0660},
+				{address: "systemd:http", socketMode: 0660},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := newListenSpecs(tc.port, tc.addresses, tc.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(listenSpec{})); diff != "" {
+				t.Errorf("newListenSpecs() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseListenAddressErrors(t *testing.T) {
+	for _, address := range []string{"unix://", "systemd:", "tcp://127.0.0.1:80", "127.0.0.1:http", "127.0.0.1:99999"} {
+		if _, _, err := parseListenAddress(address); err == nil {
+			t.Errorf("parseListenAddress(%q) want error", address)
+		}
+	}
+	for _, mode := range []string{"rw", "1777", "0999"} {
+		if _, err := parseUnixSocketMode(mode); err == nil {
+			t.Errorf("parseUnixSocketMode(%q) want error", mode)
+		}
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	socketPath := filepath.Join(mustTempDir(t), "gowebserver.sock")
+
+	// Leave a stale socket behind, as an unclean exit would.
+	stale, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("Unix domain sockets are not supported, %s", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	lis, err := listenSpec{address: unixAddressPrefix + socketPath, socketMode: 0600}.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0600 {
+		t.Errorf("want socket mode 0600, got %o", got)
+	}
+}
+
+func TestSystemdListenersFromEnvErrors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		listenPID string
+		listenFDs string
+	}{
+		{name: "not socket activated", listenPID: "", listenFDs: ""},
+		{name: "other process", listenPID: "1", listenFDs: "1"},
+		{name: "invalid count", listenPID: "100", listenFDs: "many"},
+	}
+	for _, tc := range testCases {
+		if _, err := systemdListenersFromEnv(tc.listenPID, tc.listenFDs, "", 100); err == nil {
+			t.Errorf("%s: want error", tc.name)
+		}
+	}
+}
+
+func TestWebServer_UnixSocket(t *testing.T) {
+	dir := mustTempDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	socketPath := filepath.Join(mustTempDir(t), "gowebserver.sock")
+
+	baseURL, close := serveAsync(t, &Config{
+		Serve: []Serve{{Source: dir, Endpoint: "/"}},
+		HTTP: HTTP{
+			Addresses: []string{"127.0.0.1", unixAddressPrefix + socketPath},
+		},
+		HTTPS: HTTPS{Disabled: true},
+	})
+	defer close()
+
+	if got := mustGetBody(t, baseURL+"/hello.txt"); got != "hello" {
+		t.Errorf("want 'hello' over TCP, got %q", got)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	resp, err := client.Get("http://unix/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want status %d over the Unix socket, got %d", http.StatusOK, resp.StatusCode)
+	}
+}
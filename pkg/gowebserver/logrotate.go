@@ -0,0 +1,289 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// rotatedLogTimeFormat is the suffix of rotated log files, it sorts in
+	// chronological order.
+	rotatedLogTimeFormat = "20060102T150405.000000000"
+	logFileMode          = os.FileMode(0644)
+	// rotationRetryInterval is how long a file that cannot be rotated keeps
+	// growing before the rotation is tried again.
+	rotationRetryInterval = time.Minute
+)
+
+var (
+	// rotatingFiles are the open log files by path, the handler generations
+	// of a reload share them so that only one of them rotates a file.
+	rotatingFilesMu sync.Mutex
+	rotatingFiles   = map[string]*rotatingFile{}
+)
+
+// rotatingFile is an append-only log file that is renamed with a timestamp
+// suffix, e.g. access.log.20261017T101500.000000000, and replaced by a new file
+// once it reaches maxSize.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	compress   bool
+	now        func() time.Time
+	rename     func(oldpath, newpath string) error
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	closed bool
+	// retryRotation is when a failed rotation is tried again.
+	retryRotation time.Time
+	// refs counts the users of a shared file, see acquireRotatingFile.
+	refs int
+	// background tracks the compression of rotated files.
+	background sync.WaitGroup
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int, compress bool) (*rotatingFile, error) {
+	if err := ensureDirs(path); err != nil {
+		return nil, err
+	}
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		compress:   compress,
+		now:        time.Now,
+		rename:     os.Rename,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// acquireRotatingFile returns the open log file of the path or opens it, the
+// settings of the latest caller apply. The returned function releases the
+// file, which is closed once every user has released it.
+func acquireRotatingFile(path string, maxSize int64, maxBackups int, compress bool) (*rotatingFile, func() error, error) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nilFuncWithError, err
+	}
+	rotatingFilesMu.Lock()
+	defer rotatingFilesMu.Unlock()
+	f, ok := rotatingFiles[key]
+	if ok {
+		f.mu.Lock()
+		f.maxSize, f.maxBackups, f.compress = maxSize, maxBackups, compress
+		f.mu.Unlock()
+	} else {
+		if f, err = openRotatingFile(path, maxSize, maxBackups, compress); err != nil {
+			return nil, nilFuncWithError, err
+		}
+		rotatingFiles[key] = f
+	}
+	f.refs++
+
+	var once sync.Once
+	release := func() error {
+		var err error
+		once.Do(func() {
+			rotatingFilesMu.Lock()
+			f.refs--
+			last := f.refs == 0
+			if last {
+				delete(rotatingFiles, key)
+			}
+			rotatingFilesMu.Unlock()
+			if last {
+				err = f.Close()
+			}
+		})
+		return err
+	}
+	return f, release, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, logFileMode)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	// The file is missing if it could not be opened again after a rotation.
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(b)) > f.maxSize && (f.retryRotation.IsZero() || !f.now().Before(f.retryRotation)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(b)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file and opens a new one, the caller must hold
+// the lock. If the file cannot be renamed it is opened again and keeps
+// growing until the rotation is retried.
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		zap.S().With("error", err, "file", f.path).Warn("cannot close log file for rotation")
+	}
+	f.file = nil
+	backup := f.path + "." + f.now().UTC().Format(rotatedLogTimeFormat)
+	renameErr := f.rename(f.path, backup)
+	if err := f.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		zap.S().With("error", renameErr, "file", f.path, "retryIn", rotationRetryInterval).Warn("cannot rotate log file, appending to it")
+		f.retryRotation = f.now().Add(rotationRetryInterval)
+		return nil
+	}
+	f.retryRotation = time.Time{}
+
+	if !f.compress {
+		f.prune()
+		return nil
+	}
+	f.background.Add(1)
+	go func() {
+		defer f.background.Done()
+		if err := gzipFile(backup); err != nil {
+			zap.S().With("error", err, "file", backup).Warn("cannot compress rotated log file")
+		}
+		f.prune()
+	}()
+	return nil
+}
+
+// prune deletes the oldest rotated files over maxBackups.
+func (f *rotatingFile) prune() {
+	backups := f.backups()
+	if f.maxBackups <= 0 || len(backups) <= f.maxBackups {
+		return
+	}
+	for _, backup := range backups[f.maxBackups:] {
+		for _, name := range []string{backup, backup + ".gz"} {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				zap.S().With("error", err, "file", name).Warn("cannot delete rotated log file")
+			}
+		}
+	}
+}
+
+// backups returns the rotated files, without the .gz extension, newest first.
+func (f *rotatingFile) backups() []string {
+	matches, err := filepath.Glob(escapeGlob(f.path) + ".*")
+	if err != nil {
+		return nil
+	}
+	backups := []string{}
+	for _, name := range matches {
+		name = strings.TrimSuffix(name, ".gz")
+		suffix := strings.TrimPrefix(name, f.path+".")
+		if _, err := time.Parse(rotatedLogTimeFormat, suffix); err != nil {
+			continue
+		}
+		if !slices.Contains(backups, name) {
+			backups = append(backups, name)
+		}
+	}
+	slices.Sort(backups)
+	slices.Reverse(backups)
+	return backups
+}
+
+// Close closes the file and waits for rotated files to be compressed.
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.closed = true
+	f.mu.Unlock()
+	f.background.Wait()
+	return err
+}
+
+// gzipFile replaces the file with a gzip compressed copy named file.gz.
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, logFileMode)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
+
+// escapeGlob escapes the glob metacharacters of a literal path.
+func escapeGlob(p string) string {
+	r := strings.NewReplacer(`*`, `\*`, `?`, `\?`, `[`, `\[`, `\`, `\\`)
+	if filepath.Separator == '\\' {
+		r = strings.NewReplacer(`*`, `[*]`, `?`, `[?]`, `[`, `[[]`)
+	}
+	return r.Replace(p)
+}
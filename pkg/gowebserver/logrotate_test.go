@@ -0,0 +1,221 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRotatingFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		compress bool
+		want     []string
+	}{
+		{
+			name: "plain",
+			want: []string{
+				"access.log",
+				"access.log.20261017T100003.000000000",
+				"access.log.20261017T100004.000000000",
+			},
+		},
+		{
+			name:     "compress",
+			compress: true,
+			want: []string{
+				"access.log",
+				"access.log.20261017T100003.000000000.gz",
+				"access.log.20261017T100004.000000000.gz",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := mustTempDir(t)
+			logFile := filepath.Join(dir, "access.log")
+			f, err := openRotatingFile(logFile, 10, 2, tc.compress)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clock := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+			f.now = func() time.Time {
+				clock = clock.Add(time.Second)
+				return clock
+			}
+			// Every line is 6 bytes so each one after the first rotates the file.
+			for _, line := range []string{"line1\n", "line2\n", "line3\n", "line4\n", "line5\n"} {
+				if _, err := f.Write([]byte(line)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("log files mismatch (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff("line5\n", mustReadLogFile(t, logFile)); diff != "" {
+				t.Errorf("current log mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff("line4\n", mustReadLogFile(t, filepath.Join(dir, tc.want[2]))); diff != "" {
+				t.Errorf("newest rotated log mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRotatingFile_Append(t *testing.T) {
+	logFile := filepath.Join(mustTempDir(t), "access.log")
+	if err := os.WriteFile(logFile, []byte("existing\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := openRotatingFile(logFile, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("closed\n")); err == nil {
+		t.Error("want an error writing to a closed file")
+	}
+
+	if diff := cmp.Diff("existing\nnew\n", mustReadLogFile(t, logFile)); diff != "" {
+		t.Errorf("log mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRotatingFile_RenameFailure(t *testing.T) {
+	dir := mustTempDir(t)
+	logFile := filepath.Join(dir, "access.log")
+	f, err := openRotatingFile(logFile, 10, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	clock := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return clock }
+	renames := 0
+	f.rename = func(string, string) error {
+		renames++
+		return os.ErrPermission
+	}
+
+	for _, line := range []string{"line1\n", "line2\n", "line3\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write(%q) got %v, want the line appended", line, err)
+		}
+	}
+	if renames != 1 {
+		t.Errorf("got %d renames, want 1 until the retry interval passes", renames)
+	}
+	if diff := cmp.Diff("line1\nline2\nline3\n", mustReadLogFile(t, logFile)); diff != "" {
+		t.Errorf("log mismatch (-want +got):\n%s", diff)
+	}
+
+	clock = clock.Add(rotationRetryInterval)
+	f.rename = os.Rename
+	if _, err := f.Write([]byte("line4\n")); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("line4\n", mustReadLogFile(t, logFile)); diff != "" {
+		t.Errorf("log after the retried rotation mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAcquireRotatingFile(t *testing.T) {
+	logFile := filepath.Join(mustTempDir(t), "access.log")
+	first, releaseFirst, err := acquireRotatingFile(logFile, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, releaseSecond, err := acquireRotatingFile(logFile, 100, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatal("want the file shared by the users of the same path")
+	}
+	if first.maxSize != 100 {
+		t.Errorf("got maxSize %d, want the settings of the latest user", first.maxSize)
+	}
+
+	if err := releaseFirst(); err != nil {
+		t.Fatal(err)
+	}
+	// Releasing twice must not close the file under the other user.
+	releaseFirst()
+	if _, err := second.Write([]byte("second\n")); err != nil {
+		t.Fatalf("Write() after the first release got %v", err)
+	}
+	if err := releaseSecond(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Write([]byte("closed\n")); err == nil {
+		t.Error("want an error writing after the last release")
+	}
+
+	third, releaseThird, err := acquireRotatingFile(logFile, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseThird()
+	if third == first {
+		t.Error("want the file opened again after the last release")
+	}
+}
+
+func mustReadLogFile(tb testing.TB, name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if filepath.Ext(name) == ".gz" {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		r = zr
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return string(b)
+}
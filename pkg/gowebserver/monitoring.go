@@ -18,6 +18,8 @@ import (
 	"context"
 	"net/http"
 	"net/http/pprof"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -38,6 +40,7 @@ import (
 
 func setupMonitoring(m Monitoring) (*monitoringContext, error) {
 	mc := &monitoringContext{
+		conf:     m,
 		handlers: map[string]http.Handler{},
 	}
 
@@ -113,6 +116,20 @@ func setupMonitoring(m Monitoring) (*monitoringContext, error) {
 		}
 	}
 
+	meter := mc.getMeterProvider().Meter("gowebserver")
+	if mc.configReloads, err = meter.Int64Counter("config_reloads_total", metric.WithDescription("Number of configuration reload attempts.")); err != nil {
+		mc.shutdown()
+		return nil, err
+	}
+	if mc.http3Requests, err = meter.Int64Counter("http3_requests_total", metric.WithDescription("Number of requests served over HTTP/3.")); err != nil {
+		mc.shutdown()
+		return nil, err
+	}
+	if mc.rejectedConnections, err = meter.Int64Counter("rejected_connections_total", metric.WithDescription("Number of connections rejected for exceeding a limit.")); err != nil {
+		mc.shutdown()
+		return nil, err
+	}
+
 	return mc, nil
 }
 
@@ -142,11 +159,55 @@ func newPrometheusExporter(m Monitoring, r *resource.Resource) (*otelprom.Export
 	return prometheusExporter, provider, h, nil
 }
 
+// monitoringContext is shared by the handler generations of a server until a
+// reload changes the monitoring configuration, the last generation that uses
+// it shuts it down.
 type monitoringContext struct {
+	conf         Monitoring
 	handlers     map[string]http.Handler
 	promExporter *otelprom.Exporter
 	promProvider metric.MeterProvider
 	tp           *sdktrace.TracerProvider
+
+	// The instruments of the server rather than of a handler generation, e.g.
+	// of the listeners that are kept across reloads.
+	configReloads       metric.Int64Counter
+	http3Requests       metric.Int64Counter
+	rejectedConnections metric.Int64Counter
+
+	mu   sync.Mutex
+	refs int
+}
+
+// reusableFor reports whether the context serves the monitoring
+// configuration.
+func (m *monitoringContext) reusableFor(conf Monitoring) bool {
+	return m != nil && reflect.DeepEqual(m.conf, conf)
+}
+
+// acquire adds a handler generation that uses the context.
+func (m *monitoringContext) acquire() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refs++
+}
+
+// release removes a handler generation that uses the context and shuts it
+// down after the last one.
+func (m *monitoringContext) release() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.refs--
+	last := m.refs == 0
+	m.mu.Unlock()
+	if last {
+		m.shutdown()
+	}
 }
 
 func (m *monitoringContext) getTraceProvider() trace.TracerProvider {
@@ -0,0 +1,221 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"io/fs"
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// mountOptions are the resolved serving options of a single mount.
+type mountOptions struct {
+	enhancedList   bool
+	richView       bool
+	disableListing bool
+	cacheControl   string
+	cache          []CacheRule
+	etag           string
+	hidden         []string
+	readOnly       bool
+	cors           CORS
+	headers        []HeaderRule
+	rateLimit      RateLimit
+	ipFilter       IPFilter
+	access         map[string][]string
+	precompressed  bool
+}
+
+// newMountOptions resolves the options of the mount, falling back to the
+// global settings for the options it does not set.
+func newMountOptions(s Serve, enhancedList bool, cors CORS) mountOptions {
+	opts := mountOptions{
+		enhancedList:   enhancedList,
+		richView:       true,
+		disableListing: s.DisableListing,
+		cacheControl:   s.CacheControl,
+		cache:          s.Cache,
+		etag:           s.ETag,
+		hidden:         s.Hidden,
+		readOnly:       s.ReadOnly,
+		cors:           cors,
+		headers:        s.Headers,
+		rateLimit:      s.RateLimit,
+		ipFilter:       s.IPFilter,
+		access:         s.Access,
+		precompressed:  s.Precompressed,
+	}
+	if s.EnhancedList != nil {
+		opts.enhancedList = *s.EnhancedList
+	}
+	if s.RichView != nil {
+		opts.richView = *s.RichView
+	}
+	if s.CORS != nil {
+		opts.cors = *s.CORS
+	}
+	if opts.disableListing {
+		opts.enhancedList = false
+	}
+	return opts
+}
+
+// equal reports whether the mounts can share a handler. Mounts with a rate
+// limit never do so that each keeps its own limit.
+func (o mountOptions) equal(other mountOptions) bool {
+	return o.rateLimit.RequestsPerSecond <= 0 && other.rateLimit.RequestsPerSecond <= 0 &&
+		o.enhancedList == other.enhancedList &&
+		o.richView == other.richView &&
+		o.disableListing == other.disableListing &&
+		o.cacheControl == other.cacheControl &&
+		slices.Equal(o.cache, other.cache) &&
+		o.etag == other.etag &&
+		slices.Equal(o.hidden, other.hidden) &&
+		o.readOnly == other.readOnly &&
+		o.cors.equal(other.cors) &&
+		o.ipFilter.equal(other.ipFilter) &&
+		o.precompressed == other.precompressed &&
+		slices.EqualFunc(o.headers, other.headers, HeaderRule.equal) &&
+		maps.EqualFunc(o.access, other.access, slices.Equal)
+}
+
+// wrapHandler applies the options that do not depend on the file system.
+func (o mountOptions) wrapHandler(h http.Handler) http.Handler {
+	if o.cacheControl != "" {
+		next := h
+		h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", o.cacheControl)
+			next.ServeHTTP(w, r)
+		})
+	}
+	if len(o.cache) > 0 {
+		h = &cacheRulesHandler{next: h, rules: o.cache}
+	}
+	if o.readOnly {
+		h = &readOnlyHandler{next: h}
+	}
+	if len(o.headers) > 0 {
+		h = &headerRulesHandler{next: h, rules: o.headers}
+	}
+	return o.cors.handler(h)
+}
+
+// wrapFS applies the options that filter the file system.
+func (o mountOptions) wrapFS(fsys fs.FS) fs.FS {
+	if len(o.hidden) > 0 {
+		return &hiddenFS{FS: fsys, patterns: o.hidden}
+	}
+	return fsys
+}
+
+type readOnlyHandler struct {
+	next http.Handler
+}
+
+func (h *readOnlyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		h.next.ServeHTTP(w, r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// noListingHandler responds with 404 Not Found for directories that do not
+// have an index.html.
+type noListingHandler struct {
+	next http.Handler
+	fsys fs.FS
+}
+
+func (h *noListingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := cleanPath(strings.TrimPrefix(r.URL.Path, "/"))
+	if stat, err := fs.Stat(h.fsys, name); err == nil && stat.IsDir() {
+		if _, err := fs.Stat(h.fsys, path.Join(name, "index.html")); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// hiddenFS hides the files and directories whose name matches any of the
+// patterns. Hidden entries are omitted from directory listings and cannot be
+// opened.
+type hiddenFS struct {
+	fs.FS
+	patterns []string
+}
+
+func (h *hiddenFS) Open(name string) (fs.File, error) {
+	if h.isHidden(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	f, err := h.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if dir, ok := f.(fs.ReadDirFile); ok {
+		return &hiddenDirFile{ReadDirFile: dir, fsys: h}, nil
+	}
+	return f, nil
+}
+
+func (h *hiddenFS) isHidden(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if h.isHiddenName(part) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *hiddenFS) isHiddenName(name string) bool {
+	if name == "." || name == "" {
+		return false
+	}
+	for _, pattern := range h.patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type hiddenDirFile struct {
+	fs.ReadDirFile
+	fsys *hiddenFS
+}
+
+func (f *hiddenDirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	result := []fs.DirEntry{}
+	for {
+		entries, err := f.ReadDirFile.ReadDir(n)
+		for _, entry := range entries {
+			if !f.fsys.isHiddenName(entry.Name()) {
+				result = append(result, entry)
+			}
+		}
+		// Keep reading when a batch was entirely hidden so that callers
+		// reading in batches do not mistake it for the end of the directory.
+		if err != nil || n <= 0 || len(result) > 0 || len(entries) == 0 {
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewMountOptions(t *testing.T) {
+	yes := true
+	no := false
+	testCases := []struct {
+		name         string
+		serve        Serve
+		enhancedList bool
+		want         mountOptions
+	}{
+		{
+			name:         "defaults",
+			serve:        Serve{},
+			enhancedList: true,
+			want:         mountOptions{enhancedList: true, richView: true},
+		},
+		{
+			name:         "overrides",
+			serve:        Serve{EnhancedList: &yes, RichView: &no, CacheControl: "no-store", Hidden: []string{".*"}, ReadOnly: true},
+			enhancedList: false,
+			want:         mountOptions{enhancedList: true, richView: false, cacheControl: "no-store", hidden: []string{".*"}, readOnly: true},
+		},
+		{
+			name:         "disable listing",
+			serve:        Serve{EnhancedList: &yes, DisableListing: true},
+			enhancedList: true,
+			want:         mountOptions{enhancedList: false, richView: true, disableListing: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got := newMountOptions(tc.serve, tc.enhancedList, CORS{})
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(mountOptions{})); diff != "" {
+				t.Errorf("newMountOptions() mismatch (-want +got):\n%s", diff)
+			}
+			if !got.equal(tc.want) {
+				t.Errorf("equal() got false for %+v", got)
+			}
+		})
+	}
+}
+
+func TestHiddenFS(t *testing.T) {
+	fsys := &hiddenFS{
+		FS: fstest.MapFS{
+			"index.html":       {Data: []byte("index")},
+			".git/config":      {Data: []byte("secret")},
+			"docs/.env":        {Data: []byte("secret")},
+			"docs/readme.md":   {Data: []byte("readme")},
+			"docs/backup.bak":  {Data: []byte("backup")},
+			"docs/sub/file.go": {Data: []byte("package sub")},
+		},
+		patterns: []string{".*", "*.bak"},
+	}
+
+	for _, name := range []string{".git", ".git/config", "docs/.env", "docs/backup.bak"} {
+		if _, err := fsys.Open(name); !os.IsNotExist(err) {
+			t.Errorf("Open(%q) want not exist error, got %v", name, err)
+		}
+	}
+	if _, err := fs.ReadFile(fsys, "docs/readme.md"); err != nil {
+		t.Errorf("ReadFile(docs/readme.md) failed, %s", err)
+	}
+
+	testCases := []struct {
+		dir  string
+		want []string
+	}{
+		{dir: ".", want: []string{"docs", "index.html"}},
+		{dir: "docs", want: []string{"readme.md", "sub"}},
+	}
+	for _, tc := range testCases {
+		entries, err := fs.ReadDir(fsys, tc.dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, entry := range entries {
+			got = append(got, entry.Name())
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("ReadDir(%q) mismatch (-want +got):\n%s", tc.dir, diff)
+		}
+	}
+}
+
+func TestNoListingHandler(t *testing.T) {
+	fsys := fstest.MapFS{
+		"site/index.html": {Data: []byte("site")},
+		"files/a.txt":     {Data: []byte("a")},
+	}
+	h := &noListingHandler{next: http.FileServer(http.FS(fsys)), fsys: fsys}
+
+	testCases := []struct {
+		path string
+		want int
+	}{
+		{path: "/files/", want: http.StatusNotFound},
+		{path: "/", want: http.StatusNotFound},
+		{path: "/files/a.txt", want: http.StatusOK},
+		{path: "/site/", want: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != tc.want {
+			t.Errorf("GET %s got status %d, want %d", tc.path, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestMountOptionsWrapHandler(t *testing.T) {
+	h := mountOptions{cacheControl: "max-age=3600", readOnly: true}.wrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET got status %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "max-age=3600" {
+		t.Errorf("want Cache-Control 'max-age=3600', got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST got status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestWebServer_PerMountOptions(t *testing.T) {
+	site := mustTempDir(t)
+	pub := mustTempDir(t)
+	if err := os.WriteFile(filepath.Join(site, "index.html"), []byte("site"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pub, "release.tar"), []byte("release"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pub, ".secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	baseURL, close := serveAsync(t, &Config{
+		Serve: []Serve{
+			{Source: site, Endpoint: "/"},
+			{Source: pub, Endpoint: "/pub", CacheControl: "max-age=60", Hidden: []string{".*"}, ReadOnly: true},
+		},
+	})
+	defer close()
+
+	if got := mustGetBody(t, baseURL+"/"); got != "site" {
+		t.Errorf("want 'site', got %q", got)
+	}
+
+	resp, err := http.Get(baseURL + "/pub/release.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("Cache-Control"); got != "max-age=60" {
+		t.Errorf("want Cache-Control 'max-age=60', got %q", got)
+	}
+
+	resp, err = http.Get(baseURL + "/pub/.secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("want hidden file status %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
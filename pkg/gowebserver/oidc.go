@@ -0,0 +1,393 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	oidcStateCookieName = "gowebserver_oidc"
+	// oidcStateTimeout is how long a browser has to sign in with the provider.
+	oidcStateTimeout = 10 * time.Minute
+)
+
+var defaultOIDCScopes = []string{"openid", "profile", "email"}
+
+// oidcMetadata is the part of the OpenID Provider Metadata that the
+// authorization code flow needs.
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider is an OpenID Connect provider, its metadata is discovered on
+// first use.
+type oidcProvider struct {
+	conf OIDC
+	// startPath redirects browsers to the provider, callbackPath is where the
+	// provider sends them back to.
+	startPath    string
+	callbackPath string
+	client       *http.Client
+
+	mu       sync.Mutex
+	metadata *oidcMetadata
+	keys     *keySet
+	// discovering is the discovery in progress, nil if there is none.
+	discovering *oidcDiscovery
+}
+
+// oidcDiscovery is a fetch of the provider's metadata shared by the requests
+// that need it.
+type oidcDiscovery struct {
+	// done is closed once the discovery completes, err is then its error.
+	done chan struct{}
+	err  error
+}
+
+func newOIDCProvider(conf OIDC, loginPath string, client *http.Client) *oidcProvider {
+	base := strings.TrimSuffix(loginPath, "/")
+	p := &oidcProvider{
+		conf:         conf,
+		startPath:    base + "/oidc",
+		callbackPath: base + "/callback",
+		client:       client,
+	}
+	if u, err := url.Parse(conf.RedirectURL); err == nil && u.Path != "" {
+		p.callbackPath = u.Path
+	}
+	if len(p.conf.Scopes) == 0 {
+		p.conf.Scopes = defaultOIDCScopes
+	} else if !slices.Contains(p.conf.Scopes, "openid") {
+		p.conf.Scopes = append([]string{"openid"}, p.conf.Scopes...)
+	}
+	return p
+}
+
+// discover returns the provider's metadata and keys. The metadata is fetched
+// once in the background for all the requests that wait for it, a failed
+// discovery is tried again by the next request.
+func (p *oidcProvider) discover(ctx context.Context) (*oidcMetadata, *keySet, error) {
+	p.mu.Lock()
+	if p.metadata != nil {
+		defer p.mu.Unlock()
+		return p.metadata, p.keys, nil
+	}
+	d := p.discovering
+	if d == nil {
+		d = &oidcDiscovery{done: make(chan struct{})}
+		p.discovering = d
+		go p.runDiscovery(d)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-d.done:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.metadata, p.keys, nil
+}
+
+// runDiscovery fetches the metadata and keys of the provider and completes d.
+func (p *oidcProvider) runDiscovery(d *oidcDiscovery) {
+	ctx, cancel := context.WithTimeout(context.Background(), identityProviderTimeout)
+	defer cancel()
+	m, keys, err := p.fetchMetadata(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		p.metadata, p.keys = m, keys
+	}
+	d.err = err
+	p.discovering = nil
+	close(d.done)
+}
+
+// fetchMetadata reads the OpenID Provider Metadata of the issuer.
+func (p *oidcProvider) fetchMetadata(ctx context.Context) (*oidcMetadata, *keySet, error) {
+	data, err := getJSON(ctx, p.client, strings.TrimSuffix(p.conf.Issuer, "/")+"/.well-known/openid-configuration")
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot discover OpenID Connect provider '%s', %w", p.conf.Issuer, err)
+	}
+	m := &oidcMetadata{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, nil, fmt.Errorf("cannot parse OpenID Connect provider metadata, %w", err)
+	}
+	if m.Issuer != p.conf.Issuer {
+		return nil, nil, fmt.Errorf("OpenID Connect provider issuer '%s' is not '%s'", m.Issuer, p.conf.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || !isSupportedHTTP(m.JWKSURI) {
+		return nil, nil, fmt.Errorf("OpenID Connect provider '%s' does not support the authorization code flow", p.conf.Issuer)
+	}
+	keys, err := newKeySet(m.JWKSURI, p.client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, keys, nil
+}
+
+// redirectURL returns the callback URL of the request's host unless one is
+// configured.
+func (p *oidcProvider) redirectURL(r *http.Request) string {
+	if p.conf.RedirectURL != "" {
+		return p.conf.RedirectURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + p.callbackPath
+}
+
+// oidcState is the signed content of the cookie that ties the provider's
+// callback to the browser that started signing in.
+type oidcState struct {
+	State    string `json:"s"`
+	Verifier string `json:"v"`
+	Nonce    string `json:"n"`
+	Next     string `json:"next"`
+	Expires  int64  `json:"e"`
+}
+
+func oidcStateMAC(payload []byte) []byte {
+	mac := hmac.New(sha256.New, sessionKey())
+	io.WriteString(mac, "oidc\x00")
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func (s oidcState) encode() string {
+	payload, _ := json.Marshal(s)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(oidcStateMAC(payload))
+}
+
+func decodeOIDCState(value string, now time.Time) (oidcState, bool) {
+	s := oidcState{}
+	encodedPayload, encodedMAC, ok := strings.Cut(value, ".")
+	if !ok {
+		return s, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return s, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, oidcStateMAC(payload)) {
+		return s, false
+	}
+	if err := json.Unmarshal(payload, &s); err != nil || !now.Before(time.Unix(s.Expires, 0)) {
+		return s, false
+	}
+	return s, true
+}
+
+func (p *oidcProvider) stateCookie(r *http.Request) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookieName,
+		Path:     p.callbackPath,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// serveOIDCStart redirects the browser to the provider's authorization
+// endpoint with a PKCE challenge.
+func (a *authenticator) serveOIDCStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	p := a.oidc
+	m, _, err := p.discover(r.Context())
+	if err != nil {
+		zap.S().With("error", err).Error("cannot start single sign-on")
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	authURL, err := url.Parse(m.AuthorizationEndpoint)
+	if err != nil {
+		zap.S().With("error", err, "authorizationEndpoint", m.AuthorizationEndpoint).Error("cannot start single sign-on")
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	state := oidcState{
+		State:    rand.Text(),
+		Verifier: rand.Text() + rand.Text(),
+		Nonce:    rand.Text(),
+		Next:     safeRedirect(r.URL.Query().Get("next")),
+		Expires:  a.now().Add(oidcStateTimeout).Unix(),
+	}
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.conf.ClientID)
+	query.Set("redirect_uri", p.redirectURL(r))
+	query.Set("scope", strings.Join(p.conf.Scopes, " "))
+	query.Set("state", state.State)
+	query.Set("nonce", state.Nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	cookie := p.stateCookie(r)
+	cookie.Value = state.encode()
+	cookie.MaxAge = int(oidcStateTimeout.Seconds())
+	http.SetCookie(w, cookie)
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, authURL.String(), http.StatusFound)
+}
+
+// serveOIDCCallback exchanges the authorization code for an ID token and
+// signs the browser in as its user.
+func (a *authenticator) serveOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	p := a.oidc
+	fail := func(err error) {
+		zap.S().With("error", err, "remoteAddr", r.RemoteAddr).Info("Failed single sign-on")
+		page := a.newLoginPage(r, "/")
+		page.Error = "Single sign-on failed, try again."
+		a.renderLogin(w, http.StatusUnauthorized, page)
+	}
+
+	c, err := r.Cookie(oidcStateCookieName)
+	if err != nil {
+		fail(fmt.Errorf("no sign in in progress"))
+		return
+	}
+	clearCookie := p.stateCookie(r)
+	clearCookie.MaxAge = -1
+	http.SetCookie(w, clearCookie)
+	state, ok := decodeOIDCState(c.Value, a.now())
+	if !ok {
+		fail(fmt.Errorf("invalid or expired sign in state"))
+		return
+	}
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state.State)) != 1 {
+		fail(fmt.Errorf("state mismatch"))
+		return
+	}
+	if e := query.Get("error"); e != "" {
+		fail(fmt.Errorf("provider returned %s: %s", e, query.Get("error_description")))
+		return
+	}
+
+	m, keys, err := p.discover(r.Context())
+	if err != nil {
+		fail(err)
+		return
+	}
+	idToken, err := p.exchange(r, m, query.Get("code"), state.Verifier)
+	if err != nil {
+		fail(err)
+		return
+	}
+	claims, err := verifyJWT(r.Context(), idToken, keys)
+	if err != nil {
+		fail(fmt.Errorf("invalid ID token, %w", err))
+		return
+	}
+	if err := checkClaims(claims, p.conf.Issuer, p.conf.ClientID, a.now()); err != nil {
+		fail(fmt.Errorf("invalid ID token, %w", err))
+		return
+	}
+	if nonce, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(nonce), []byte(state.Nonce)) != 1 {
+		fail(fmt.Errorf("invalid ID token, nonce mismatch"))
+		return
+	}
+	id, err := a.claims.identity(claims, identitySourceOIDC)
+	if err != nil {
+		fail(fmt.Errorf("invalid ID token, %w", err))
+		return
+	}
+	a.signIn(w, r, id, state.Next)
+}
+
+// exchange redeems the authorization code at the token endpoint and returns
+// the ID token.
+func (p *oidcProvider) exchange(r *http.Request, m *oidcMetadata, code string, verifier string) (string, error) {
+	if code == "" {
+		return "", fmt.Errorf("no authorization code")
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL(r)},
+		"code_verifier": {verifier},
+		"client_id":     {p.conf.ClientID},
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("cannot create token request, %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.conf.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.conf.ClientID), url.QueryEscape(p.conf.ClientSecret))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot redeem authorization code, %w", err)
+	}
+	defer resp.Body.Close()
+	body := struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("cannot parse token response (%s), %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s: %s %s", resp.Status, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("token response has no id_token")
+	}
+	return body.IDToken, nil
+}
@@ -0,0 +1,289 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// testOIDCProvider is an OpenID Connect provider that signs every browser in
+// as the user of claims without asking.
+type testOIDCProvider struct {
+	*httptest.Server
+	signer       testSigner
+	clientID     string
+	clientSecret string
+	claims       map[string]any
+
+	mu    sync.Mutex
+	codes map[string]url.Values
+}
+
+func newTestOIDCProvider(tb testing.TB, claims map[string]any) *testOIDCProvider {
+	tb.Helper()
+	p := &testOIDCProvider{
+		signer:       mustNewTestSigner(tb, "idp", "RS256"),
+		clientID:     "gowebserver",
+		clientSecret: "s3cret",
+		claims:       claims,
+		codes:        map[string]url.Values{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcMetadata{
+			Issuer:                p.URL,
+			AuthorizationEndpoint: p.URL + "/authorize",
+			TokenEndpoint:         p.URL + "/token",
+			JWKSURI:               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []jsonWebKey{p.signer.jwk()}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("response_type") != "code" || q.Get("client_id") != p.clientID || q.Get("code_challenge_method") != "S256" || !strings.Contains(q.Get("scope"), "openid") {
+			http.Error(w, "invalid_request", http.StatusBadRequest)
+			return
+		}
+		code := rand.Text()
+		p.mu.Lock()
+		p.codes[code] = q
+		p.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?"+url.Values{"code": {code}, "state": {q.Get("state")}}.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != p.clientID || secret != p.clientSecret || r.PostFormValue("grant_type") != "authorization_code" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		p.mu.Lock()
+		authorize, ok := p.codes[r.PostFormValue("code")]
+		delete(p.codes, r.PostFormValue("code"))
+		p.mu.Unlock()
+		challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if !ok || authorize.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]) || authorize.Get("redirect_uri") != r.PostFormValue("redirect_uri") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := map[string]any{"iss": p.URL, "aud": p.clientID, "exp": time.Now().Add(time.Hour).Unix(), "nonce": authorize.Get("nonce")}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.signer.sign(tb, claims), "token_type": "Bearer"})
+	})
+	p.Server = httptest.NewServer(mux)
+	tb.Cleanup(p.Close)
+	return p
+}
+
+func TestWebServer_OIDC(t *testing.T) {
+	provider := newTestOIDCProvider(t, map[string]any{"sub": "1234", "preferred_username": "frank", "groups": []string{"engineering"}})
+	dir := mustTempDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	baseURL, close := serveAsync(t, &Config{
+		Serve: []Serve{
+			{Source: dir, Endpoint: "/private", Access: map[string][]string{"staff": {permissionRead}}},
+			{Source: dir, Endpoint: "/admin", Access: map[string][]string{"admins": {permissionRead}}},
+		},
+		Auth: Auth{
+			LoginEndpoint:  "/login",
+			SessionTimeout: time.Hour,
+			OIDC:           OIDC{Issuer: provider.URL, ClientID: provider.clientID, ClientSecret: provider.clientSecret},
+			Claims:         Claims{Username: "preferred_username", Roles: "groups", RoleMapping: map[string][]string{"engineering": {"staff"}}},
+		},
+	})
+	defer close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	browser := &http.Client{Jar: jar}
+	get := func(p string) (int, string, string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, baseURL+p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "text/html")
+		resp, err := browser.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Request.URL.Path, string(body)
+	}
+
+	// Without local users browsers go straight to the provider and come back
+	// signed in.
+	status, path, body := get("/private/a.txt")
+	if status != http.StatusOK || path != "/private/a.txt" || body != "a" {
+		t.Errorf("got %d %s %q, want the file after signing in", status, path, body)
+	}
+	if status, _, _ := get("/admin/a.txt"); status != http.StatusForbidden {
+		t.Errorf("got status %d for a mount without the user's role, want %d", status, http.StatusForbidden)
+	}
+	if _, _, body := get("/login"); !strings.Contains(body, "Signed in as frank") {
+		t.Errorf("want the login page to show the signed in user, got\n%s", body)
+	}
+
+	// The callback only accepts the browser that started signing in.
+	resp, err := http.Get(baseURL + "/login/callback?code=stolen&state=guess")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got status %d for a callback without state, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthenticator_OIDCCallback(t *testing.T) {
+	provider := newTestOIDCProvider(t, map[string]any{"sub": "frank"})
+	testCases := []struct {
+		name       string
+		conf       OIDC
+		claims     map[string]any
+		wantStatus int
+	}{
+		{name: "signed in", conf: OIDC{ClientSecret: provider.clientSecret}, wantStatus: http.StatusSeeOther},
+		{name: "wrong client secret", conf: OIDC{ClientSecret: "wrong"}, wantStatus: http.StatusUnauthorized},
+		{name: "wrong issuer", conf: OIDC{ClientSecret: provider.clientSecret}, claims: map[string]any{"iss": "https://evil"}, wantStatus: http.StatusUnauthorized},
+		{name: "wrong nonce", conf: OIDC{ClientSecret: provider.clientSecret}, claims: map[string]any{"nonce": "replayed"}, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider.claims = map[string]any{"sub": "frank"}
+			for k, v := range tc.claims {
+				provider.claims[k] = v
+			}
+			tc.conf.Issuer = provider.URL
+			tc.conf.ClientID = provider.clientID
+			a, err := newAuthenticator(Auth{OIDC: tc.conf}, ClientAuth{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			a.serveOIDCStart(w, httptest.NewRequest(http.MethodGet, "http://files.example.com/login/oidc?next=/private/", nil))
+			if w.Code != http.StatusFound {
+				t.Fatalf("start got status %d, want %d", w.Code, http.StatusFound)
+			}
+			// The provider redirects back to the callback of the requested host.
+			noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+			resp, err := noRedirects.Get(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if !strings.HasPrefix(resp.Header.Get("Location"), "http://files.example.com/login/callback?") {
+				t.Fatalf("got provider redirect to %s, want the callback", resp.Header.Get("Location"))
+			}
+
+			callback := httptest.NewRequest(http.MethodGet, resp.Header.Get("Location"), nil)
+			for _, c := range w.Result().Cookies() {
+				callback.AddCookie(c)
+			}
+			w = httptest.NewRecorder()
+			a.serveOIDCCallback(w, callback)
+			if w.Code != tc.wantStatus {
+				t.Fatalf("callback got status %d, want %d", w.Code, tc.wantStatus)
+			}
+			if w.Code != http.StatusSeeOther {
+				return
+			}
+			if got := w.Header().Get("Location"); got != "/private/" {
+				t.Errorf("got redirect to %s, want /private/", got)
+			}
+			cookies := map[string]*http.Cookie{}
+			for _, c := range w.Result().Cookies() {
+				cookies[c.Name] = c
+			}
+			id, ok := a.verifySession(cookies[sessionCookieName].Value)
+			if !ok {
+				t.Fatal("want a valid session cookie")
+			}
+			if diff := cmp.Diff(&identity{name: "frank", source: identitySourceOIDC}, id, cmp.AllowUnexported(identity{})); diff != "" {
+				t.Errorf("identity mismatch (-want +got):\n%s", diff)
+			}
+			if c := cookies[oidcStateCookieName]; c == nil || c.MaxAge >= 0 {
+				t.Error("want the state cookie cleared")
+			}
+		})
+	}
+}
+
+func TestOIDCProvider_DiscoverCancelled(t *testing.T) {
+	release := make(chan struct{})
+	fetches := atomic.Int32{}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		json.NewEncoder(w).Encode(oidcMetadata{
+			Issuer:                srv.URL,
+			AuthorizationEndpoint: srv.URL + "/authorize",
+			TokenEndpoint:         srv.URL + "/token",
+			JWKSURI:               srv.URL + "/jwks",
+		})
+	}))
+	defer srv.Close()
+	p := newOIDCProvider(OIDC{Issuer: srv.URL}, "/login", srv.Client())
+
+	// The request that starts the discovery gives up, the discovery goes on
+	// for the requests after it.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := p.discover(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("discover() with a cancelled context got %v, want %v", err, context.Canceled)
+	}
+	close(release)
+	m, keys, err := p.discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.JWKSURI != srv.URL+"/jwks" || keys == nil {
+		t.Errorf("got metadata %+v, want the provider's", m)
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("got %d discoveries, want 1", got)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"io/fs"
+	"net/http"
+)
+
+// Option customizes a WebServer created by New.
+type Option func(*serverOptions)
+
+// Middleware wraps the handler of the server. It runs after the client IP
+// filters and authentication, and before the rate limits and mounts.
+type Middleware func(http.Handler) http.Handler
+
+type serverOptions struct {
+	middleware []Middleware
+	mounts     []fsMount
+	// flagDefaultsBase reloads the configuration on top of the defaults of
+	// the flags instead of the configuration given to New.
+	flagDefaultsBase bool
+	// monitoringCtx is the monitoring context of the running configuration,
+	// a reload keeps it if the monitoring configuration is unchanged.
+	monitoringCtx *monitoringContext
+}
+
+// fsMount is a file system mounted with WithFS.
+type fsMount struct {
+	serve Serve
+	fsys  fs.FS
+}
+
+// WithMiddleware adds middleware to the server, the first one is the
+// outermost.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *serverOptions) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// WithFS mounts fsys, e.g. an embed.FS, at serve.Endpoint alongside the
+// mounts of the configuration. The other fields of serve, such as Hidden or
+// Access, apply to the mount as they do to configured mounts and its Source is
+// ignored.
+func WithFS(serve Serve, fsys fs.FS) Option {
+	return func(o *serverOptions) {
+		o.mounts = append(o.mounts, fsMount{serve: serve, fsys: fsys})
+	}
+}
+
+// withFlagDefaultsBase is for the configuration of Load, which already has
+// the layers that a reload applies again.
+func withFlagDefaultsBase() Option {
+	return func(o *serverOptions) {
+		o.flagDefaultsBase = true
+	}
+}
+
+// withMonitoringContext reuses the monitoring context of the running
+// configuration.
+func withMonitoringContext(mc *monitoringContext) Option {
+	return func(o *serverOptions) {
+		o.monitoringCtx = mc
+	}
+}
+
+// applyMiddleware wraps h with the middleware, the first one is the outermost.
+func applyMiddleware(h http.Handler, middleware []Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
@@ -0,0 +1,160 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// mustServeRequest serves the request with the body and headers through h.
+func mustServeRequest(tb testing.TB, h http.Handler, method string, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	tb.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestWebServer_Handler(t *testing.T) {
+	dir := mustTempDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "disk.txt"), []byte("disk"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	assets := fstest.MapFS{
+		"app.js":      {Data: []byte("console.log(1)")},
+		".secret.txt": {Data: []byte("secret")},
+	}
+	calls := []string{}
+	trace := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				w.Header().Set("X-Middleware", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	ws, err := New(&Config{Serve: []Serve{{Source: dir, Endpoint: "/disk"}}},
+		WithFS(Serve{Endpoint: "/assets", Hidden: []string{".*"}}, assets),
+		WithMiddleware(trace("outer"), trace("inner")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Shutdown(context.Background())
+	h, err := ws.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path string
+		want int
+		body string
+	}{
+		{path: "/assets/app.js", want: http.StatusOK, body: "console.log(1)"},
+		{path: "/assets/.secret.txt", want: http.StatusNotFound},
+		{path: "/disk/disk.txt", want: http.StatusOK, body: "disk"},
+	}
+	for _, tc := range testCases {
+		w := mustServeRequest(t, h, http.MethodGet, tc.path, "", nil)
+		if w.Code != tc.want {
+			t.Errorf("GET %s got %d, want %d", tc.path, w.Code, tc.want)
+		}
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Errorf("GET %s got body %q, want %q", tc.path, w.Body.String(), tc.body)
+		}
+		if got := w.Header().Get("X-Middleware"); got != "inner" {
+			t.Errorf("GET %s got X-Middleware %q, want %q", tc.path, got, "inner")
+		}
+	}
+	if diff := cmp.Diff([]string{"outer", "inner", "outer", "inner", "outer", "inner"}, calls); diff != "" {
+		t.Errorf("middleware calls mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWebServer_HandlerRootFS(t *testing.T) {
+	ws, err := New(&Config{}, WithFS(Serve{Endpoint: "/"}, fstest.MapFS{"index.html": {Data: []byte("<p>embedded</p>")}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Shutdown(context.Background())
+	h, err := ws.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := mustServeRequest(t, h, http.MethodGet, "/", "", nil); w.Code != http.StatusOK || w.Body.String() != "<p>embedded</p>" {
+		t.Errorf("GET / got %d %q, want the embedded index.html", w.Code, w.Body.String())
+	}
+}
+
+func TestWebServer_StartShutdown(t *testing.T) {
+	ws, err := New(&Config{
+		HTTP:  HTTP{Addresses: []string{"127.0.0.1:0"}},
+		HTTPS: HTTPS{Disabled: true},
+	}, WithFS(Serve{Endpoint: "/"}, fstest.MapFS{"a.txt": {Data: []byte("a")}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ws.Addrs(); len(got) != 0 {
+		t.Errorf("Addrs() before Start got %v, want none", got)
+	}
+	if err := ws.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Start(); err == nil {
+		t.Error("want an error when starting twice")
+	}
+
+	addrs := ws.Addrs()
+	if len(addrs) != 1 {
+		t.Fatalf("Addrs() got %v, want 1 address", addrs)
+	}
+	url := fmt.Sprintf("http://%s/a.txt", addrs[0])
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "a" {
+		t.Errorf("GET %s got %q, want %q", url, body, "a")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := ws.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("want the server to be stopped after Shutdown")
+	}
+}
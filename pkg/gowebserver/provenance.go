@@ -0,0 +1,164 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+// provenance maps the YAML path of a configuration field, e.g.
+// "serve[1].source" or "https.port", to the layer the value was taken from.
+// A path also covers every field below it, e.g. "serve" covers
+// "serve[0].endpoint". Fields that are not present came from the defaults.
+type provenance map[string]string
+
+// set records the source of the field and every field below it.
+func (p provenance) set(field string, source string) {
+	if p == nil {
+		return
+	}
+	for k := range p {
+		if isFieldPrefix(field, k) {
+			delete(p, k)
+		}
+	}
+	p[field] = source
+}
+
+// source returns the source of the field from the closest recorded path.
+func (p provenance) source(field string) string {
+	for {
+		if source, ok := p[field]; ok {
+			return source
+		}
+		i := strings.LastIndexAny(field, ".[")
+		if i < 0 {
+			return sourceDefault
+		}
+		field = field[:i]
+	}
+}
+
+// setFromYAML records every field present in the YAML document. Lists are
+// recorded as a whole since decoding replaces them.
+func (p provenance) setFromYAML(doc *yaml.Node, source string) {
+	if doc == nil || len(doc.Content) == 0 {
+		return
+	}
+	var walk func(n *yaml.Node, prefix string)
+	walk = func(n *yaml.Node, prefix string) {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			field := n.Content[i].Value
+			if prefix != "" {
+				field = prefix + "." + field
+			}
+			if value := n.Content[i+1]; value.Kind == yaml.MappingNode {
+				walk(value, field)
+			} else {
+				p.set(field, source)
+			}
+		}
+	}
+	if root := doc.Content[0]; root.Kind == yaml.MappingNode {
+		walk(root, "")
+	}
+}
+
+func isFieldPrefix(prefix string, field string) bool {
+	rest, ok := strings.CutPrefix(field, prefix)
+	return ok && (rest == "" || rest[0] == '.' || rest[0] == '[')
+}
+
+// annotatedConfig returns the configuration as YAML with a comment on each
+// value naming its source.
+func annotatedConfig(conf *Config, prov provenance) (string, error) {
+	doc := &yaml.Node{}
+	if err := doc.Encode(conf); err != nil {
+		return "", fmt.Errorf("cannot encode configuration, %w", err)
+	}
+	annotateYAML(doc, "", prov)
+	doc.HeadComment = "Effective configuration, each value is annotated with its source: default, file, env or flag."
+
+	b := &strings.Builder{}
+	e := yaml.NewEncoder(b)
+	e.SetIndent(2)
+	if err := e.Encode(doc); err != nil {
+		return "", fmt.Errorf("cannot encode configuration, %w", err)
+	}
+	if err := e.Close(); err != nil {
+		return "", fmt.Errorf("cannot encode configuration, %w", err)
+	}
+	return b.String(), nil
+}
+
+func annotateYAML(n *yaml.Node, field string, prov provenance) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			child := key.Value
+			if field != "" {
+				child = field + "." + key.Value
+			}
+			if value.Kind == yaml.ScalarNode || (value.Kind == yaml.SequenceNode && !isSequenceOfMappings(value)) {
+				key.LineComment = prov.source(child)
+			} else {
+				annotateYAML(value, child, prov)
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			annotateYAML(item, fmt.Sprintf("%s[%d]", field, i), prov)
+		}
+	default:
+		for _, c := range n.Content {
+			annotateYAML(c, field, prov)
+		}
+	}
+}
+
+func isSequenceOfMappings(n *yaml.Node) bool {
+	return len(n.Content) > 0 && n.Content[0].Kind == yaml.MappingNode
+}
+
+// runPrintConfig loads the configuration and prints it annotated with the
+// source of each value. Returns the process exit code.
+func runPrintConfig(w io.Writer) int {
+	conf, prov, err := loadLayeredWithProvenance(nil, *configFileFlag, os.Environ(), flag.Visit)
+	if err != nil {
+		printConfigErrors(w, err)
+		return 1
+	}
+	out, err := annotatedConfig(conf, prov)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	fmt.Fprint(w, out)
+	return 0
+}
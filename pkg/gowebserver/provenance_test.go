@@ -0,0 +1,110 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProvenanceSource(t *testing.T) {
+	p := provenance{}
+	p.set("serve[1].source", sourceEnv)
+	p.set("https.port", sourceFile)
+	p.set("monitoring", sourceFile)
+	p.set("monitoring.trace", sourceFlag)
+
+	testCases := []struct {
+		field string
+		want  string
+	}{
+		{field: "serve[1].source", want: sourceEnv},
+		{field: "serve[1].endpoint", want: sourceDefault},
+		{field: "https.port", want: sourceFile},
+		{field: "https.portable", want: sourceDefault},
+		{field: "monitoring.debugEndpoint", want: sourceFile},
+		{field: "monitoring.trace.uri", want: sourceFlag},
+		{field: "verbose", want: sourceDefault},
+	}
+	for _, tc := range testCases {
+		if got := p.source(tc.field); got != tc.want {
+			t.Errorf("source(%q) got %q, want %q", tc.field, got, tc.want)
+		}
+	}
+
+	p.set("serve", sourceFlag)
+	if got := p.source("serve[1].source"); got != sourceFlag {
+		t.Errorf("want 'serve' to replace the sources of its fields, got %q", got)
+	}
+}
+
+func TestLoadLayeredWithProvenance(t *testing.T) {
+	fp, err := writeTempFile(`serve:
+  - source: /a
+    endpoint: /
+https:
+  port: 2
+`)
+	defer os.Remove(fp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setFlags := func(fn func(*flag.Flag)) {
+		fn(&flag.Flag{Name: "https.certificate.hosts"})
+	}
+	conf, prov, err := loadLayeredWithProvenance(nil, fp.Name(), []string{
+		"GOWEBSERVER_HTTP_PORT=10",
+		"GOWEBSERVER_SERVE_0_ENDPOINT=/pub",
+	}, setFlags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for _, field := range []string{"serve[0].source", "serve[0].endpoint", "https.port", "http.port", "https.certificate.hosts", "verbose"} {
+		got[field] = prov.source(field)
+	}
+	want := map[string]string{
+		"serve[0].source":         sourceFile,
+		"serve[0].endpoint":       sourceEnv,
+		"https.port":              sourceFile,
+		"http.port":               sourceEnv,
+		"https.certificate.hosts": sourceFlag,
+		"verbose":                 sourceDefault,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("provenance mismatch (-want +got):\n%s", diff)
+	}
+
+	out, err := annotatedConfig(conf, prov)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"  - source: /a # file\n",
+		"    endpoint: /pub # env\n",
+		"  port: 10 # env\n",
+		"verbose: false # default\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("annotated configuration does not contain %q\n%s", line, out)
+		}
+	}
+}
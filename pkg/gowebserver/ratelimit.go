@@ -0,0 +1,166 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// rateLimitSweepInterval is how often the clients whose bucket has refilled
+	// are forgotten.
+	rateLimitSweepInterval = time.Minute
+)
+
+// userContextKey is the context key of the *identity of the authenticated
+// user of a request.
+type userContextKey struct{}
+
+// requestUser returns the authenticated user of the request, empty if the
+// request is anonymous.
+func requestUser(r *http.Request) string {
+	if id, ok := r.Context().Value(userContextKey{}).(*identity); ok {
+		return id.name
+	}
+	return ""
+}
+
+// clientKey identifies the client of the request by its authenticated user or
+// its IP address.
+func clientKey(r *http.Request) string {
+	if user := requestUser(r); user != "" {
+		return "user:" + user
+	}
+	if addr := requestClientIP(r); addr.IsValid() {
+		return "ip:" + addr.String()
+	}
+	return "ip:" + r.RemoteAddr
+}
+
+// burst returns the bucket size of the limit.
+func (l RateLimit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, math.Ceil(l.RequestsPerSecond))
+}
+
+// tokenBucket holds the tokens of a single client, a request takes a token.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket per client.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{
+		rate:    limit.RequestsPerSecond,
+		burst:   limit.burst(),
+		now:     time.Now,
+		clients: map[string]*tokenBucket{},
+	}
+}
+
+// take takes a token from the client's bucket. If the bucket is empty it
+// returns how long until the next token is available.
+func (l *rateLimiter) take(client string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst}
+		l.clients[client] = b
+	} else {
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep forgets the clients whose bucket is full again, the caller must hold
+// the lock.
+func (l *rateLimiter) sweep(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for client, b := range l.clients {
+		if now.Sub(b.last) >= refill {
+			delete(l.clients, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// rateLimitHandler responds with 429 Too Many Requests to the clients that
+// exceed the rate limit.
+type rateLimitHandler struct {
+	next     http.Handler
+	limiter  *rateLimiter
+	scope    string
+	rejected metric.Int64Counter
+}
+
+// newRateLimitHandler limits the requests of each client to next, next is
+// returned as is if the limit is not set. The scope, e.g. the mount's
+// endpoint, is recorded in the rejected_requests_total metric.
+func newRateLimitHandler(next http.Handler, limit RateLimit, scope string, mc *monitoringContext) (http.Handler, error) {
+	if limit.RequestsPerSecond <= 0 {
+		return next, nil
+	}
+	rejected, err := mc.getMeterProvider().Meter("gowebserver").Int64Counter("rejected_requests_total", metric.WithDescription("Number of requests rejected for exceeding a limit."))
+	if err != nil {
+		return nil, err
+	}
+	return &rateLimitHandler{
+		next:     next,
+		limiter:  newRateLimiter(limit),
+		scope:    scope,
+		rejected: rejected,
+	}, nil
+}
+
+func (h *rateLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if wait := h.limiter.take(clientKey(r)); wait > 0 {
+		h.rejected.Add(r.Context(), 1, metric.WithAttributes(attribute.String("reason", "rate_limited"), attribute.String("scope", h.scope)))
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
@@ -0,0 +1,150 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(RateLimit{RequestsPerSecond: 2, Burst: 3})
+	clock := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return clock }
+
+	steps := []struct {
+		advance time.Duration
+		client  string
+		want    time.Duration
+	}{
+		{client: "a", want: 0},
+		{client: "a", want: 0},
+		{client: "a", want: 0},
+		{client: "a", want: 500 * time.Millisecond},
+		{client: "b", want: 0},
+		{advance: 250 * time.Millisecond, client: "a", want: 250 * time.Millisecond},
+		{advance: 250 * time.Millisecond, client: "a", want: 0},
+		{advance: time.Hour, client: "a", want: 0},
+	}
+
+	for i, step := range steps {
+		clock = clock.Add(step.advance)
+		if got := l.take(step.client); got != step.want {
+			t.Errorf("step %d: take(%q) got %s, want %s", i, step.client, got, step.want)
+		}
+	}
+
+	// Clients whose bucket has refilled are forgotten.
+	clock = clock.Add(2 * rateLimitSweepInterval)
+	l.take("c")
+	if diff := cmp.Diff([]string{"c"}, mapKeys(l.clients)); diff != "" {
+		t.Errorf("clients after sweep mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func mapKeys(m map[string]*tokenBucket) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestRateLimitBurst(t *testing.T) {
+	testCases := []struct {
+		limit RateLimit
+		want  float64
+	}{
+		{limit: RateLimit{RequestsPerSecond: 0.1}, want: 1},
+		{limit: RateLimit{RequestsPerSecond: 2.5}, want: 3},
+		{limit: RateLimit{RequestsPerSecond: 2.5, Burst: 10}, want: 10},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.limit.burst(); got != tc.want {
+			t.Errorf("%+v burst() got %g, want %g", tc.limit, got, tc.want)
+		}
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	anonymous := httptest.NewRequest(http.MethodGet, "/", nil)
+	anonymous.RemoteAddr = "[2001:db8::1]:5000"
+	user := anonymous.WithContext(context.WithValue(anonymous.Context(), userContextKey{}, &identity{name: "frank"}))
+
+	if got, want := clientKey(anonymous), "ip:2001:db8::1"; got != want {
+		t.Errorf("clientKey(anonymous) got %q, want %q", got, want)
+	}
+	if got, want := clientKey(user), "user:frank"; got != want {
+		t.Errorf("clientKey(user) got %q, want %q", got, want)
+	}
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	h, err := newRateLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), RateLimit{RequestsPerSecond: 0.5}, "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("first request got %d, want %d", w.Code, http.StatusOK)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("second request got %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After got %q, want %q", got, "2")
+	}
+}
+
+func TestWebServer_RateLimit(t *testing.T) {
+	dir := mustTempDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	baseURL, close := serveAsync(t, &Config{
+		Serve: []Serve{
+			{Source: dir, Endpoint: "/"},
+			{Source: dir, Endpoint: "/limited", RateLimit: RateLimit{RequestsPerSecond: 0.01, Burst: 2}},
+		},
+	})
+	defer close()
+
+	got := []int{}
+	for _, p := range []string{"/limited/a.txt", "/limited/a.txt", "/limited/a.txt", "/a.txt"} {
+		resp, err := http.Get(baseURL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		got = append(got, resp.StatusCode)
+	}
+	want := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusOK}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("status codes mismatch (-want +got):\n%s", diff)
+	}
+}
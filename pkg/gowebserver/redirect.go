@@ -0,0 +1,79 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// httpHandler serves the HTTP listener, redirecting to HTTPS if enabled.
+func (ws *webServerImpl) httpHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws.RLock()
+		redirect := ws.redirectToHTTPS
+		ws.RUnlock()
+		if !redirect {
+			next.ServeHTTP(w, r)
+			return
+		}
+		_, httpsPort := ws.getPorts()
+		http.Redirect(w, r, httpsURL(r, httpsPort), http.StatusPermanentRedirect)
+	})
+}
+
+// httpsURL returns the URL of the request on the HTTPS listener.
+func httpsURL(r *http.Request, httpsPort int) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		// No port in the Host header.
+		host = strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
+	}
+	if httpsPort != 443 && httpsPort != 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return "https://" + host + r.URL.RequestURI()
+}
+
+// hstsHandler adds the Strict-Transport-Security header to HTTPS responses.
+func (ws *webServerImpl) hstsHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws.RLock()
+		hsts := ws.hsts
+		ws.RUnlock()
+		if value := hsts.headerValue(); value != "" && r.TLS != nil {
+			w.Header().Set("Strict-Transport-Security", value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// headerValue returns the Strict-Transport-Security header value, empty if
+// HSTS is disabled.
+func (h HSTS) headerValue() string {
+	if h.MaxAge <= 0 {
+		return ""
+	}
+	value := fmt.Sprintf("max-age=%d", int64(h.MaxAge.Seconds()))
+	if h.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	return value
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPSURL(t *testing.T) {
+	testCases := []struct {
+		target    string
+		host      string
+		httpsPort int
+		want      string
+	}{
+		{target: "/a/b?c=d", host: "example.com", httpsPort: 443, want: "https://example.com/a/b?c=d"},
+		{target: "/", host: "example.com:8080", httpsPort: 8443, want: "https://example.com:8443/"},
+		{target: "/x", host: "[::1]:8080", httpsPort: 443, want: "https://[::1]/x"},
+		{target: "/x", host: "[::1]", httpsPort: 8443, want: "https://[::1]:8443/x"},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		r.Host = tc.host
+		if got := httpsURL(r, tc.httpsPort); got != tc.want {
+			t.Errorf("httpsURL(%q, %q, %d) got %q, want %q", tc.host, tc.target, tc.httpsPort, got, tc.want)
+		}
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	ws := &webServerImpl{redirectToHTTPS: true, httpsListenPort: 8443}
+	h := ws.httpHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request was not redirected")
+	}))
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "http://example.com:8080/upload.asp?x=1", nil)
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusPermanentRedirect {
+		t.Errorf("want status %d, got %d", http.StatusPermanentRedirect, rec.Code)
+	}
+	if got, want := rec.Header().Get("Location"), "https://example.com:8443/upload.asp?x=1"; got != want {
+		t.Errorf("want Location %q, got %q", want, got)
+	}
+}
+
+func TestHSTSHandler(t *testing.T) {
+	testCases := []struct {
+		name string
+		hsts HSTS
+		tls  bool
+		want string
+	}{
+		{name: "disabled", hsts: HSTS{}, tls: true, want: ""},
+		{name: "enabled", hsts: HSTS{MaxAge: 24 * time.Hour}, tls: true, want: "max-age=86400"},
+		{name: "subdomains", hsts: HSTS{MaxAge: time.Hour, IncludeSubdomains: true}, tls: true, want: "max-age=3600; includeSubDomains"},
+		{name: "not TLS", hsts: HSTS{MaxAge: time.Hour}, tls: false, want: ""},
+	}
+	for _, tc := range testCases {
+		ws := &webServerImpl{hsts: tc.hsts}
+		h := ws.hstsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if got := rec.Header().Get("Strict-Transport-Security"); got != tc.want {
+			t.Errorf("%s: got Strict-Transport-Security %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWebServer_HTTPSDisabled(t *testing.T) {
+	baseURL, close := serveAsync(t, &Config{
+		Serve: []Serve{{Source: mustTempDir(t), Endpoint: "/"}},
+		HTTPS: HTTPS{Disabled: true},
+	})
+	defer close()
+
+	if got := mustGetBody(t, baseURL+"/"); got == "" {
+		t.Error("want a response from the HTTP listener")
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	// configReloadDebounce coalesces the burst of file system events that
+	// editors and config management tools emit when saving a file.
+	configReloadDebounce = 250 * time.Millisecond
+)
+
+// handlerGeneration is one build of the serving handler tree along with the
+// resources it owns.
+type handlerGeneration struct {
+	handler  http.Handler
+	cleanup  func()
+	inflight sync.WaitGroup
+}
+
+// retire waits for all in-flight requests of the generation to finish before
+// releasing its resources.
+func (g *handlerGeneration) retire() {
+	g.inflight.Wait()
+	g.cleanup()
+}
+
+// swappableHandler routes requests to the current handler generation and
+// allows a new generation to replace it without interrupting the requests that
+// are still being served by the previous one.
+type swappableHandler struct {
+	mu      sync.RWMutex
+	current *handlerGeneration
+}
+
+func (s *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	g := s.current
+	if g == nil {
+		s.mu.RUnlock()
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	g.inflight.Add(1)
+	s.mu.RUnlock()
+
+	defer g.inflight.Done()
+	g.handler.ServeHTTP(w, r)
+}
+
+// swap installs a new handler generation. The previous generation is retired
+// in the background once its in-flight requests complete.
+func (s *swappableHandler) swap(handler http.Handler, cleanup func()) {
+	next := &handlerGeneration{
+		handler: handler,
+		cleanup: cleanup,
+	}
+	s.mu.Lock()
+	prev := s.current
+	s.current = next
+	s.mu.Unlock()
+
+	if prev != nil {
+		go prev.retire()
+	}
+}
+
+// loaded reports whether a handler generation is installed.
+func (s *swappableHandler) loaded() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current != nil
+}
+
+// close retires the current generation, releasing its resources once its
+// in-flight requests have completed.
+func (s *swappableHandler) close() {
+	s.mu.Lock()
+	g := s.current
+	s.current = nil
+	s.mu.Unlock()
+	if g != nil {
+		g.retire()
+	}
+}
+
+// watchConfig reloads the server configuration whenever the configuration
+// file changes or the process receives a reload signal (SIGHUP).
+func (ws *webServerImpl) watchConfig() (func(), error) {
+	configFile, err := filepath.Abs(ws.configurationFile)
+	if err != nil {
+		return nilFunc, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nilFunc, err
+	}
+	// Watch the directory rather than the file so that atomic saves, which
+	// replace the file with a new inode, continue to be observed.
+	if err := watcher.Add(filepath.Dir(configFile)); err != nil {
+		watcher.Close()
+		return nilFunc, err
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	stopSignals := notifyReloadSignal(sigCh)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var debounce *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				if debounce != nil {
+					debounce.Stop()
+				}
+				return
+			case <-sigCh:
+				zap.S().With("configFile", configFile).Info("Reload signal received")
+				ws.reloadAndReport("signal")
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != configFile {
+					continue
+				}
+				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(configReloadDebounce, func() {
+					ws.reloadAndReport("file")
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				zap.S().With("error", err, "configFile", configFile).Warn("configuration file watch error")
+			}
+		}
+	}()
+
+	zap.S().With("configFile", configFile).Info("Watching configuration file for changes")
+	return func() {
+		stopSignals()
+		cancel()
+		watcher.Close()
+		<-done
+		// A debounced reload can still be running.
+		ws.reloadMu.Lock()
+		ws.reloadStopped = true
+		ws.reloadMu.Unlock()
+	}, nil
+}
+
+// reloadAndReport reloads the configuration and records the outcome in the
+// logs and the config_reloads_total metric.
+func (ws *webServerImpl) reloadAndReport(trigger string) error {
+	ws.reloadMu.Lock()
+	defer ws.reloadMu.Unlock()
+	if ws.reloadStopped {
+		return fmt.Errorf("cannot reload the configuration, the server is stopping")
+	}
+	reloadErr := ws.reload()
+
+	result := "success"
+	if reloadErr != nil {
+		result = "failure"
+		zap.S().With("error", reloadErr, "configFile", ws.configurationFile, "trigger", trigger).Error("Configuration reload failed, keeping the previous configuration")
+	} else {
+		zap.S().With("configFile", ws.configurationFile, "trigger", trigger).Info("Configuration reloaded")
+	}
+
+	if mc := ws.currentMonitoring(); mc != nil && mc.configReloads != nil {
+		mc.configReloads.Add(context.Background(), 1, metric.WithAttributes(attribute.String("result", result), attribute.String("trigger", trigger)))
+	}
+	return reloadErr
+}
+
+// reload reads the configuration file again on top of the configuration given
+// to New and atomically replaces the serving handler tree. The listeners are
+// kept open and the previous handler tree remains active if the new
+// configuration cannot be applied.
+func (ws *webServerImpl) reload() error {
+	configFile := ws.configurationFile
+	conf, err := loadAndValidate(ws.baseConfig, configFile)
+	if err != nil {
+		return err
+	}
+
+	next, err := newWebServer(conf, append(slices.Clone(ws.opts), withMonitoringContext(ws.currentMonitoring()))...)
+	if err != nil {
+		return err
+	}
+	next.killFunc = ws.killFunc
+	next.server = ws
+
+	// A failed build releases the monitoring context, which shuts it down
+	// unless the running generation shares it.
+	handler, cleanup, err := next.buildHandler()
+	if err != nil {
+		return err
+	}
+
+	if !slices.Equal(next.httpListen, ws.httpListen) || !slices.Equal(next.httpsListen, ws.httpsListen) || next.certificateFilePath != ws.certificateFilePath || next.privateKeyFilePath != ws.privateKeyFilePath || !next.limits.serverSettingsEqual(ws.limits) || next.httpDisabled != ws.httpDisabled || next.httpsDisabled != ws.httpsDisabled || next.http3Enabled != ws.http3Enabled || next.clientAuth.Mode != ws.clientAuth.Mode || next.clientAuth.CAFile != ws.clientAuth.CAFile {
+		zap.S().With("configFile", configFile).Warn("Listener, certificate, client certificate, timeout and connection limit changes require a restart to take effect")
+	}
+	if !slices.Equal(next.adminListen, ws.adminListen) {
+		zap.S().With("configFile", configFile).Warn("Admin API address changes require a restart to take effect")
+	}
+
+	ws.Lock()
+	ws.monitoringCtx = next.monitoringCtx
+	ws.metricsEnabled = next.metricsEnabled
+	ws.metricsServePath = next.metricsServePath
+	ws.fileSystemServePath = next.fileSystemServePath
+	ws.uploadPath = next.uploadPath
+	ws.uploadHTTPPath = next.uploadHTTPPath
+	ws.enhancedListMode = next.enhancedListMode
+	ws.verbose = next.verbose
+	ws.drainTimeout = next.drainTimeout
+	ws.limits = next.limits
+	ws.redirectToHTTPS = next.redirectToHTTPS
+	ws.hsts = next.hsts
+	ws.cors = next.cors
+	ws.accessLog = next.accessLog
+	ws.rateLimit = next.rateLimit
+	ws.uploadRateLimit = next.uploadRateLimit
+	ws.ipFilter = next.ipFilter
+	ws.uploadIPFilter = next.uploadIPFilter
+	ws.metricsIPFilter = next.metricsIPFilter
+	ws.debugIPFilter = next.debugIPFilter
+	ws.clientIP = next.clientIP
+	ws.bandwidth = next.bandwidth
+	ws.auth = next.auth
+	ws.clientAuth = next.clientAuth
+	ws.uploadAccess = next.uploadAccess
+	ws.admin = next.admin
+	ws.compression = next.compression
+	ws.Unlock()
+
+	ws.handler.swap(handler, cleanup)
+	return nil
+}
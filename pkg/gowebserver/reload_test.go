@@ -0,0 +1,218 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSwappableHandler(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var oldCleanedUp atomic.Bool
+
+	s := &swappableHandler{}
+	s.swap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("old"))
+	}), func() {
+		oldCleanedUp.Store(true)
+	})
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	oldResp := make(chan string)
+	go func() {
+		oldResp <- mustGetBody(t, ts.URL)
+	}()
+	<-started
+
+	s.swap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new"))
+	}), nilFunc)
+
+	if got := mustGetBody(t, ts.URL); got != "new" {
+		t.Errorf("want 'new' from the swapped handler, got %q", got)
+	}
+	if oldCleanedUp.Load() {
+		t.Error("previous handler was cleaned up while a request was in-flight")
+	}
+
+	close(release)
+	if got := <-oldResp; got != "old" {
+		t.Errorf("want 'old' from the in-flight request, got %q", got)
+	}
+
+	for i := 0; i < 50 && !oldCleanedUp.Load(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !oldCleanedUp.Load() {
+		t.Error("previous handler was not cleaned up after the in-flight request completed")
+	}
+}
+
+func TestSwappableHandlerEmpty(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&swappableHandler{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("want status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
+
+func TestWebServer_ReloadOnConfigChange(t *testing.T) {
+	first := mustTempDir(t)
+	second := mustTempDir(t)
+	if err := os.WriteFile(filepath.Join(first, "hello.txt"), []byte("first"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(second, "hello.txt"), []byte("second"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configFile := filepath.Join(mustTempDir(t), "gowebserver.yaml")
+	writeConfig := func(content string) {
+		if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	serveConfig := func(source string) string {
+		return fmt.Sprintf("serve:\n  - source: %s\n    endpoint: /\n", source)
+	}
+	writeConfig(serveConfig(first))
+
+	baseURL, close := serveAsync(t, &Config{
+		Serve:             []Serve{{Source: first, Endpoint: "/"}},
+		ConfigurationFile: configFile,
+	})
+	defer close()
+
+	if got := mustGetBody(t, baseURL+"/hello.txt"); got != "first" {
+		t.Fatalf("want 'first', got %q", got)
+	}
+
+	writeConfig(serveConfig(second))
+	waitForBody(t, baseURL+"/hello.txt", "second")
+
+	// A broken configuration must keep the previous one running.
+	writeConfig("serve: [")
+	time.Sleep(configReloadDebounce * 4)
+	if got := mustGetBody(t, baseURL+"/hello.txt"); got != "second" {
+		t.Errorf("want 'second' after a failed reload, got %q", got)
+	}
+}
+
+func TestWebServer_ReloadKeepsConfigFromCode(t *testing.T) {
+	dir := mustTempDir(t)
+	configFile := filepath.Join(mustTempDir(t), "gowebserver.yaml")
+	if err := os.WriteFile(configFile, []byte(fmt.Sprintf("serve:\n  - source: %s\n    endpoint: /\nenhancedList: true\n", dir)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := &Config{
+		Serve:             []Serve{{Source: dir, Endpoint: "/"}},
+		Shutdown:          Shutdown{DrainTimeout: 5 * time.Second},
+		ConfigurationFile: configFile,
+	}
+	ws, _, close := serveAsyncServer(t, conf)
+	defer close()
+
+	if err := ws.reloadAndReport("test"); err != nil {
+		t.Fatal(err)
+	}
+	ws.RLock()
+	drainTimeout, enhancedList := ws.drainTimeout, ws.enhancedListMode
+	ws.RUnlock()
+	if drainTimeout != 5*time.Second {
+		t.Errorf("drain timeout set in code got %s after a reload, want %s", drainTimeout, 5*time.Second)
+	}
+	if !enhancedList {
+		t.Error("want the enhanced list of the configuration file after a reload")
+	}
+	if conf.EnhancedList {
+		t.Error("the reload modified the configuration given to New")
+	}
+}
+
+func TestWebServer_ReloadKeepsMetrics(t *testing.T) {
+	dir := mustTempDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(mustTempDir(t), "gowebserver.yaml")
+	config := fmt.Sprintf("serve:\n  - source: %s\n    endpoint: /\nmonitoring:\n  metrics:\n    enabled: true\n    path: /metrics\n", dir)
+	if err := os.WriteFile(configFile, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ws, baseURL, close := serveAsyncServer(t, &Config{
+		Serve:             []Serve{{Source: dir, Endpoint: "/"}},
+		Monitoring:        Monitoring{Metrics: Metrics{Enabled: true, Path: "/metrics"}},
+		ConfigurationFile: configFile,
+	})
+	defer close()
+
+	for i := 0; i < 2; i++ {
+		if err := ws.reloadAndReport("test"); err != nil {
+			t.Fatal(err)
+		}
+		// Let the previous generation retire.
+		time.Sleep(100 * time.Millisecond)
+		if got := mustGetBody(t, baseURL+"/hello.txt"); got != "hello" {
+			t.Fatalf("want 'hello', got %q", got)
+		}
+		metrics := mustGetBody(t, baseURL+"/metrics")
+		for _, want := range []string{"config_reloads_total", "http_server_request"} {
+			if !strings.Contains(metrics, want) {
+				t.Errorf("reload %d: /metrics does not contain %s", i+1, want)
+			}
+		}
+	}
+}
+
+func mustGetBody(tb testing.TB, url string) string {
+	resp, err := http.Get(url)
+	if err != nil {
+		tb.Error(err)
+		return ""
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		tb.Error(err)
+	}
+	return string(data)
+}
+
+func waitForBody(tb testing.TB, url string, want string) {
+	got := ""
+	for i := 0; i < 50; i++ {
+		got = mustGetBody(tb, url)
+		if got == want {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	tb.Errorf("want %q from '%s', got %q", want, url, got)
+}
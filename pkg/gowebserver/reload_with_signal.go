@@ -0,0 +1,30 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !(plan9 || js || wasip1)
+
+package gowebserver
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func notifyReloadSignal(ch chan<- os.Signal) func() {
+	signal.Notify(ch, syscall.SIGHUP)
+	return func() {
+		signal.Stop(ch)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	// requestIDAttribute is the span attribute of the request ID.
+	requestIDAttribute = "http.request.id"
+	// maxRequestIDLength bounds the length of the request IDs that clients
+	// and proxies send.
+	maxRequestIDLength = 128
+)
+
+// requestIDKey is the context key of the request ID.
+type requestIDKey struct{}
+
+// newRequestID returns a random 128-bit request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether a request ID from the client can be used as
+// is, it must be printable ASCII without spaces so that it cannot forge log
+// lines or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= 0x20 || id[i] >= 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the request ID of the request's context, empty if there
+// is none.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestLogger returns the logger of a request, it logs the request ID.
+func requestLogger(ctx context.Context) *zap.SugaredLogger {
+	if id := requestID(ctx); id != "" {
+		return zap.S().With("requestId", id)
+	}
+	return zap.S()
+}
+
+// requestIDHandler gives every request an ID, the one in the X-Request-ID
+// header if it is valid or a new one. The ID is stored in the context, sent
+// to reverse proxied servers and echoed in the response.
+func requestIDHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		r.Header.Set(requestIDHeader, id)
+		w.Header().Set(requestIDHeader, id)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// setSpanRequestID records the request ID of the context on the span.
+func setSpanRequestID(ctx context.Context, span trace.Span) {
+	if id := requestID(ctx); id != "" {
+		span.SetAttributes(attribute.String(requestIDAttribute, id))
+	}
+}
+
+// withRequestIDSpan records the request ID on the span of the requests served
+// by h.
+func withRequestIDSpan(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setSpanRequestID(r.Context(), trace.SpanFromContext(r.Context()))
+		h.ServeHTTP(w, r)
+	})
+}
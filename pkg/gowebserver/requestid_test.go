@@ -0,0 +1,112 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var generatedRequestID = regexp.MustCompile("^[0-9a-f]{32}$")
+
+func TestRequestIDHandler(t *testing.T) {
+	testCases := []struct {
+		name     string
+		incoming string
+		want     string
+	}{
+		{name: "generated", incoming: ""},
+		{name: "accepted", incoming: "abc-123", want: "abc-123"},
+		{name: "spaces", incoming: "abc 123"},
+		{name: "control", incoming: "abc\x01"},
+		{name: "too long", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ctxID, headerID string
+			h := requestIDHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID = requestID(r.Context())
+				headerID = r.Header.Get(requestIDHeader)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.incoming != "" {
+				r.Header.Set(requestIDHeader, tc.incoming)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			got := w.Header().Get(requestIDHeader)
+			if tc.want != "" && got != tc.want {
+				t.Errorf("response ID got %q, want %q", got, tc.want)
+			}
+			if tc.want == "" && !generatedRequestID.MatchString(got) {
+				t.Errorf("response ID got %q, want a generated ID", got)
+			}
+			if ctxID != got || headerID != got {
+				t.Errorf("context ID %q and request header ID %q, want %q", ctxID, headerID, got)
+			}
+		})
+	}
+}
+
+func TestWebServer_RequestID(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(requestIDHeader, "backend-id")
+		io.WriteString(w, r.Header.Get(requestIDHeader))
+	}))
+	defer backend.Close()
+
+	baseURL, close := serveAsync(t, &Config{
+		Serve: []Serve{{Source: backend.URL, Endpoint: "/proxy"}},
+	})
+	defer close()
+
+	get := func(id string) (string, []string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, baseURL+"/proxy/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "" {
+			req.Header.Set(requestIDHeader, id)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.Header.Values(requestIDHeader)
+	}
+
+	body, got := get("client-id")
+	if diff := cmp.Diff([]string{"client-id"}, got); diff != "" {
+		t.Errorf("response IDs mismatch (-want +got):\n%s", diff)
+	}
+	if body != "client-id" {
+		t.Errorf("proxied request ID got %q, want %q", body, "client-id")
+	}
+
+	body, got = get("")
+	if len(got) != 1 || !generatedRequestID.MatchString(got[0]) || body != got[0] {
+		t.Errorf("got response IDs %v and proxied ID %q, want the same generated ID", got, body)
+	}
+}
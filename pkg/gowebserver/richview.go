@@ -23,12 +23,14 @@ import (
 	"net/http"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/chroma/v2"
 	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
 	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/alecthomas/chroma/v2/styles"
 	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 const (
@@ -98,9 +100,11 @@ func (h *richViewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	fsPath := cleanPath(strings.TrimPrefix(r.URL.Path, "/"))
+	logger := requestLogger(r.Context()).With("path", fsPath)
 
 	f, err := h.baseFS.Open(fsPath)
 	if err != nil {
+		logger.With("error", err).Warn("cannot render rich view")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -108,6 +112,7 @@ func (h *richViewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	stat, err := f.Stat()
 	if err != nil {
+		logger.With("error", err).Warn("cannot render rich view")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -119,6 +124,7 @@ func (h *richViewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	content, err := io.ReadAll(io.LimitReader(f, int64(richViewMaxFileSize)+1))
 	if err != nil {
+		logger.With("error", err).Warn("cannot render rich view")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -141,8 +147,7 @@ func (h *richViewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			ApplicationVersion: version,
 			Oversized:          true,
 		}
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		h.tmpl.Execute(w, report) //nolint:errcheck
+		h.serve(w, r, logger, report, stat.ModTime())
 		return
 	}
 
@@ -179,18 +184,21 @@ func (h *richViewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	var cssBuilder strings.Builder
 	if err := formatter.WriteCSS(&cssBuilder, style); err != nil {
+		logger.With("error", err).Warn("cannot render rich view")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	iterator, err := lexer.Tokenise(nil, contentStr)
 	if err != nil {
+		logger.With("error", err).Warn("cannot render rich view")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	var htmlBuf bytes.Buffer
 	if err := formatter.Format(&htmlBuf, style, iterator); err != nil {
+		logger.With("error", err).Warn("cannot render rich view")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -213,6 +221,20 @@ func (h *richViewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ApplicationVersion: version,
 	}
 
+	logger.With("language", language, "theme", themeName).Debug("richViewHandler")
+	h.serve(w, r, logger, report, stat.ModTime())
+}
+
+// serve renders the rich view, it is revalidated with the ETag of the page
+// or the modification time of the file.
+func (h *richViewHandler) serve(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, report *RichViewReport, modTime time.Time) {
+	body := &bytes.Buffer{}
+	if err := h.tmpl.Execute(body, report); err != nil {
+		logger.With("error", err).Warn("cannot execute rich view template")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	h.tmpl.Execute(w, report) //nolint:errcheck
+	setSecureHeaders(w.Header())
+	serveGenerated(w, r, body.Bytes(), modTime)
 }
@@ -0,0 +1,122 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+const (
+	jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+	// durationPattern matches the Go duration format, e.g. 1h30m or 250ms.
+	durationPattern = `^-?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+)
+
+// configJSONSchema returns the JSON Schema of the YAML configuration file so
+// that editors can validate and autocomplete it.
+func configJSONSchema() ([]byte, error) {
+	descriptions := map[string]string{}
+	for name, override := range flagOverrides {
+		if f := flag.Lookup(name); f != nil {
+			descriptions[override.field] = f.Usage
+		}
+	}
+
+	schema := jsonSchemaForType(reflect.TypeOf(Config{}), "", descriptions)
+	schema["$schema"] = jsonSchemaDialect
+	schema["title"] = "gowebserver configuration"
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode JSON schema, %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func jsonSchemaForType(t reflect.Type, field string, descriptions map[string]string) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	schema := map[string]any{}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map:
+	default:
+		// Flags that set a whole section, e.g. -path for serve, do not describe it.
+		if description, ok := descriptions[field]; ok {
+			schema["description"] = description
+		}
+	}
+	if t == durationType {
+		schema["type"] = "string"
+		schema["pattern"] = durationPattern
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]any{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := yamlFieldName(f)
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			childField := name
+			if field != "" {
+				childField = field + "." + name
+			}
+			properties[name] = jsonSchemaForType(f.Type, childField, descriptions)
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+	case reflect.Slice:
+		schema["type"] = "array"
+		schema["items"] = jsonSchemaForType(t.Elem(), field+"[]", descriptions)
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = jsonSchemaForType(t.Elem(), field+".*", descriptions)
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		schema["type"] = "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema["type"] = "integer"
+		schema["minimum"] = 0
+	case reflect.Float32, reflect.Float64:
+		schema["type"] = "number"
+	}
+	return schema
+}
+
+// runConfigSchema prints the JSON Schema of the configuration file and returns
+// the process exit code.
+func runConfigSchema(w io.Writer) int {
+	data, err := configJSONSchema()
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 1
+	}
+	if _, err := w.Write(data); err != nil {
+		return 1
+	}
+	return 0
+}
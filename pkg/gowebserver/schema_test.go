@@ -0,0 +1,69 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfigJSONSchema(t *testing.T) {
+	data, err := configJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := map[string]any{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	properties := schema["properties"].(map[string]any)
+	for _, name := range []string{"verbose", "serve", "enhancedList", "http", "https", "monitoring", "upload"} {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("schema does not have property %q", name)
+		}
+	}
+	if _, ok := properties["configurationfile"]; ok {
+		t.Error("schema has the unserialized configurationfile property")
+	}
+
+	serve := properties["serve"].(map[string]any)
+	serveItem := serve["items"].(map[string]any)
+	if diff := cmp.Diff("boolean", serveItem["properties"].(map[string]any)["readOnly"].(map[string]any)["type"]); diff != "" {
+		t.Errorf("serve[].readOnly type mismatch (-want +got):\n%s", diff)
+	}
+
+	duration := properties["https"].(map[string]any)["properties"].(map[string]any)["certificate"].(map[string]any)["properties"].(map[string]any)["duration"].(map[string]any)
+	if duration["type"] != "string" || duration["pattern"] != durationPattern {
+		t.Errorf("https.certificate.duration want a duration string, got %v", duration)
+	}
+}
+
+func TestConfigJSONSchemaIsUpToDate(t *testing.T) {
+	want, err := configJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../../install/gowebserver.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("install/gowebserver.schema.json is out of date, run 'make schema' (-want +got):\n%s", diff)
+	}
+}
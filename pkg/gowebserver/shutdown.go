@@ -0,0 +1,166 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultDrainTimeout is how long in-flight requests may run after shutdown
+	// begins when Shutdown.DrainTimeout is not set.
+	defaultDrainTimeout = 30 * time.Second
+	// maxInterruptedLogged limits the number of interrupted requests listed in
+	// the shutdown summary.
+	maxInterruptedLogged = 10
+)
+
+// inflightRequest describes a request that is being served.
+type inflightRequest struct {
+	method string
+	path   string
+	remote string
+	start  time.Time
+}
+
+// requestTracker keeps account of the requests that are being served so that
+// shutdown can report what it interrupted.
+type requestTracker struct {
+	mu       sync.Mutex
+	next     uint64
+	requests map[uint64]inflightRequest
+}
+
+func newRequestTracker() *requestTracker {
+	return &requestTracker{
+		requests: map[uint64]inflightRequest{},
+	}
+}
+
+func (t *requestTracker) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.mu.Lock()
+		id := t.next
+		t.next++
+		t.requests[id] = inflightRequest{
+			method: r.Method,
+			path:   r.URL.Path,
+			remote: r.RemoteAddr,
+			start:  time.Now(),
+		}
+		t.mu.Unlock()
+
+		defer func() {
+			t.mu.Lock()
+			delete(t.requests, id)
+			t.mu.Unlock()
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// inflight returns the requests being served, oldest first.
+func (t *requestTracker) inflight() []inflightRequest {
+	t.mu.Lock()
+	result := make([]inflightRequest, 0, len(t.requests))
+	for _, r := range t.requests {
+		result = append(result, r)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].start.Before(result[j].start)
+	})
+	return result
+}
+
+// onceCloseListener allows the listener to be closed early, e.g. when the
+// shutdown begins, before http.Server.Shutdown closes it again.
+type onceCloseListener struct {
+	net.Listener
+	once sync.Once
+	err  error
+}
+
+func (l *onceCloseListener) Close() error {
+	l.once.Do(func() {
+		l.err = l.Listener.Close()
+	})
+	return l.err
+}
+
+// shutdownServer is a server that can be drained, e.g. http.Server and
+// http3.Server.
+type shutdownServer interface {
+	Shutdown(ctx context.Context) error
+	Close() error
+}
+
+// drain gracefully shuts down the servers. New connections are refused right
+// away, in-flight requests are given until the drain timeout or until ctx is
+// done to complete and the connections of the remaining ones are closed.
+func (ws *webServerImpl) drain(ctx context.Context, servers []shutdownServer) {
+	ws.RLock()
+	timeout := ws.drainTimeout
+	ws.RUnlock()
+
+	start := time.Now()
+	pending := len(ws.requests.inflight())
+	zap.S().With("inflight", pending, "timeout", timeout).Info("Shutting down, draining in-flight requests")
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv shutdownServer) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				zap.S().With("error", err).Warn("error shutting down server")
+			}
+		}(srv)
+	}
+	wg.Wait()
+
+	interrupted := ws.requests.inflight()
+	if len(interrupted) > 0 {
+		for _, srv := range servers {
+			srv.Close()
+		}
+	}
+
+	summary := zap.S().With("duration", time.Since(start), "drained", max(pending-len(interrupted), 0), "interrupted", len(interrupted))
+	if len(interrupted) == 0 {
+		summary.Info("Shutdown complete")
+		return
+	}
+	requests := []string{}
+	for i, r := range interrupted {
+		if i == maxInterruptedLogged {
+			break
+		}
+		requests = append(requests, r.method+" "+r.path+" from "+r.remote+" running for "+time.Since(r.start).Round(time.Millisecond).String())
+	}
+	summary.With("requests", requests).Warn("Shutdown complete, drain timeout exceeded and in-flight requests were interrupted")
+}
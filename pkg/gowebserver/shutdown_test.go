@@ -0,0 +1,121 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// startDrainTestServer serves h through a requestTracker on a new listener and
+// returns the server and its URL.
+func startDrainTestServer(t *testing.T, ws *webServerImpl, h http.Handler) (*http.Server, string) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: ws.requests.wrap(h)}
+	go srv.Serve(lis)
+	return srv, "http://" + lis.Addr().String()
+}
+
+func TestDrainWaitsForInflightRequests(t *testing.T) {
+	ws := &webServerImpl{drainTimeout: 10 * time.Second, requests: newRequestTracker()}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv, baseURL := startDrainTestServer(t, ws, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("complete"))
+	}))
+
+	body := make(chan string)
+	go func() {
+		body <- mustGetBody(t, baseURL)
+	}()
+	<-started
+
+	drained := make(chan struct{})
+	go func() {
+		ws.drain(context.Background(), []shutdownServer{srv})
+		close(drained)
+	}()
+
+	// Wait for the listener to close, new connections must be refused while
+	// draining.
+	refused := false
+	for i := 0; i < 50 && !refused; i++ {
+		conn, err := net.Dial("tcp", baseURL[len("http://"):])
+		if err != nil {
+			refused = true
+		} else {
+			conn.Close()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if !refused {
+		t.Error("new connections are accepted while draining")
+	}
+
+	select {
+	case <-drained:
+		t.Fatal("drain completed before the in-flight request finished")
+	default:
+	}
+
+	close(release)
+	if got := <-body; got != "complete" {
+		t.Errorf("want 'complete' from the in-flight request, got %q", got)
+	}
+	<-drained
+	if got := len(ws.requests.inflight()); got != 0 {
+		t.Errorf("want no in-flight requests after draining, got %d", got)
+	}
+}
+
+func TestDrainInterruptsAfterTimeout(t *testing.T) {
+	ws := &webServerImpl{drainTimeout: 100 * time.Millisecond, requests: newRequestTracker()}
+	started := make(chan struct{})
+	srv, baseURL := startDrainTestServer(t, ws, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(started)
+		<-r.Context().Done()
+	}))
+
+	resp, err := http.Get(baseURL + "/stuck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	<-started
+
+	if got := ws.requests.inflight(); len(got) != 1 || got[0].path != "/stuck" {
+		t.Errorf("want the /stuck request in-flight, got %+v", got)
+	}
+
+	start := time.Now()
+	ws.drain(context.Background(), []shutdownServer{srv})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("drain took %s, want about the drain timeout", elapsed)
+	}
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Error("want the interrupted response to fail")
+	}
+}
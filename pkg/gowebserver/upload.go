@@ -19,6 +19,7 @@ package gowebserver
 import (
 	"crypto/md5"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -32,6 +33,7 @@ import (
 
 	_ "embed"
 
+	"github.com/rs/cors"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
@@ -55,6 +57,8 @@ type uploadHTTPHandler struct {
 	uploadedBytesTotal metric.Int64Counter
 	uploadedFilesTotal metric.Int64Counter
 	tmpl               *template.Template
+	maxMemory          int64
+	trustedOrigins     *cors.Cors
 }
 
 type uploadResponse struct {
@@ -71,7 +75,8 @@ func (uh *uploadHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx, span := uploadTracer.Start(r.Context(), r.Method)
 	defer span.End()
 
-	logger := zap.S().With("url", r.URL)
+	setSpanRequestID(ctx, span)
+	logger := requestLogger(ctx).With("url", r.URL)
 
 	if r.Method == "GET" {
 		crutime := time.Now().Unix()
@@ -86,20 +91,30 @@ func (uh *uploadHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			ApplicationVersion string
 		}{uh.uploadHTTPPath, token, uploadFileFormName, version}
 
+		setSecureHeaders(w.Header())
 		if err := uh.tmpl.Execute(w, params); err != nil {
 			logger.With("error", err).Error("cannot execute upload.html template.")
 		}
 	} else {
 		var resp uploadResponse
 
-		if !isSameOrigin(r) {
+		// Cross-origin uploads are only accepted from origins that the CORS
+		// policy lists explicitly.
+		if !isSameOrigin(r) && (uh.trustedOrigins == nil || !uh.trustedOrigins.OriginAllowed(r)) {
 			resp.Error = fmt.Errorf("Forbidden: cross-origin upload requests are not allowed")
 			writeUploadResponse(w, resp, http.StatusForbidden, logger, span)
 			return
 		}
 
 		ctx, childSpan := uploadTracer.Start(ctx, "ParseMultipartForm")
-		if err := r.ParseMultipartForm(32 << 20); err != nil {
+		if err := r.ParseMultipartForm(uh.maxMemory); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				resp.Error = fmt.Errorf("RequestEntityTooLarge: upload exceeds the limit of %d bytes", maxBytesErr.Limit)
+				writeUploadResponse(w, resp, http.StatusRequestEntityTooLarge, logger, childSpan)
+				childSpan.End()
+				return
+			}
 			resp.Error = fmt.Errorf("InternalError: cannot parse multi-part form")
 			writeUploadResponse(w, resp, http.StatusBadRequest, logger, childSpan)
 			childSpan.End()
@@ -156,7 +171,7 @@ func (uh *uploadHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func newUploadHandler(mc *monitoringContext, uploadHTTPPath string, uploadDirectory string) (http.Handler, error) {
+func newUploadHandler(mc *monitoringContext, uploadHTTPPath string, uploadDirectory string, maxMemory int64, corsPolicy CORS) (http.Handler, error) {
 	m := mc.getMeterProvider().Meter(uploadDirectory)
 
 	uploadedBytesTotal, err := m.Int64Counter("uploaded_bytes_total", metric.WithDescription("Number of bytes uploaded."), metric.WithUnit("bytes"))
@@ -178,6 +193,8 @@ func newUploadHandler(mc *monitoringContext, uploadHTTPPath string, uploadDirect
 		uploadedBytesTotal: uploadedBytesTotal,
 		uploadedFilesTotal: uploadedFilesTotal,
 		tmpl:               tmpl,
+		maxMemory:          maxMemory,
+		trustedOrigins:     corsPolicy.trustedOrigins(),
 	}, nil
 }
 
@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -156,6 +157,62 @@ func TestUpload_CrossOriginRejected(t *testing.T) {
 	}
 }
 
+func TestUpload_CrossOriginTrusted(t *testing.T) {
+	tmpDir, close, err := createTempDirectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close()
+
+	cfg := &Config{
+		Serve: []Serve{
+			{
+				Source:   tmpDir,
+				Endpoint: "/",
+			},
+		},
+		Upload: Serve{
+			Source:   tmpDir,
+			Endpoint: "/upload",
+		},
+		CORS: CORS{
+			AllowedOrigins: []string{"https://*.example.com"},
+		},
+	}
+
+	baseURL, close := serveAsync(t, cfg)
+	defer close()
+
+	testCases := []struct {
+		origin   string
+		fileName string
+		want     int
+	}{
+		{origin: "https://app.example.com", fileName: "trusted.txt", want: http.StatusOK},
+		{origin: "https://evil.example.org", fileName: "untrusted.txt", want: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		req, err := newUploadFormRequest(context.Background(), baseURL+"/upload", tc.fileName, strings.NewReader("data"), map[string]string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Origin", tc.origin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("upload from %s got status %d, want %d", tc.origin, resp.StatusCode, tc.want)
+		}
+		_, err = os.Stat(filepath.Join(tmpDir, tc.fileName))
+		if written := err == nil; written != (tc.want == http.StatusOK) {
+			t.Errorf("upload from %s written = %t, stat err = %v", tc.origin, written, err)
+		}
+	}
+}
+
 func sha256File(tb testing.TB, localPath string) string {
 	f, err := os.Open(localPath)
 	if err != nil {
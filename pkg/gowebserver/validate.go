@@ -0,0 +1,640 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// supportedSourceSchemes are the URI schemes that can be used in Serve.Source.
+	supportedSourceSchemes = map[string]bool{
+		"file":  true,
+		"http":  true,
+		"https": true,
+		"git":   true,
+		"ssh":   true,
+		"null":  true,
+	}
+
+	yamlErrorLinePattern = regexp.MustCompile(`^line (\d+): (.*)$`)
+)
+
+// ConfigError is a single problem found in the configuration.
+type ConfigError struct {
+	// Field is the YAML path of the offending field, e.g. "serve[1].endpoint".
+	Field string
+	// Line is the line in the configuration file, 0 if unknown.
+	Line int
+	// Message describes the problem.
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	parts := []string{}
+	if e.Line > 0 {
+		parts = append(parts, fmt.Sprintf("line %d", e.Line))
+	}
+	if e.Field != "" {
+		parts = append(parts, e.Field)
+	}
+	parts = append(parts, e.Message)
+	return strings.Join(parts, ": ")
+}
+
+// ConfigErrors is the list of problems found in the configuration.
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Validate checks the configuration for semantic problems such as duplicate
+// endpoints or conflicting ports. The returned error is of type ConfigErrors.
+func (c *Config) Validate() error {
+	return c.validate(nil)
+}
+
+func (c *Config) validate(doc *yaml.Node) error {
+	errs := ConfigErrors{}
+	add := func(field string, format string, args ...any) {
+		errs = append(errs, &ConfigError{
+			Field:   field,
+			Line:    yamlFieldLine(doc, field),
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	// Endpoints of each site, keyed by host pattern, "" for the default site.
+	sites := map[string]map[string]string{}
+	for i, s := range c.Serve {
+		field := fmt.Sprintf("serve[%d]", i)
+		endpoint := normalizeHTTPPath(s.Endpoint)
+		hosts := []string{""}
+		if len(s.Hosts) > 0 {
+			hosts = s.Hosts
+		}
+		for j, host := range hosts {
+			if len(s.Hosts) > 0 {
+				if err := validateHostPattern(host); err != nil {
+					add(fmt.Sprintf("%s.hosts[%d]", field, j), "%s", err)
+					continue
+				}
+			}
+			host = normalizeHost(host)
+			endpoints, ok := sites[host]
+			if !ok {
+				endpoints = map[string]string{}
+				sites[host] = endpoints
+			}
+			if other, ok := endpoints[endpoint]; ok && other != field {
+				if host == "" {
+					add(field+".endpoint", "duplicate endpoint '%s', already used by %s", endpoint, other)
+				} else {
+					add(field+".endpoint", "duplicate endpoint '%s' for host '%s', already used by %s", endpoint, host, other)
+				}
+			} else {
+				endpoints[endpoint] = field
+			}
+		}
+		if err := validateSource(s.Source); err != nil {
+			add(field+".source", "%s", err)
+		}
+		for j, rule := range s.Headers {
+			if err := rule.validate(); err != nil {
+				add(fmt.Sprintf("%s.headers[%d]", field, j), "%s", err)
+			}
+		}
+		for j, rule := range s.Cache {
+			if err := rule.validate(); err != nil {
+				add(fmt.Sprintf("%s.cache[%d]", field, j), "%s", err)
+			}
+		}
+		if s.ETag != "" && !slices.Contains(etagModes, s.ETag) {
+			add(field+".etag", "unknown mode '%s', want one of %s", s.ETag, strings.Join(etagModes, ", "))
+		}
+		for j, pattern := range s.Hidden {
+			if _, err := path.Match(pattern, ""); err != nil {
+				add(fmt.Sprintf("%s.hidden[%d]", field, j), "invalid pattern '%s', %s", pattern, err)
+			}
+		}
+	}
+
+	listeners := []struct {
+		field          string
+		addresses      []string
+		unixSocketMode string
+	}{
+		{"http", c.HTTP.Addresses, c.HTTP.UnixSocketMode},
+		{"https", c.HTTPS.Addresses, c.HTTPS.UnixSocketMode},
+	}
+	for _, l := range listeners {
+		for i, address := range l.addresses {
+			if _, _, err := parseListenAddress(address); err != nil {
+				add(fmt.Sprintf("%s.addresses[%d]", l.field, i), "%s", err)
+			}
+		}
+		if _, err := parseUnixSocketMode(l.unixSocketMode); err != nil {
+			add(l.field+".unixSocketMode", "%s", err)
+		}
+	}
+
+	if c.HTTP.Disabled && c.HTTPS.Disabled {
+		add("https.disabled", "HTTP and HTTPS cannot both be disabled")
+	}
+	if c.HTTP.RedirectToHTTPS && c.HTTPS.Disabled {
+		add("http.redirectToHTTPS", "cannot redirect to HTTPS when HTTPS is disabled")
+	}
+	if c.HTTPS.HSTS.MaxAge < 0 {
+		add("https.hsts.maxAge", "cannot be negative, got %s", c.HTTPS.HSTS.MaxAge)
+	}
+	if clientAuth := c.HTTPS.ClientAuth; clientAuth.Mode != "" && !slices.Contains(clientAuthModes, clientAuth.Mode) {
+		add("https.clientAuth.mode", "unknown mode '%s', want one of %s", clientAuth.Mode, strings.Join(clientAuthModes, ", "))
+	} else if clientAuth.enabled() {
+		if clientAuth.CAFile == "" {
+			add("https.clientAuth.caFile", "cannot be empty when https.clientAuth.mode is %s", clientAuth.Mode)
+		} else if _, err := readCertPool(clientAuth.CAFile); err != nil {
+			add("https.clientAuth.caFile", "%s", err)
+		}
+		if c.HTTPS.Disabled {
+			add("https.clientAuth.mode", "cannot ask for client certificates when HTTPS is disabled")
+		}
+	}
+	if identity := c.HTTPS.ClientAuth.Identity; identity != "" && !slices.Contains(certIdentityFields, identity) {
+		add("https.clientAuth.identity", "unknown field '%s', want one of %s", identity, strings.Join(certIdentityFields, ", "))
+	}
+
+	if !c.HTTP.Disabled && !c.HTTPS.Disabled && len(c.HTTP.Addresses) == 0 && len(c.HTTPS.Addresses) == 0 && c.HTTP.Port != 0 && c.HTTP.Port == c.HTTPS.Port {
+		add("https.port", "HTTP and HTTPS cannot both use port %d", c.HTTPS.Port)
+	}
+
+	limits := c.HTTP.Limits
+	durations := []struct {
+		field string
+		value time.Duration
+	}{
+		{"http.limits.readHeaderTimeout", limits.ReadHeaderTimeout},
+		{"http.limits.readTimeout", limits.ReadTimeout},
+		{"http.limits.writeTimeout", limits.WriteTimeout},
+		{"http.limits.idleTimeout", limits.IdleTimeout},
+		{"shutdown.drainTimeout", c.Shutdown.DrainTimeout},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			add(d.field, "cannot be negative, got %s", d.value)
+		}
+	}
+	sizes := []struct {
+		field string
+		value int64
+	}{
+		{"http.limits.maxHeaderBytes", int64(limits.MaxHeaderBytes)},
+		{"http.limits.maxBodyBytes", limits.MaxBodyBytes},
+		{"http.limits.multipartMemoryBytes", limits.MultipartMemoryBytes},
+		{"http.limits.maxConnections", int64(limits.MaxConnections)},
+	}
+	for _, size := range sizes {
+		if size.value < 0 {
+			add(size.field, "cannot be negative, got %d", size.value)
+		}
+	}
+	for _, endpoint := range slices.Sorted(maps.Keys(limits.EndpointMaxBodyBytes)) {
+		field := "http.limits.endpointMaxBodyBytes." + endpoint
+		if !strings.HasPrefix(endpoint, "/") {
+			add(field, "endpoint '%s' must start with '/'", endpoint)
+		}
+		if limits.EndpointMaxBodyBytes[endpoint] < 0 {
+			add(field, "cannot be negative, got %d", limits.EndpointMaxBodyBytes[endpoint])
+		}
+	}
+
+	type corsPolicy struct {
+		field string
+		cors  CORS
+	}
+	policies := []corsPolicy{{"cors", c.CORS}}
+	for i, s := range c.Serve {
+		if s.CORS != nil {
+			policies = append(policies, corsPolicy{fmt.Sprintf("serve[%d].cors", i), *s.CORS})
+		}
+	}
+	for _, p := range policies {
+		for i, origin := range p.cors.AllowedOrigins {
+			if err := validateOrigin(origin); err != nil {
+				add(fmt.Sprintf("%s.allowedOrigins[%d]", p.field, i), "%s", err)
+			}
+		}
+		if p.cors.AllowCredentials && !p.cors.Disabled && p.cors.allowsAllOrigins() {
+			add(p.field+".allowCredentials", "cannot allow credentials from all origins, list the allowed origins")
+		}
+		if p.cors.MaxAge < 0 {
+			add(p.field+".maxAge", "cannot be negative, got %s", p.cors.MaxAge)
+		}
+	}
+
+	type rateLimitField struct {
+		field string
+		limit RateLimit
+	}
+	rateLimits := []rateLimitField{{"rateLimit", c.RateLimit}, {"upload.rateLimit", c.Upload.RateLimit}}
+	for i, s := range c.Serve {
+		rateLimits = append(rateLimits, rateLimitField{fmt.Sprintf("serve[%d].rateLimit", i), s.RateLimit})
+	}
+	for _, r := range rateLimits {
+		if r.limit.RequestsPerSecond < 0 {
+			add(r.field+".requestsPerSecond", "cannot be negative, got %g", r.limit.RequestsPerSecond)
+		}
+		if r.limit.Burst < 0 {
+			add(r.field+".burst", "cannot be negative, got %d", r.limit.Burst)
+		}
+	}
+
+	type ipListField struct {
+		field  string
+		values []string
+	}
+	ipLists := []ipListField{
+		{"ipFilter.allow", c.IPFilter.Allow}, {"ipFilter.deny", c.IPFilter.Deny},
+		{"upload.ipFilter.allow", c.Upload.IPFilter.Allow}, {"upload.ipFilter.deny", c.Upload.IPFilter.Deny},
+		{"monitoring.ipFilter.allow", c.Monitoring.IPFilter.Allow}, {"monitoring.ipFilter.deny", c.Monitoring.IPFilter.Deny},
+		{"monitoring.debugIPFilter.allow", c.Monitoring.DebugIPFilter.Allow}, {"monitoring.debugIPFilter.deny", c.Monitoring.DebugIPFilter.Deny},
+		{"clientIP.trustedProxies", c.ClientIP.TrustedProxies},
+	}
+	for i, s := range c.Serve {
+		ipLists = append(ipLists, ipListField{fmt.Sprintf("serve[%d].ipFilter.allow", i), s.IPFilter.Allow}, ipListField{fmt.Sprintf("serve[%d].ipFilter.deny", i), s.IPFilter.Deny})
+	}
+	for _, l := range ipLists {
+		for i, value := range l.values {
+			if _, err := parsePrefixes([]string{value}); err != nil {
+				add(fmt.Sprintf("%s[%d]", l.field, i), "%s", err)
+			}
+		}
+	}
+	if header := c.ClientIP.Header; header != "" && !slices.ContainsFunc(clientIPHeaders, func(h string) bool { return strings.EqualFold(h, header) }) {
+		add("clientIP.header", "unknown header '%s', want one of %s", c.ClientIP.Header, strings.Join(clientIPHeaders, ", "))
+	}
+
+	if endpoint := c.Admin.Endpoint; endpoint != "" {
+		if !strings.HasPrefix(endpoint, "/") {
+			add("admin.endpoint", "endpoint '%s' must start with '/'", endpoint)
+		}
+		if c.Admin.TokenFile == "" && len(c.Admin.Roles) == 0 {
+			add("admin.endpoint", "the admin API needs a tokenFile or roles to protect it")
+		}
+	} else if len(c.Admin.Addresses) > 0 {
+		add("admin.addresses", "cannot listen for the admin API without an endpoint")
+	}
+	for i, address := range c.Admin.Addresses {
+		if _, _, err := parseListenAddress(address); err != nil {
+			add(fmt.Sprintf("admin.addresses[%d]", i), "%s", err)
+		}
+	}
+	if c.Admin.TokenFile != "" {
+		if _, err := readAdminToken(c.Admin.TokenFile); err != nil {
+			add("admin.tokenFile", "%s", err)
+		}
+	}
+	if slices.Contains(c.Admin.Roles, roleAnonymous) {
+		add("admin.roles", "cannot grant the admin API to the '%s' role", roleAnonymous)
+	}
+
+	for i, encoding := range c.Compression.Encodings {
+		if !slices.Contains(compressionEncodings, strings.ToLower(encoding)) {
+			add(fmt.Sprintf("compression.encodings[%d]", i), "unknown encoding '%s', want one of %s", encoding, strings.Join(compressionEncodings, ", "))
+		}
+	}
+	if c.Compression.MinSizeBytes < 0 {
+		add("compression.minSizeBytes", "cannot be negative, got %d", c.Compression.MinSizeBytes)
+	}
+	if level := c.Compression.Level; level != "" && !slices.Contains(compressionLevels, level) {
+		add("compression.level", "unknown level '%s', want one of %s", level, strings.Join(compressionLevels, ", "))
+	}
+
+	bandwidths := []struct {
+		field string
+		value int64
+	}{
+		{"bandwidth.download.bytesPerSecond", c.Bandwidth.Download.BytesPerSecond},
+		{"bandwidth.download.perConnectionBytesPerSecond", c.Bandwidth.Download.PerConnectionBytesPerSecond},
+		{"bandwidth.upload.bytesPerSecond", c.Bandwidth.Upload.BytesPerSecond},
+		{"bandwidth.upload.perConnectionBytesPerSecond", c.Bandwidth.Upload.PerConnectionBytesPerSecond},
+	}
+	for _, b := range bandwidths {
+		if b.value < 0 {
+			add(b.field, "cannot be negative, got %d", b.value)
+		}
+	}
+
+	if c.AccessLog.Format != "" && !slices.Contains(accessLogFormats, c.AccessLog.Format) {
+		add("accessLog.format", "unknown format '%s', want one of %s", c.AccessLog.Format, strings.Join(accessLogFormats, ", "))
+	}
+	if c.AccessLog.MaxSizeBytes < 0 {
+		add("accessLog.maxSizeBytes", "cannot be negative, got %d", c.AccessLog.MaxSizeBytes)
+	} else if c.AccessLog.MaxSizeBytes > 0 && isStdoutPath(c.AccessLog.Path) {
+		add("accessLog.maxSizeBytes", "cannot rotate stdout, set accessLog.path")
+	}
+	if c.AccessLog.MaxBackups < 0 {
+		add("accessLog.maxBackups", "cannot be negative, got %d", c.AccessLog.MaxBackups)
+	}
+
+	users := map[string]string{}
+	for i, u := range c.Auth.Users {
+		field := fmt.Sprintf("auth.users[%d]", i)
+		switch {
+		case u.Name == "":
+			add(field+".name", "cannot be empty")
+		case strings.Contains(u.Name, ":"):
+			add(field+".name", "cannot contain ':', got '%s'", u.Name)
+		case users[u.Name] != "":
+			add(field+".name", "duplicate user '%s', already defined by %s", u.Name, users[u.Name])
+		default:
+			users[u.Name] = field
+		}
+		if err := checkPasswordHash(u.PasswordHash); err != nil {
+			add(field+".passwordHash", "%s", err)
+		}
+	}
+	if c.Auth.HtpasswdFile != "" {
+		fileUsers, err := readHtpasswd(c.Auth.HtpasswdFile, c.Auth.HtpasswdRoles)
+		if err != nil {
+			add("auth.htpasswdFile", "%s", err)
+		}
+		for _, u := range fileUsers {
+			if other, ok := users[u.Name]; ok {
+				add("auth.htpasswdFile", "duplicate user '%s', already defined by %s", u.Name, other)
+			}
+			users[u.Name] = "auth.htpasswdFile"
+		}
+	}
+	if c.Auth.LoginEndpoint != "" && !strings.HasPrefix(c.Auth.LoginEndpoint, "/") {
+		add("auth.loginEndpoint", "must start with '/', got '%s'", c.Auth.LoginEndpoint)
+	}
+	if c.Auth.SessionTimeout < 0 {
+		add("auth.sessionTimeout", "cannot be negative, got %s", c.Auth.SessionTimeout)
+	}
+	if jwks := c.Auth.JWT.JWKS; isSupportedHTTP(jwks) {
+		if err := validateHTTPURL(jwks); err != nil {
+			add("auth.jwt.jwks", "%s", err)
+		}
+	} else if jwks != "" {
+		if _, err := newKeySet(jwks, nil); err != nil {
+			add("auth.jwt.jwks", "%s", err)
+		}
+	} else if c.Auth.JWT.Issuer != "" || c.Auth.JWT.Audience != "" {
+		add("auth.jwt.jwks", "cannot be empty when auth.jwt.issuer or auth.jwt.audience is set")
+	}
+	if oidc := c.Auth.OIDC; oidc.Issuer != "" {
+		if err := validateHTTPURL(oidc.Issuer); err != nil {
+			add("auth.oidc.issuer", "%s", err)
+		}
+		if oidc.ClientID == "" {
+			add("auth.oidc.clientId", "cannot be empty when auth.oidc.issuer is set")
+		}
+		if oidc.RedirectURL != "" {
+			if err := validateHTTPURL(oidc.RedirectURL); err != nil {
+				add("auth.oidc.redirectUrl", "%s", err)
+			}
+		}
+	} else if oidc.ClientID != "" || oidc.ClientSecret != "" || oidc.RedirectURL != "" {
+		add("auth.oidc.issuer", "cannot be empty when auth.oidc.clientId, auth.oidc.clientSecret or auth.oidc.redirectUrl is set")
+	}
+	if len(c.Auth.Claims.RoleMapping) > 0 && c.Auth.Claims.Roles == "" {
+		add("auth.claims.roleMapping", "requires auth.claims.roles")
+	}
+
+	type accessField struct {
+		field  string
+		access map[string][]string
+	}
+	hasUsers := len(c.Auth.Users) > 0 || c.Auth.HtpasswdFile != "" || c.Auth.JWT.JWKS != "" || c.Auth.OIDC.Issuer != "" || c.HTTPS.ClientAuth.enabled()
+	accessRules := []accessField{{"upload.access", c.Upload.Access}}
+	for i, s := range c.Serve {
+		accessRules = append(accessRules, accessField{fmt.Sprintf("serve[%d].access", i), s.Access})
+	}
+	for _, a := range accessRules {
+		for _, role := range slices.Sorted(maps.Keys(a.access)) {
+			if !hasUsers && role != roleAnonymous {
+				add(a.field+"."+role, "no user has role '%s', set auth.users, auth.htpasswdFile, auth.jwt, auth.oidc or https.clientAuth", role)
+			}
+			for i, p := range a.access[role] {
+				if !slices.Contains(permissions, p) {
+					add(fmt.Sprintf("%s.%s[%d]", a.field, role, i), "unknown permission '%s', want one of %s", p, strings.Join(permissions, ", "))
+				}
+			}
+		}
+	}
+
+	if c.Upload.Endpoint != "" {
+		upload := normalizeHTTPPath(c.Upload.Endpoint)
+		for i, s := range c.Serve {
+			endpoint := normalizeHTTPPath(s.Endpoint)
+			if endpoint != "/" && (endpoint == upload || (strings.HasSuffix(c.Upload.Endpoint, "/") && strings.HasPrefix(endpoint, upload))) {
+				add("upload.endpoint", "upload endpoint '%s' shadows serve[%d] ('%s')", c.Upload.Endpoint, i, endpoint)
+			}
+		}
+	}
+
+	certs := []struct {
+		field string
+		path  string
+	}{
+		{"https.certificate.path", c.HTTPS.Certificate.CertificateFilePath},
+		{"https.certificate.privateKey", c.HTTPS.Certificate.PrivateKeyFilePath},
+		{"https.certificate.rootPath", c.HTTPS.Certificate.RootCertificateFilePath},
+		{"https.certificate.rootPrivateKey", c.HTTPS.Certificate.RootPrivateKeyFilePath},
+	}
+	for _, cert := range certs {
+		if err := validateReadableFile(cert.path); err != nil {
+			add(cert.field, "%s", err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateSource checks that the source is a local path or a URI with a
+// supported scheme.
+func validateSource(source string) error {
+	if !strings.Contains(source, "://") {
+		return nil
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return fmt.Errorf("invalid source URI '%s', %w", source, err)
+	}
+	if !supportedSourceSchemes[strings.ToLower(u.Scheme)] {
+		return fmt.Errorf("unsupported source URI scheme '%s' in '%s'", u.Scheme, source)
+	}
+	return nil
+}
+
+// validateHTTPURL checks that the URL is an absolute http or https URL.
+func validateHTTPURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL '%s', %w", rawURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be an http or https URL, got '%s'", rawURL)
+	}
+	return nil
+}
+
+// validateReadableFile checks that the file can be read if it exists. Missing
+// files are allowed since certificates are generated on demand.
+func validateReadableFile(filePath string) error {
+	if filePath == "" {
+		return nil
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("cannot access '%s', %w", filePath, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("'%s' is a directory", filePath)
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("cannot read '%s', %w", filePath, err)
+	}
+	return f.Close()
+}
+
+// decodeErrorsToConfigErrors converts YAML decoding errors to ConfigErrors so
+// that they can be reported together with the semantic validation errors.
+func decodeErrorsToConfigErrors(err error) ConfigErrors {
+	msgs := []string{err.Error()}
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	}
+
+	errs := ConfigErrors{}
+	for _, msg := range msgs {
+		msg = strings.TrimPrefix(msg, "yaml: ")
+		ce := &ConfigError{Message: msg}
+		if m := yamlErrorLinePattern.FindStringSubmatch(msg); m != nil {
+			ce.Line, _ = strconv.Atoi(m[1])
+			ce.Message = m[2]
+		}
+		errs = append(errs, ce)
+	}
+	return errs
+}
+
+// yamlFieldLine returns the line of the field, e.g. "serve[1].endpoint", in the
+// YAML document. If the field is not present the line of the closest parent
+// that is present is returned, or 0 if none.
+func yamlFieldLine(doc *yaml.Node, field string) int {
+	if doc == nil {
+		return 0
+	}
+	n := doc
+	if n.Kind == yaml.DocumentNode {
+		if len(n.Content) == 0 {
+			return 0
+		}
+		n = n.Content[0]
+	}
+
+	line := 0
+	for _, part := range strings.Split(field, ".") {
+		name, index := part, -1
+		if i := strings.Index(part, "["); i >= 0 {
+			name = part[:i]
+			index, _ = strconv.Atoi(strings.TrimSuffix(part[i+1:], "]"))
+		}
+
+		child := yamlMappingValue(n, name)
+		if child == nil {
+			return line
+		}
+		n = child
+		line = n.Line
+		if index >= 0 {
+			if n.Kind != yaml.SequenceNode || index >= len(n.Content) {
+				return line
+			}
+			n = n.Content[index]
+			line = n.Line
+		}
+	}
+	return line
+}
+
+func yamlMappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// runValidate loads and validates the configuration, prints every problem
+// found and returns the process exit code.
+func runValidate(w io.Writer) int {
+	_, err := Load()
+	if err == nil {
+		fmt.Fprintln(w, "Configuration is valid.")
+		return 0
+	}
+
+	printConfigErrors(w, err)
+	return 1
+}
+
+// printConfigErrors prints each configuration problem on its own line prefixed
+// with the configuration file.
+func printConfigErrors(w io.Writer, err error) {
+	prefix := ""
+	if *configFileFlag != "" {
+		prefix = *configFileFlag + ": "
+	}
+	var configErrs ConfigErrors
+	if errors.As(err, &configErrs) {
+		for _, ce := range configErrs {
+			fmt.Fprintf(w, "%s%s\n", prefix, ce)
+		}
+	} else {
+		fmt.Fprintf(w, "%s%s\n", prefix, err)
+	}
+}
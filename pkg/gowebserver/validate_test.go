@@ -0,0 +1,481 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfigValidate(t *testing.T) {
+	certDir := mustTempDir(t)
+
+	testCases := []struct {
+		name   string
+		config *Config
+		want   []string
+	}{
+		{
+			name:   "empty",
+			config: &Config{},
+			want:   []string{},
+		},
+		{
+			name: "duplicate endpoints",
+			config: &Config{
+				Serve: []Serve{
+					{Source: "/a", Endpoint: "/pub"},
+					{Source: "/b", Endpoint: "pub/"},
+				},
+			},
+			want: []string{"serve[1].endpoint: duplicate endpoint '/pub/', already used by serve[0]"},
+		},
+		{
+			name: "virtual hosts",
+			config: &Config{
+				Serve: []Serve{
+					{Source: "/a", Endpoint: "/"},
+					{Source: "/b", Endpoint: "/", Hosts: []string{"docs.lan"}},
+					{Source: "/c", Endpoint: "/", Hosts: []string{"*.lan", "DOCS.lan"}},
+					{Source: "/d", Endpoint: "/", Hosts: []string{"", "docs.lan:8080", "[a"}},
+				},
+			},
+			want: []string{
+				"serve[2].endpoint: duplicate endpoint '/' for host 'docs.lan', already used by serve[1]",
+				"serve[3].hosts[0]: host cannot be empty",
+				"serve[3].hosts[1]: invalid host 'docs.lan:8080', want a hostname without scheme, port or path",
+				"serve[3].hosts[2]: invalid host pattern '[a', syntax error in pattern",
+			},
+		},
+		{
+			name: "cors",
+			config: &Config{
+				CORS: CORS{AllowCredentials: true, MaxAge: -time.Second},
+				Serve: []Serve{
+					{Source: "/a", Endpoint: "/", CORS: &CORS{AllowedOrigins: []string{"https://*.example.com", "example.com", "https://*.*.com", "https://a.com/"}}},
+					{Source: "/b", Endpoint: "/b", CORS: &CORS{AllowedOrigins: []string{"https://a.com"}, AllowCredentials: true}},
+				},
+			},
+			want: []string{
+				"cors.allowCredentials: cannot allow credentials from all origins, list the allowed origins",
+				"cors.maxAge: cannot be negative, got -1s",
+				"serve[0].cors.allowedOrigins[1]: invalid origin 'example.com', want a scheme and host such as https://example.com",
+				"serve[0].cors.allowedOrigins[2]: invalid origin 'https://*.*.com', only one wildcard is allowed",
+				"serve[0].cors.allowedOrigins[3]: invalid origin 'https://a.com/', want a scheme and host such as https://example.com",
+			},
+		},
+		{
+			name: "rate limits",
+			config: &Config{
+				RateLimit: RateLimit{RequestsPerSecond: -1},
+				Upload:    Serve{RateLimit: RateLimit{Burst: -1}},
+				Serve: []Serve{
+					{Source: "/a", Endpoint: "/", RateLimit: RateLimit{RequestsPerSecond: -0.5}},
+				},
+			},
+			want: []string{
+				"rateLimit.requestsPerSecond: cannot be negative, got -1",
+				"upload.rateLimit.burst: cannot be negative, got -1",
+				"serve[0].rateLimit.requestsPerSecond: cannot be negative, got -0.5",
+			},
+		},
+		{
+			name: "ip filters",
+			config: &Config{
+				IPFilter: IPFilter{Allow: []string{"10.0.0.0/8", "10.0.0.0/33"}},
+				Upload:   Serve{IPFilter: IPFilter{Deny: []string{"localhost"}}},
+				Monitoring: Monitoring{
+					DebugIPFilter: IPFilter{Allow: []string{"::1", "127.0.0.1"}},
+				},
+				Serve: []Serve{
+					{Source: "/a", Endpoint: "/", IPFilter: IPFilter{Allow: []string{"192.168.1.1/24"}, Deny: []string{"300.0.0.1"}}},
+				},
+				ClientIP: ClientIP{TrustedProxies: []string{"proxy"}, Header: "X-Client-IP"},
+			},
+			want: []string{
+				`ipFilter.allow[1]: invalid CIDR '10.0.0.0/33', netip.ParsePrefix("10.0.0.0/33"): prefix length out of range`,
+				`upload.ipFilter.deny[0]: invalid IP address 'localhost', ParseAddr("localhost"): unable to parse IP`,
+				`clientIP.trustedProxies[0]: invalid IP address 'proxy', ParseAddr("proxy"): unable to parse IP`,
+				`serve[0].ipFilter.deny[0]: invalid IP address '300.0.0.1', ParseAddr("300.0.0.1"): IPv4 field has value >255`,
+				"clientIP.header: unknown header 'X-Client-IP', want one of X-Forwarded-For, X-Real-IP, Forwarded",
+			},
+		},
+		{
+			name: "admin",
+			config: &Config{
+				Admin: Admin{Endpoint: "admin", Addresses: []string{"127.0.0.1:http"}, TokenFile: "/does/not/exist/token", Roles: []string{"anonymous"}},
+			},
+			want: []string{
+				"admin.endpoint: endpoint 'admin' must start with '/'",
+				"admin.addresses[0]: invalid port in listen address '127.0.0.1:http'",
+				"admin.tokenFile: cannot read admin token file '/does/not/exist/token', open /does/not/exist/token: no such file or directory",
+				"admin.roles: cannot grant the admin API to the 'anonymous' role",
+			},
+		},
+		{
+			name: "admin unprotected",
+			config: &Config{
+				Admin: Admin{Endpoint: "/admin"},
+			},
+			want: []string{"admin.endpoint: the admin API needs a tokenFile or roles to protect it"},
+		},
+		{
+			name: "admin addresses without endpoint",
+			config: &Config{
+				Admin: Admin{Addresses: []string{"127.0.0.1:9090"}},
+			},
+			want: []string{"admin.addresses: cannot listen for the admin API without an endpoint"},
+		},
+		{
+			name: "cache",
+			config: &Config{
+				Serve: []Serve{{Source: ".", Endpoint: "/", Cache: []CacheRule{{Path: "[", CacheControl: "no-cache"}, {Path: "*.js"}}, ETag: "weak"}},
+			},
+			want: []string{
+				"serve[0].cache[0]: invalid path pattern '[', syntax error in pattern",
+				"serve[0].cache[1]: rule does not set cacheControl",
+				"serve[0].etag: unknown mode 'weak', want one of auto, content, off",
+			},
+		},
+		{
+			name: "compression",
+			config: &Config{
+				Compression: Compression{Enabled: true, Encodings: []string{"gzip", "deflate"}, MinSizeBytes: -1, Level: "max"},
+			},
+			want: []string{
+				"compression.encodings[1]: unknown encoding 'deflate', want one of br, zstd, gzip",
+				"compression.minSizeBytes: cannot be negative, got -1",
+				"compression.level: unknown level 'max', want one of fastest, default, best",
+			},
+		},
+		{
+			name: "bandwidth",
+			config: &Config{
+				Bandwidth: Bandwidth{
+					Download: BandwidthLimit{BytesPerSecond: -1},
+					Upload:   BandwidthLimit{PerConnectionBytesPerSecond: -2},
+				},
+			},
+			want: []string{
+				"bandwidth.download.bytesPerSecond: cannot be negative, got -1",
+				"bandwidth.upload.perConnectionBytesPerSecond: cannot be negative, got -2",
+			},
+		},
+		{
+			name: "auth",
+			config: &Config{
+				Serve: []Serve{
+					{Source: "/a", Endpoint: "/a", Access: map[string][]string{"staff": {"read", "write"}}},
+				},
+				Upload: Serve{Access: map[string][]string{roleAnonymous: {"upload"}}},
+				Auth: Auth{
+					Users: []User{
+						{Name: "frank:admin", PasswordHash: "$apr1$salt$hash"},
+					},
+					LoginEndpoint:  "login",
+					SessionTimeout: -time.Second,
+				},
+			},
+			want: []string{
+				"auth.users[0].name: cannot contain ':', got 'frank:admin'",
+				"auth.users[0].passwordHash: unsupported password hash, use bcrypt (htpasswd -B) or argon2id",
+				"auth.loginEndpoint: must start with '/', got 'login'",
+				"auth.sessionTimeout: cannot be negative, got -1s",
+				"serve[0].access.staff[1]: unknown permission 'write', want one of read, upload, delete, admin",
+			},
+		},
+		{
+			name: "access without users",
+			config: &Config{
+				Serve: []Serve{
+					{Source: "/a", Endpoint: "/a", Access: map[string][]string{roleAnonymous: {"read"}, "staff": {"upload"}}},
+				},
+			},
+			want: []string{
+				"serve[0].access.staff: no user has role 'staff', set auth.users, auth.htpasswdFile, auth.jwt, auth.oidc or https.clientAuth",
+			},
+		},
+		{
+			name: "jwt and oidc",
+			config: &Config{
+				Serve: []Serve{
+					{Source: "/a", Endpoint: "/a", Access: map[string][]string{"staff": {"read"}}},
+				},
+				Auth: Auth{
+					JWT:    JWT{JWKS: "ftp://keys.example.com/jwks.json"},
+					OIDC:   OIDC{Issuer: "https://idp.example.com", RedirectURL: "/login/callback"},
+					Claims: Claims{RoleMapping: map[string][]string{"engineering": {"staff"}}},
+				},
+			},
+			want: []string{
+				"auth.jwt.jwks: cannot read JSON Web Key Set 'ftp://keys.example.com/jwks.json', open ftp://keys.example.com/jwks.json: no such file or directory",
+				"auth.oidc.clientId: cannot be empty when auth.oidc.issuer is set",
+				"auth.oidc.redirectUrl: must be an http or https URL, got '/login/callback'",
+				"auth.claims.roleMapping: requires auth.claims.roles",
+			},
+		},
+		{
+			name: "client auth",
+			config: &Config{
+				HTTPS: HTTPS{ClientAuth: ClientAuth{Mode: "optional", Identity: "serial"}},
+			},
+			want: []string{
+				"https.clientAuth.mode: unknown mode 'optional', want one of none, request, require",
+				"https.clientAuth.identity: unknown field 'serial', want one of commonName, email, dns, uri",
+			},
+		},
+		{
+			name: "client auth without CA",
+			config: &Config{
+				HTTPS: HTTPS{ClientAuth: ClientAuth{Mode: "require"}},
+			},
+			want: []string{
+				"https.clientAuth.caFile: cannot be empty when https.clientAuth.mode is require",
+			},
+		},
+		{
+			name: "access log",
+			config: &Config{
+				AccessLog: AccessLog{Format: "apache", MaxSizeBytes: 1024, MaxBackups: -1},
+			},
+			want: []string{
+				"accessLog.format: unknown format 'apache', want one of common, combined, extended, json",
+				"accessLog.maxSizeBytes: cannot rotate stdout, set accessLog.path",
+				"accessLog.maxBackups: cannot be negative, got -1",
+			},
+		},
+		{
+			name: "header rules",
+			config: &Config{
+				Serve: []Serve{
+					{Source: "/a", Endpoint: "/", Headers: []HeaderRule{
+						{Path: "*.html", Preset: "secure"},
+						{ContentType: "image/*"},
+					}},
+				},
+			},
+			want: []string{"serve[0].headers[1]: rule does not set or remove any headers"},
+		},
+		{
+			name: "same port",
+			config: &Config{
+				HTTP:  HTTP{Port: 8080},
+				HTTPS: HTTPS{Port: 8080},
+			},
+			want: []string{"https.port: HTTP and HTTPS cannot both use port 8080"},
+		},
+		{
+			name: "upload shadows mount",
+			config: &Config{
+				Serve:  []Serve{{Source: "/a", Endpoint: "/upload"}},
+				Upload: Serve{Source: "/tmp", Endpoint: "/upload"},
+			},
+			want: []string{"upload.endpoint: upload endpoint '/upload' shadows serve[0] ('/upload/')"},
+		},
+		{
+			name: "upload at root mount",
+			config: &Config{
+				Serve:  []Serve{{Source: "/a", Endpoint: "/"}},
+				Upload: Serve{Source: "/tmp", Endpoint: "/upload.asp"},
+			},
+			want: []string{},
+		},
+		{
+			name: "unsupported source",
+			config: &Config{
+				Serve: []Serve{
+					{Source: "ftp://example.com/files", Endpoint: "/"},
+					{Source: "https://example.com/", Endpoint: "/web"},
+					{Source: "git@github.com:jeremyje/gowebserver.git", Endpoint: "/git"},
+				},
+			},
+			want: []string{"serve[0].source: unsupported source URI scheme 'ftp' in 'ftp://example.com/files'"},
+		},
+		{
+			name: "listeners disabled",
+			config: &Config{
+				HTTP:  HTTP{Disabled: true, RedirectToHTTPS: true},
+				HTTPS: HTTPS{Disabled: true},
+			},
+			want: []string{
+				"https.disabled: HTTP and HTTPS cannot both be disabled",
+				"http.redirectToHTTPS: cannot redirect to HTTPS when HTTPS is disabled",
+			},
+		},
+		{
+			name: "invalid listen addresses",
+			config: &Config{
+				HTTP:  HTTP{Addresses: []string{"127.0.0.1", "tcp://127.0.0.1"}},
+				HTTPS: HTTPS{Addresses: []string{"unix://"}, UnixSocketMode: "rw"},
+			},
+			want: []string{
+				"http.addresses[1]: unsupported listen address 'tcp://127.0.0.1'",
+				"https.addresses[0]: missing socket path in 'unix://'",
+				"https.unixSocketMode: invalid Unix socket mode 'rw', want octal permissions such as 0660",
+			},
+		},
+		{
+			name: "negative limits",
+			config: &Config{
+				HTTP: HTTP{Limits: Limits{
+					ReadTimeout:          -time.Second,
+					MaxConnections:       -1,
+					EndpointMaxBodyBytes: map[string]int64{"upload": 10},
+				}},
+			},
+			want: []string{
+				"http.limits.readTimeout: cannot be negative, got -1s",
+				"http.limits.maxConnections: cannot be negative, got -1",
+				"http.limits.endpointMaxBodyBytes.upload: endpoint 'upload' must start with '/'",
+			},
+		},
+		{
+			name: "certificate is a directory",
+			config: &Config{
+				HTTPS: HTTPS{Certificate: Certificate{CertificateFilePath: certDir}},
+			},
+			want: []string{"https.certificate.path: '" + certDir + "' is a directory"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got := []string{}
+			if err := tc.config.Validate(); err != nil {
+				var errs ConfigErrors
+				if !errors.As(err, &errs) {
+					t.Fatalf("want ConfigErrors, got %T", err)
+				}
+				for _, ce := range errs {
+					got = append(got, ce.Error())
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Validate() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadAndValidateReportsLines(t *testing.T) {
+	fp, err := writeTempFile(`serve:
+  - source: /a
+    endpoint: /pub
+  - source: /b
+    endpoint: /pub
+enhancedlist: true
+http:
+  port: 9000
+https:
+  port: 9000
+`)
+	defer os.Remove(fp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = loadAndValidate(nil, fp.Name())
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("want ConfigErrors, got %v", err)
+	}
+
+	got := []string{}
+	for _, ce := range errs {
+		got = append(got, ce.Error())
+	}
+	want := []string{
+		"line 6: field enhancedlist not found in type gowebserver.Config",
+		"line 5: serve[1].endpoint: duplicate endpoint '/pub/', already used by serve[0]",
+		"line 10: https.port: HTTP and HTTPS cannot both use port 9000",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("loadAndValidate() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	valid, err := writeTempFile("serve:\n  - source: /a\n    endpoint: /\n")
+	defer os.Remove(valid.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid, err := writeTempFile("verbose: true\nenhancedlist: true\n")
+	defer os.Remove(invalid.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := *configFileFlag
+	defer func() {
+		*configFileFlag = prev
+	}()
+
+	testCases := []struct {
+		configFile string
+		wantCode   int
+		wantOutput string
+	}{
+		{configFile: valid.Name(), wantCode: 0, wantOutput: "Configuration is valid.\n"},
+		{configFile: invalid.Name(), wantCode: 1, wantOutput: invalid.Name() + ": line 2: field enhancedlist not found in type gowebserver.Config\n"},
+	}
+
+	for _, tc := range testCases {
+		*configFileFlag = tc.configFile
+		w := &bytes.Buffer{}
+		if got := runValidate(w); got != tc.wantCode {
+			t.Errorf("runValidate() got exit code %d, want %d", got, tc.wantCode)
+		}
+		if diff := cmp.Diff(tc.wantOutput, w.String()); diff != "" {
+			t.Errorf("runValidate() output mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestYAMLFieldLine(t *testing.T) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(strings.TrimSpace(populatedConfigYaml)), doc); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		field string
+		want  int
+	}{
+		{field: "verbose", want: 1},
+		{field: "serve[0].endpoint", want: 4},
+		{field: "serve[5].endpoint", want: 3},
+		{field: "https.certificate.path", want: 30},
+		{field: "https.missing", want: 23},
+		{field: "missing", want: 0},
+	}
+
+	for _, tc := range testCases {
+		if got := yamlFieldLine(doc, tc.field); got != tc.want {
+			t.Errorf("yamlFieldLine(%q) got %d, want %d", tc.field, got, tc.want)
+		}
+	}
+	if got := yamlFieldLine(nil, "verbose"); got != 0 {
+		t.Errorf("yamlFieldLine(nil) got %d, want 0", got)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// virtualHost is the site served for the hostnames matching pattern.
+type virtualHost struct {
+	pattern string
+	paths   []servePath
+	handler http.Handler
+}
+
+// hostRouter dispatches requests to the site of the first virtual host that
+// matches the Host header, or to the default site.
+type hostRouter struct {
+	hosts    []*virtualHost
+	fallback http.Handler
+}
+
+func (h *hostRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.route(r.Host).ServeHTTP(w, r)
+}
+
+func (h *hostRouter) route(host string) http.Handler {
+	host = normalizeHost(host)
+	for _, vh := range h.hosts {
+		if matchHost(vh.pattern, host) {
+			return vh.handler
+		}
+	}
+	return h.fallback
+}
+
+// groupVirtualHosts splits the mounts into the default site, the mounts
+// without hosts, and one site per host pattern. Exact hostnames are matched
+// before wildcard patterns, otherwise patterns keep their configuration order.
+func groupVirtualHosts(paths []servePath) ([]servePath, []*virtualHost) {
+	defaultSite := []servePath{}
+	hosts := []*virtualHost{}
+	byPattern := map[string]*virtualHost{}
+	for _, p := range paths {
+		if len(p.hosts) == 0 {
+			defaultSite = append(defaultSite, p)
+			continue
+		}
+		for _, pattern := range p.hosts {
+			pattern = normalizeHost(pattern)
+			vh, ok := byPattern[pattern]
+			if !ok {
+				vh = &virtualHost{pattern: pattern}
+				byPattern[pattern] = vh
+				hosts = append(hosts, vh)
+			}
+			vh.paths = append(vh.paths, p)
+		}
+	}
+	sort.SliceStable(hosts, func(i, j int) bool {
+		return !isHostWildcard(hosts[i].pattern) && isHostWildcard(hosts[j].pattern)
+	})
+	return defaultSite, hosts
+}
+
+// normalizeHost returns the lower case hostname without the port and the
+// trailing dot, e.g. "Docs.LAN.:8080" is "docs.lan".
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// matchHost reports whether the hostname matches the pattern, e.g.
+// "*.example.com" matches "www.example.com".
+func matchHost(pattern string, host string) bool {
+	if !isHostWildcard(pattern) {
+		return pattern == host
+	}
+	ok, _ := path.Match(pattern, host)
+	return ok
+}
+
+func isHostWildcard(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// validateHostPattern checks that the host pattern can be matched.
+func validateHostPattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("host cannot be empty")
+	}
+	if strings.ContainsAny(pattern, "/:") {
+		return fmt.Errorf("invalid host '%s', want a hostname without scheme, port or path", pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid host pattern '%s', %w", pattern, err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2026 Jeremy Edwards
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gowebserver
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{input: "docs.lan", want: "docs.lan"},
+		{input: "Docs.LAN:8080", want: "docs.lan"},
+		{input: "docs.lan.", want: "docs.lan"},
+		{input: "[::1]:8080", want: "::1"},
+		{input: "[::1]", want: "::1"},
+		{input: "", want: ""},
+	}
+
+	for _, tc := range testCases {
+		if got := normalizeHost(tc.input); got != tc.want {
+			t.Errorf("normalizeHost(%q) got %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{pattern: "docs.lan", host: "docs.lan", want: true},
+		{pattern: "docs.lan", host: "www.docs.lan", want: false},
+		{pattern: "*.example.com", host: "www.example.com", want: true},
+		{pattern: "*.example.com", host: "example.com", want: false},
+		{pattern: "downloads?.lan", host: "downloads.lan", want: false},
+		{pattern: "download?.lan", host: "downloads.lan", want: true},
+	}
+
+	for _, tc := range testCases {
+		if got := matchHost(tc.pattern, tc.host); got != tc.want {
+			t.Errorf("matchHost(%q, %q) got %t, want %t", tc.pattern, tc.host, got, tc.want)
+		}
+	}
+}
+
+func TestGroupVirtualHosts(t *testing.T) {
+	paths := []servePath{
+		{localPath: "/default", httpPath: "/"},
+		{localPath: "/wildcard", httpPath: "/", hosts: []string{"*.lan"}},
+		{localPath: "/docs", httpPath: "/", hosts: []string{"Docs.lan", "docs.example.com"}},
+		{localPath: "/shared", httpPath: "/shared/", hosts: []string{"docs.lan", "*.lan"}},
+	}
+
+	defaultSite, hosts := groupVirtualHosts(paths)
+	if diff := cmp.Diff([]string{"/default"}, localPaths(defaultSite)); diff != "" {
+		t.Errorf("default site mismatch (-want +got):\n%s", diff)
+	}
+
+	got := map[string][]string{}
+	order := []string{}
+	for _, vh := range hosts {
+		order = append(order, vh.pattern)
+		got[vh.pattern] = localPaths(vh.paths)
+	}
+	wantOrder := []string{"docs.lan", "docs.example.com", "*.lan"}
+	if diff := cmp.Diff(wantOrder, order); diff != "" {
+		t.Errorf("host order mismatch (-want +got):\n%s", diff)
+	}
+	want := map[string][]string{
+		"docs.lan":         {"/docs", "/shared"},
+		"docs.example.com": {"/docs"},
+		"*.lan":            {"/wildcard", "/shared"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("host mounts mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func localPaths(paths []servePath) []string {
+	result := []string{}
+	for _, p := range paths {
+		result = append(result, p.localPath)
+	}
+	return result
+}
+
+func TestWebServer_VirtualHosts(t *testing.T) {
+	dirs := map[string]string{}
+	for _, name := range []string{"default", "docs", "downloads", "lan"} {
+		dir := mustTempDir(t)
+		if err := os.WriteFile(filepath.Join(dir, "site.txt"), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		dirs[name] = dir
+	}
+
+	baseURL, close := serveAsync(t, &Config{
+		Serve: []Serve{
+			{Source: dirs["default"], Endpoint: "/"},
+			{Source: dirs["docs"], Endpoint: "/", Hosts: []string{"docs.lan"}},
+			{Source: dirs["downloads"], Endpoint: "/files", Hosts: []string{"downloads.lan"}},
+			{Source: dirs["lan"], Endpoint: "/", Hosts: []string{"*.lan"}},
+		},
+	})
+	defer close()
+
+	testCases := []struct {
+		host string
+		path string
+		want string
+	}{
+		{host: "", path: "/site.txt", want: "default"},
+		{host: "docs.lan", path: "/site.txt", want: "docs"},
+		{host: "DOCS.lan:8080", path: "/site.txt", want: "docs"},
+		{host: "downloads.lan", path: "/files/site.txt", want: "downloads"},
+		{host: "printer.lan", path: "/site.txt", want: "lan"},
+		{host: "example.com", path: "/site.txt", want: "default"},
+	}
+
+	for _, tc := range testCases {
+		got := mustGetHostBody(t, baseURL+tc.path, tc.host)
+		if got != tc.want {
+			t.Errorf("GET %s%s got %q, want %q", tc.host, tc.path, got, tc.want)
+		}
+	}
+
+	// The generated root index of a site only lists the site's own mounts.
+	index := mustGetHostBody(t, baseURL+"/", "downloads.lan")
+	if !strings.Contains(index, `href="files"`) {
+		t.Errorf("want a link to files in the downloads.lan index, got:\n%s", index)
+	}
+}
+
+func mustGetHostBody(tb testing.TB, url string, host string) string {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if host != "" {
+		req.Host = host
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return string(body)
+}